@@ -5,10 +5,17 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/FelipePn10/kariuki/pkg/bell"
+	"github.com/FelipePn10/kariuki/pkg/charset"
+	"github.com/FelipePn10/kariuki/pkg/clipboard"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/export"
+	"github.com/FelipePn10/kariuki/pkg/policy"
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
@@ -31,19 +38,38 @@ type TerminalConfig struct {
 	AutoSuggest     bool          `mapstructure:"auto_suggest"`
 	InactivityClose time.Duration `mapstructure:"inactivity_close"`
 	LRUCacheSize    int           `mapstructure:"lru_cache_size"`
+	PrefixKey       string        `mapstructure:"prefix_key"`          // Starts kariuki's key bindings, e.g. C-]
+	ExportFormat    string        `mapstructure:"export_format"`       // text, ansi or html
+	Term            string        `mapstructure:"term"`                // TERM of programs; xterm-256color when its entry is not installed
+	Multiplexer     bool          `mapstructure:"multiplexer"`         // Tabs and split panes, see pkg/mux
+	ShellPrompt     bool          `mapstructure:"shell_prompt"`        // Default shell gets the prompt setting with OSC 133 marks
+	DaemonSocket    string        `mapstructure:"daemon_socket"`       // Socket of `kariuki daemon`; empty for the default
+	ControlSocket   string        `mapstructure:"control_socket"`      // Control API of the daemon; empty for control.sock next to DaemonSocket
+	RecordSessions  bool          `mapstructure:"record_sessions"`     // asciicast files next to HistoryFile
+	WebAddress      string        `mapstructure:"web_address"`         // host:port of `kariuki web`
+	SSHAddress      string        `mapstructure:"ssh_address"`         // host:port of `kariuki ssh`
+	SSHHostKey      string        `mapstructure:"ssh_host_key"`        // Private key file, created if missing; empty for the default
+	SSHAuthorized   string        `mapstructure:"ssh_authorized_keys"` // Keys that may log in to `kariuki ssh`; empty for the default
 
 	// Section: Security and Access
 	MaxSessionTime  time.Duration `mapstructure:"max_session_time"`
 	AllowedCommands []string      `mapstructure:"allowed_commands"`
 	BlockedCommands []string      `mapstructure:"blocked_commands"`
-	EnableLogging   bool          `mapstructure:"enable_logging"` // Log executed commands
+	EnableLogging   bool          `mapstructure:"enable_logging"` // Log executed commands, to AuditLogPath
+	AuditLog        string        `mapstructure:"audit_log"`      // Empty for kariuki-audit.log next to HistoryFile
+	ShareAddress    string        `mapstructure:"share_address"`  // Loopback host:port or socket path where viewers join shared sessions
+
+	// OSC 52: "write-only", "read-write" or "disabled", and the most bytes
+	// a program may copy at once.
+	Clipboard        string `mapstructure:"clipboard"`
+	ClipboardMaxSize int    `mapstructure:"clipboard_max_size"`
 
 	// Section: Config PTY
 	Rows         int    `mapstructure:"rows"`
 	Cols         int    `mapstructure:"cols"`
 	ScrollBuffer int    `mapstructure:"scroll_buffer"`
-	Encoding     string `mapstructure:"encoding"` // UTF-8 etc..
-	BellSound    string `mapstructure:"bell_sound"`
+	Encoding     string `mapstructure:"encoding"`     // UTF-8 etc..
+	BellSound    string `mapstructure:"bell_sound"`   // system, visual, none or command:<program>
 	EnableMouse  bool   `mapstructure:"enable_mouse"` // Mouse event support
 }
 
@@ -51,97 +77,133 @@ var (
 	configInstance *TerminalConfig // Instance of TerminalConfig
 	configMutex    sync.RWMutex
 	configErr      error
-	configOnce     sync.Once // Ensures a single boot
+	watchedPaths   = map[string]bool{} // Config files with a running watcher
+	reloadHooks    []*reloadHook
 )
 
+// reloadHook is a function registered with OnReload; its address tells it
+// apart from others when it is removed.
+type reloadHook struct {
+	fn func(*TerminalConfig)
+}
+
 // Loads terminal settings from a configuration file.
-// Loads terminal settings from a configuration file.
+// Every call reads the configuration again and replaces the shared instance
+// returned by GetConfig. The file is read without holding configMutex, so
+// ReloadConfig and the watcher can call it, and a file is watched once
+// however often it is loaded.
 func LoadConfig(configPath, kariuki string) (*TerminalConfig, error) {
-	configOnce.Do(func() {
-		configInstance = &TerminalConfig{}
-		v := viper.New()
-
-		setDefaultConfig(v)
-		v.SetConfigType("yaml")
-
-		// Explicit configuration file (highest priority)
-		if configPath != "" {
-			v.SetConfigFile(configPath)
-		} else {
-			v.SetConfigName("pty-config")
-			// Path Search - Current directory
-			v.AddConfigPath(".")
-			// e.g. ~/.config/<kariuki>
-			if userConfigDir, err := os.UserConfigDir(); err == nil {
-				appConfigDir := filepath.Join(userConfigDir, kariuki)
-				v.AddConfigPath(appConfigDir)
-			}
-			v.AddConfigPath("/etc/" + kariuki) // Global config
-		}
+	cfg, err := readConfig(configPath, kariuki)
 
-		// Config System
-		v.SetEnvPrefix("PTY") // PTY_ prefix for all variables
-		v.AutomaticEnv()      // Bind all environment variables automatically
-
-		v.BindEnv("bg_color", "PTY_BACKGROUND_COLOR")
-		v.BindEnv("text_color", "PTY_TEXT_COLOR")
-		v.BindEnv("inactivity_close", "PTY_SESSION_TIMEOUT")
-
-		// Load and error handling
-		if err := v.ReadInConfig(); err != nil {
-			// Check if the error is just "file not found"
-			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				fmt.Println("Config: Using default settings (file not found)")
-			} else {
-				configErr = fmt.Errorf("error reading configuration file: %w", err)
-				return
-			}
-		}
+	configMutex.Lock()
+	configInstance, configErr = cfg, err
+	startWatcher := err == nil && configPath != "" && !watchedPaths[configPath]
+	if startWatcher {
+		watchedPaths[configPath] = true
+	}
+	configMutex.Unlock()
 
-		// Create a custom decoder
-		decoderConfig := &mapstructure.DecoderConfig{
-			Result: configInstance,
-			DecodeHook: mapstructure.ComposeDecodeHookFunc(
-				mapstructure.StringToTimeDurationHookFunc(),
-				mapstructure.StringToSliceHookFunc(","),
-			),
-			// We'll handle unused keys manually
-			ErrorUnused: false,
+	// Start monitoring file changes
+	if startWatcher {
+		go watchConfigFile(configPath, kariuki)
+	}
+	return cfg, err
+}
+
+// readConfig builds a new TerminalConfig from defaults, the config file and
+// the environment.
+func readConfig(configPath, kariuki string) (*TerminalConfig, error) {
+	cfg := &TerminalConfig{}
+	v := viper.New()
+
+	setDefaultConfig(v)
+	v.SetConfigType("yaml")
+
+	// Explicit configuration file (highest priority)
+	if configPath != "" {
+		v.SetConfigFile(configPath)
+	} else {
+		v.SetConfigName("pty-config")
+		// Path Search - Current directory
+		v.AddConfigPath(".")
+		// e.g. ~/.config/<kariuki>
+		if userConfigDir, err := os.UserConfigDir(); err == nil {
+			appConfigDir := filepath.Join(userConfigDir, kariuki)
+			v.AddConfigPath(appConfigDir)
 		}
+		v.AddConfigPath("/etc/" + kariuki) // Global config
+	}
 
-		decoder, err := mapstructure.NewDecoder(decoderConfig)
-		if err != nil {
-			configErr = fmt.Errorf("failed to create config decoder: %w", err)
-			return
+	// Config System
+	v.SetEnvPrefix("PTY") // PTY_ prefix for all variables
+	v.AutomaticEnv()      // Bind all environment variables automatically
+
+	v.BindEnv("bg_color", "PTY_BACKGROUND_COLOR")
+	v.BindEnv("text_color", "PTY_TEXT_COLOR")
+	v.BindEnv("inactivity_close", "PTY_SESSION_TIMEOUT")
+
+	// Load and error handling
+	if err := v.ReadInConfig(); err != nil {
+		// Check if the error is just "file not found"
+		// The default settings are used without a file; this runs during a
+		// session too, so it prints nothing.
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return cfg, fmt.Errorf("error reading configuration file: %w", err)
 		}
+	}
 
-		// Get all settings and handle legacy keys
-		settings := v.AllSettings()
-		handleLegacyKeys(settings)
+	// Create a custom decoder
+	decoderConfig := &mapstructure.DecoderConfig{
+		Result: cfg,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		// We'll handle unused keys manually
+		ErrorUnused: false,
+	}
 
-		// Decode using custom decoder
-		if err := decoder.Decode(settings); err != nil {
-			configErr = fmt.Errorf("failed to decode config: %w", err)
-			return
-		}
+	decoder, err := mapstructure.NewDecoder(decoderConfig)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to create config decoder: %w", err)
+	}
 
-		// Validate configuration keys
-		if err := validateConfigKeys(v, settings); err != nil {
-			configErr = err
-			return
-		}
+	// Get all settings and handle legacy keys
+	settings := v.AllSettings()
+	handleLegacyKeys(settings)
 
-		// Process settings
-		configMutex.Lock()
-		configInstance.postProcessConfig()
-		configMutex.Unlock()
+	// Decode using custom decoder
+	if err := decoder.Decode(settings); err != nil {
+		return cfg, fmt.Errorf("failed to decode config: %w", err)
+	}
 
-		// Start monitoring file changes
-		if configPath != "" {
-			go watchConfigFile(configPath, kariuki)
-		}
-	})
-	return configInstance, configErr
+	// Validate configuration keys
+	if err := validateConfigKeys(v, settings); err != nil {
+		return cfg, err
+	}
+
+	// Process settings
+	cfg.postProcessConfig()
+
+	if err := cfg.validateColors(); err != nil {
+		return cfg, err
+	}
+	if _, err := charset.Lookup(cfg.Encoding); err != nil {
+		return cfg, fmt.Errorf("encoding: %w", err)
+	}
+	if _, err := clipboard.ParseMode(cfg.Clipboard); err != nil {
+		return cfg, fmt.Errorf("clipboard: %w", err)
+	}
+	if _, _, err := bell.ParseMode(cfg.BellSound); err != nil {
+		return cfg, fmt.Errorf("bell_sound: %w", err)
+	}
+	if _, err := ParseKey(cfg.PrefixKey); err != nil {
+		return cfg, fmt.Errorf("prefix_key: %w", err)
+	}
+	if _, err := export.ParseFormat(cfg.ExportFormat); err != nil {
+		return cfg, fmt.Errorf("export_format: %w", err)
+	}
+	return cfg, nil
 }
 
 // setDefaultConfig sets the default values for all configurations
@@ -159,12 +221,28 @@ func setDefaultConfig(v *viper.Viper) {
 	v.SetDefault("type_ahead", true)
 	v.SetDefault("auto_suggest", true)
 	v.SetDefault("inactivity_close", time.Hour)
+	v.SetDefault("record_sessions", false)
+	v.SetDefault("prefix_key", "C-]")
+	v.SetDefault("export_format", "html")
+	v.SetDefault("term", "kariuki")
+	v.SetDefault("multiplexer", false)
+	v.SetDefault("shell_prompt", true)
+	v.SetDefault("daemon_socket", "")
+	v.SetDefault("control_socket", "")
+	v.SetDefault("web_address", "127.0.0.1:7680")
+	v.SetDefault("ssh_address", "127.0.0.1:2222")
+	v.SetDefault("ssh_host_key", "")
+	v.SetDefault("ssh_authorized_keys", "")
 
 	v.SetDefault("max_session_time", 8*time.Hour)
 	v.SetDefault("allowed_commands", []string{})
 	v.SetDefault("blocked_commands", []string{"rm -rf /", "dd if=/dev/random"})
 
 	v.SetDefault("enable_logging", false)
+	v.SetDefault("audit_log", "")
+	v.SetDefault("share_address", "")
+	v.SetDefault("clipboard", "write-only")
+	v.SetDefault("clipboard_max_size", clipboard.DefaultMaxSize)
 
 	v.SetDefault("rows", 24)
 	v.SetDefault("cols", 80)
@@ -174,23 +252,22 @@ func setDefaultConfig(v *viper.Viper) {
 	v.SetDefault("enable_mouse", true)
 }
 
-// watchConfigFile monitors configuration file changes
+// watchConfigFile monitors configuration file changes. Sessions may have
+// the host terminal in raw mode, so only errors are reported, on stderr.
 func watchConfigFile(configPath, kariuki string) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		fmt.Printf("Error creating configuration watcher: %v\n", err)
+		fmt.Fprintf(os.Stderr, "kariuki: error creating configuration watcher: %v\r\n", err)
 		return
 	}
 	defer watcher.Close()
 
 	dir := filepath.Dir(configPath)
 	if err := watcher.Add(dir); err != nil {
-		fmt.Printf("Error adding directory to watcher: %v\n", err)
+		fmt.Fprintf(os.Stderr, "kariuki: error adding directory to watcher: %v\r\n", err)
 		return
 	}
 
-	fmt.Printf("Watching directory: %s\n", configPath)
-
 	// Process watcher events.
 	for {
 		select {
@@ -199,11 +276,8 @@ func watchConfigFile(configPath, kariuki string) {
 				return
 			}
 			if event.Name == configPath && event.Op&fsnotify.Write == fsnotify.Write {
-				fmt.Println("Configuration file changed, reloading...")
 				if err := ReloadConfig(configPath, kariuki); err != nil {
-					fmt.Printf("Error reloading configuration: %v\n", err)
-				} else {
-					fmt.Println("Configuration reloaded successfully")
+					fmt.Fprintf(os.Stderr, "kariuki: error reloading configuration: %v\r\n", err)
 				}
 			}
 		// If an error occurs in the watcher
@@ -212,7 +286,7 @@ func watchConfigFile(configPath, kariuki string) {
 				// Close channel and goruntine stop
 				return
 			}
-			fmt.Printf("Error watching configuration file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "kariuki: error watching configuration file: %v\r\n", err)
 		}
 	}
 }
@@ -283,6 +357,14 @@ func (c *TerminalConfig) postProcessConfig() {
 	if c.Cols < 40 {
 		c.Cols = 40
 	}
+	// 0 disables the scrollback.
+	if c.ScrollBuffer < 0 {
+		c.ScrollBuffer = 0
+	}
+
+	if c.ClipboardMaxSize <= 0 {
+		c.ClipboardMaxSize = clipboard.DefaultMaxSize
+	}
 
 	// Ex.: ".kariuki_history" -> "/home/user/.kariuki_history".
 	if c.HistoryFile != "" && !filepath.IsAbs(c.HistoryFile) {
@@ -316,6 +398,139 @@ func (c *TerminalConfig) postProcessConfig() {
 	}
 }
 
+// validateColors checks that bg_color and text_color are colors we can parse.
+func (c *TerminalConfig) validateColors() error {
+	if _, err := color.Parse(c.BgColor); err != nil {
+		return fmt.Errorf("bg_color: %w", err)
+	}
+	if _, err := color.Parse(c.TextColor); err != nil {
+		return fmt.Errorf("text_color: %w", err)
+	}
+	return nil
+}
+
+// Background returns BgColor parsed, or the terminal default if invalid.
+func (c *TerminalConfig) Background() color.Color {
+	bg, _ := color.Parse(c.BgColor)
+	return bg
+}
+
+// Foreground returns TextColor parsed, or the terminal default if invalid.
+func (c *TerminalConfig) Foreground() color.Color {
+	fg, _ := color.Parse(c.TextColor)
+	return fg
+}
+
+// ClipboardPolicy returns the OSC 52 settings; an invalid Clipboard
+// setting means write-only.
+func (c *TerminalConfig) ClipboardPolicy() clipboard.Policy {
+	mode, _ := clipboard.ParseMode(c.Clipboard)
+	return clipboard.Policy{Mode: mode, MaxSize: c.ClipboardMaxSize}
+}
+
+// CommandPolicy returns the command lines restricted sessions may run.
+func (c *TerminalConfig) CommandPolicy() policy.Policy {
+	return policy.Policy{Allowed: c.AllowedCommands, Blocked: c.BlockedCommands}
+}
+
+// RecordingPath returns where a session started at t is recorded: the
+// directory of HistoryFile, e.g. ~/kariuki-20250102-150405.cast.
+func (c *TerminalConfig) RecordingPath(kariuki string, t time.Time) string {
+	dir := "."
+	if c.HistoryFile != "" {
+		dir = filepath.Dir(c.HistoryFile)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s-%s.cast", kariuki, t.Format("20060102-150405")))
+}
+
+// Prefix returns the byte of PrefixKey; an invalid setting means C-].
+func (c *TerminalConfig) Prefix() byte {
+	key, err := ParseKey(c.PrefixKey)
+	if err != nil {
+		return 0x1d
+	}
+	return key
+}
+
+// Export returns the ExportFormat; an invalid setting means HTML.
+func (c *TerminalConfig) Export() export.Format {
+	f, err := export.ParseFormat(c.ExportFormat)
+	if err != nil {
+		return export.HTML
+	}
+	return f
+}
+
+// ExportPath returns where scrollback exported at t is written, next to
+// the recordings, e.g. ~/kariuki-20250102-150405.html.
+func (c *TerminalConfig) ExportPath(kariuki string, f export.Format, t time.Time) string {
+	path := c.RecordingPath(kariuki, t)
+	return strings.TrimSuffix(path, ".cast") + f.Extension()
+}
+
+// SocketPath returns DaemonSocket, by default daemon.sock in a directory
+// of the user: $XDG_RUNTIME_DIR/kariuki, or /tmp/kariuki-<uid>.
+func (c *TerminalConfig) SocketPath(kariuki string) string {
+	if c.DaemonSocket != "" {
+		return c.DaemonSocket
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, kariuki, "daemon.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", kariuki, os.Getuid()), "daemon.sock")
+}
+
+// ControlSocketPath returns ControlSocket, by default control.sock next
+// to the daemon socket at daemonSocket.
+func (c *TerminalConfig) ControlSocketPath(daemonSocket string) string {
+	if c.ControlSocket != "" {
+		return c.ControlSocket
+	}
+	return filepath.Join(filepath.Dir(daemonSocket), "control.sock")
+}
+
+// AuditLogPath returns AuditLog, by default kariuki-audit.log in the
+// directory of HistoryFile.
+func (c *TerminalConfig) AuditLogPath(kariuki string) string {
+	if c.AuditLog != "" {
+		return c.AuditLog
+	}
+	dir := "."
+	if c.HistoryFile != "" {
+		dir = filepath.Dir(c.HistoryFile)
+	}
+	return filepath.Join(dir, kariuki+"-audit.log")
+}
+
+// SSHHostKeyPath returns SSHHostKey, by default ssh_host_ed25519_key in
+// the user's configuration directory, e.g. ~/.config/kariuki.
+func (c *TerminalConfig) SSHHostKeyPath(kariuki string) string {
+	if c.SSHHostKey != "" {
+		return c.SSHHostKey
+	}
+	return filepath.Join(userConfigDir(kariuki), "ssh_host_ed25519_key")
+}
+
+// SSHAuthorizedKeysPath returns SSHAuthorized, by default authorized_keys
+// in the user's configuration directory: the keys of `kariuki ssh` are
+// kept apart from those of the account.
+func (c *TerminalConfig) SSHAuthorizedKeysPath(kariuki string) string {
+	if c.SSHAuthorized != "" {
+		return c.SSHAuthorized
+	}
+	return filepath.Join(userConfigDir(kariuki), "authorized_keys")
+}
+
+// userConfigDir returns the configuration directory of kariuki, or the
+// current directory if the user has none.
+func userConfigDir(kariuki string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(dir, kariuki)
+}
+
 func GetConfig() (*TerminalConfig, error) {
 	configMutex.RLock()
 	defer configMutex.RUnlock()
@@ -326,15 +541,37 @@ func GetConfig() (*TerminalConfig, error) {
 	return configInstance, configErr
 }
 
+// ReloadConfig loads the configuration again and passes it to the OnReload
+// hooks, which run without configMutex held so they may call GetConfig.
 func ReloadConfig(configPath, kariuki string) error {
-	configMutex.Lock()
-	defer configMutex.Unlock()
+	cfg, err := LoadConfig(configPath, kariuki)
+	if err != nil {
+		return err
+	}
 
-	configOnce = sync.Once{}
-	configInstance = nil
+	configMutex.RLock()
+	hooks := append([]*reloadHook{}, reloadHooks...)
+	configMutex.RUnlock()
+
+	for _, hook := range hooks {
+		hook.fn(cfg)
+	}
+	return nil
+}
 
-	_, err := LoadConfig(configPath, kariuki)
-	return err
+// OnReload registers a function called with the new configuration after
+// every successful reload, so running sessions can apply the changes.
+// Calling the returned function removes it.
+func OnReload(fn func(*TerminalConfig)) (remove func()) {
+	hook := &reloadHook{fn: fn}
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	reloadHooks = append(reloadHooks, hook)
+	return func() {
+		configMutex.Lock()
+		defer configMutex.Unlock()
+		reloadHooks = slices.DeleteFunc(reloadHooks, func(h *reloadHook) bool { return h == hook })
+	}
 }
 
 // Handle legacy keys by mapping them to new keys
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/clipboard"
+	"github.com/FelipePn10/kariuki/pkg/export"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -16,6 +18,7 @@ func TestLoadConfig(t *testing.T) {
 		require.NoError(t, err)
 
 		assert.Equal(t, "> ", cfg.Prompt)
+		assert.True(t, cfg.ShellPrompt)
 		assert.Equal(t, "black", cfg.BgColor)
 		assert.Equal(t, 1000, cfg.HistorySize)
 		assert.Equal(t, []string{"rm -rf /", "dd if=/dev/random"}, cfg.BlockedCommands)
@@ -42,6 +45,53 @@ allowed_commands: ["ls", "pwd"]
 		assert.Equal(t, []string{"ls", "pwd"}, cfg.AllowedCommands)
 	})
 
+	t.Run("Invalid color", func(t *testing.T) {
+		dir := t.TempDir()
+		cfgPath := filepath.Join(dir, "colors.yaml")
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("text_color: \"#12345\""), 0644))
+
+		_, err := terminal.LoadConfig(cfgPath, "testapp")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "text_color")
+	})
+
+	t.Run("Clipboard policy", func(t *testing.T) {
+		dir := t.TempDir()
+		cfgPath := filepath.Join(dir, "clipboard.yaml")
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("rows: 24"), 0644))
+		cfg, err := terminal.LoadConfig(cfgPath, "testapp")
+		require.NoError(t, err)
+		assert.Equal(t, clipboard.Policy{Mode: clipboard.WriteOnly, MaxSize: clipboard.DefaultMaxSize}, cfg.ClipboardPolicy())
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("clipboard: read-write\nclipboard_max_size: 4096"), 0644))
+		cfg, err = terminal.LoadConfig(cfgPath, "testapp")
+		require.NoError(t, err)
+		assert.Equal(t, clipboard.Policy{Mode: clipboard.ReadWrite, MaxSize: 4096}, cfg.ClipboardPolicy())
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("clipboard: always"), 0644))
+		_, err = terminal.LoadConfig(cfgPath, "testapp")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "clipboard")
+	})
+
+	t.Run("Key bindings and export", func(t *testing.T) {
+		dir := t.TempDir()
+		cfgPath := filepath.Join(dir, "keys.yaml")
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("prefix_key: C-a\nexport_format: ansi"), 0644))
+		cfg, err := terminal.LoadConfig(cfgPath, "testapp")
+		require.NoError(t, err)
+		assert.Equal(t, byte(0x01), cfg.Prefix())
+		assert.Equal(t, export.ANSI, cfg.Export())
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("export_format: pdf"), 0644))
+		_, err = terminal.LoadConfig(cfgPath, "testapp")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "export_format")
+	})
+
 	t.Run("Environment override", func(t *testing.T) {
 		t.Setenv("PTY_BACKGROUND_COLOR", "green")
 		t.Setenv("PTY_TEXT_COLOR", "yellow")
@@ -74,4 +124,42 @@ allowed_commands: ["ls", "pwd"]
 		cfg, _ = terminal.GetConfig()
 		assert.Equal(t, "V2 $", cfg.Prompt)
 	})
+
+	t.Run("Reload hook", func(t *testing.T) {
+		dir := t.TempDir()
+		cfgPath := filepath.Join(dir, "hook.yaml")
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("scroll_buffer: 200"), 0644))
+		cfg, err := terminal.LoadConfig(cfgPath, "testapp")
+		require.NoError(t, err)
+		assert.Equal(t, 200, cfg.ScrollBuffer)
+
+		var reloaded *terminal.TerminalConfig
+		remove := terminal.OnReload(func(c *terminal.TerminalConfig) { reloaded = c })
+		defer remove()
+
+		require.NoError(t, os.WriteFile(cfgPath, []byte("scroll_buffer: 50"), 0644))
+		require.NoError(t, terminal.ReloadConfig(cfgPath, "testapp"))
+
+		require.NotNil(t, reloaded)
+		assert.Equal(t, 50, reloaded.ScrollBuffer)
+
+		// A removed hook is not called again.
+		remove()
+		require.NoError(t, os.WriteFile(cfgPath, []byte("scroll_buffer: 60"), 0644))
+		require.NoError(t, terminal.ReloadConfig(cfgPath, "testapp"))
+		assert.Equal(t, 50, reloaded.ScrollBuffer)
+	})
+}
+
+func TestParseKey(t *testing.T) {
+	for name, want := range map[string]byte{"C-a": 0x01, "C-b": 0x02, "C-]": 0x1d, "C-@": 0x00, "C-_": 0x1f} {
+		key, err := terminal.ParseKey(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, key, name)
+	}
+	for _, name := range []string{"", "a", "C-", "C-ab", "C-[", "M-a"} {
+		_, err := terminal.ParseKey(name)
+		assert.Error(t, err, name)
+	}
 }
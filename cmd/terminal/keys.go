@@ -0,0 +1,24 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseKey converts a control key written as "C-a" ... "C-z", "C-@",
+// "C-\", "C-]", "C-^" or "C-_" to the byte the terminal sends. C-[ is Esc,
+// which starts escape sequences, so it cannot be used.
+func ParseKey(name string) (byte, error) {
+	key, ok := strings.CutPrefix(strings.TrimSpace(name), "C-")
+	if !ok || len(key) != 1 {
+		return 0, fmt.Errorf("invalid key %q (want C- and a letter, e.g. C-a)", name)
+	}
+	c := key[0]
+	switch {
+	case c >= 'a' && c <= 'z':
+		return c - 'a' + 1, nil
+	case c >= '@' && c <= '_' && c != '[':
+		return c - '@', nil
+	}
+	return 0, fmt.Errorf("invalid key %q (want C- and a letter, e.g. C-a)", name)
+}
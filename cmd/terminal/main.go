@@ -1 +1,311 @@
 package terminal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// AppName is used to look up the configuration directory (~/.config/kariuki, /etc/kariuki).
+const AppName = "kariuki"
+
+// Options holds the command-line arguments of the kariuki binary.
+type Options struct {
+	ConfigPath string   // Explicit configuration file
+	Record     bool     // Record the session, as with record_sessions
+	Mux        bool     // Run sessions in tabs and panes, as with multiplexer
+	Command    []string // Program started inside the PTY (defaults to the user's shell)
+}
+
+// PlayOptions holds the arguments of `kariuki play`.
+type PlayOptions struct {
+	Path      string
+	Speed     float64
+	IdleLimit time.Duration
+}
+
+// DaemonOptions holds the arguments of `kariuki daemon`.
+type DaemonOptions struct {
+	ConfigPath string
+	Socket     string // Instead of the configured socket
+}
+
+// AttachOptions holds the arguments of `kariuki attach`.
+type AttachOptions struct {
+	ConfigPath string
+	Socket     string   // Instead of the configured socket
+	Name       string   // Session to attach to
+	List       bool     // List the sessions instead
+	Command    []string // Program of a new session (defaults to the user's shell)
+}
+
+// ShareOptions holds the arguments of `kariuki share`. Without Stop, Grant
+// or Revoke the session is shared, or its viewers listed if it already is.
+type ShareOptions struct {
+	ConfigPath string
+	Socket     string // Instead of the configured socket
+	Name       string // Session to share
+	Stop       bool   // Stop sharing and disconnect the viewers
+	Grant      int    // Viewer allowed to type
+	Revoke     int    // Viewer made read-only again
+}
+
+// JoinOptions holds the arguments of `kariuki join`.
+type JoinOptions struct {
+	ConfigPath string
+	Socket     string // Daemon socket to join through
+	Address    string // Share listener to join through instead, as with share_address
+	Name       string // Session to watch
+	Token      string // Given by the owner
+	As         string // Who joins, when the daemon can not tell
+}
+
+// WebOptions holds the arguments of `kariuki web`.
+type WebOptions struct {
+	ConfigPath string
+	Address    string   // Instead of web_address
+	Token      string   // Needed by browsers; random when empty
+	Command    []string // Program of each session (defaults to the user's shell)
+}
+
+// SSHOptions holds the arguments of `kariuki ssh`.
+type SSHOptions struct {
+	ConfigPath     string
+	Address        string   // Instead of ssh_address
+	HostKey        string   // Instead of ssh_host_key
+	AuthorizedKeys string   // Instead of ssh_authorized_keys
+	Command        []string // Program of shell sessions (defaults to the user's shell)
+}
+
+// ParseArgs parses the command line (without the program name).
+// Everything after the flags is the command to run, e.g. `kariuki -config c.yaml -- htop -d 5`.
+func ParseArgs(args []string, output io.Writer) (*Options, error) {
+	opts := &Options{}
+
+	fs := flag.NewFlagSet(AppName, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the configuration file")
+	fs.BoolVar(&opts.Record, "record", false, "record the session to an asciicast file next to the history file")
+	fs.BoolVar(&opts.Mux, "mux", false, "run sessions in tabs and split panes")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s [-config file] [-record] [-mux] [command [args...]]\n", AppName)
+		fmt.Fprintf(output, "       %s play [-speed n] [-idle duration] file.cast\n", AppName)
+		fmt.Fprintf(output, "       %s daemon [-config file] [-socket path]\n", AppName)
+		fmt.Fprintf(output, "       %s attach [-config file] [-socket path] [-name session] [-list] [command [args...]]\n", AppName)
+		fmt.Fprintf(output, "       %s share [-config file] [-socket path] [-name session] [-stop | -grant id | -revoke id]\n", AppName)
+		fmt.Fprintf(output, "       %s join [-config file] [-socket path | -address addr] [-name session] [-as name] -token token\n", AppName)
+		fmt.Fprintf(output, "       %s web [-config file] [-address host:port] [-token token] [command [args...]]\n", AppName)
+		fmt.Fprintf(output, "       %s ssh [-config file] [-address host:port] [-host-key file] [-authorized-keys file] [command [args...]]\n", AppName)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.Command = fs.Args()
+	if len(opts.Command) == 0 {
+		opts.Command = []string{DefaultShell()}
+	}
+	return opts, nil
+}
+
+// ParsePlayArgs parses the arguments of the play subcommand.
+func ParsePlayArgs(args []string, output io.Writer) (*PlayOptions, error) {
+	opts := &PlayOptions{}
+
+	fs := flag.NewFlagSet(AppName+" play", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Float64Var(&opts.Speed, "speed", 1, "playback speed, e.g. 2 for twice as fast")
+	fs.DurationVar(&opts.IdleLimit, "idle", 0, "longest pause between events, e.g. 2s (default: from the recording)")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s play [-speed n] [-idle duration] file.cast\n", AppName)
+		fmt.Fprintln(output, "Keys: space pauses and resumes, q quits.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, errors.New("play needs one recording")
+	}
+	if opts.Speed <= 0 {
+		return nil, fmt.Errorf("invalid speed %v", opts.Speed)
+	}
+	opts.Path = fs.Arg(0)
+	return opts, nil
+}
+
+// ParseDaemonArgs parses the arguments of the daemon subcommand.
+func ParseDaemonArgs(args []string, output io.Writer) (*DaemonOptions, error) {
+	opts := &DaemonOptions{}
+
+	fs := flag.NewFlagSet(AppName+" daemon", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the configuration file")
+	fs.StringVar(&opts.Socket, "socket", "", "path of the socket (default: daemon_socket)")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s daemon [-config file] [-socket path]\n", AppName)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return nil, errors.New("daemon takes no arguments")
+	}
+	return opts, nil
+}
+
+// ParseAttachArgs parses the arguments of the attach subcommand.
+func ParseAttachArgs(args []string, output io.Writer) (*AttachOptions, error) {
+	opts := &AttachOptions{}
+
+	fs := flag.NewFlagSet(AppName+" attach", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the configuration file")
+	fs.StringVar(&opts.Socket, "socket", "", "path of the daemon socket (default: daemon_socket)")
+	fs.StringVar(&opts.Name, "name", "main", "session to attach to, started with command if there is none")
+	fs.BoolVar(&opts.List, "list", false, "list the sessions of the daemon")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s attach [-config file] [-socket path] [-name session] [-list] [command [args...]]\n", AppName)
+		fmt.Fprintln(output, "The prefix key followed by d detaches.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.Command = fs.Args()
+	return opts, nil
+}
+
+// ParseShareArgs parses the arguments of the share subcommand.
+func ParseShareArgs(args []string, output io.Writer) (*ShareOptions, error) {
+	opts := &ShareOptions{}
+
+	fs := flag.NewFlagSet(AppName+" share", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the configuration file")
+	fs.StringVar(&opts.Socket, "socket", "", "path of the daemon socket (default: daemon_socket)")
+	fs.StringVar(&opts.Name, "name", "main", "session to share")
+	fs.BoolVar(&opts.Stop, "stop", false, "stop sharing and disconnect the viewers")
+	fs.IntVar(&opts.Grant, "grant", 0, "let the viewer with this id type")
+	fs.IntVar(&opts.Revoke, "revoke", 0, "make the viewer with this id read-only again")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s share [-config file] [-socket path] [-name session] [-stop | -grant id | -revoke id]\n", AppName)
+		fmt.Fprintln(output, "Without -stop, -grant or -revoke the session is shared and its viewers listed.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return nil, errors.New("share takes no arguments")
+	}
+	actions := 0
+	for _, set := range []bool{opts.Stop, opts.Grant != 0, opts.Revoke != 0} {
+		if set {
+			actions++
+		}
+	}
+	if actions > 1 {
+		return nil, errors.New("-stop, -grant and -revoke can not be combined")
+	}
+	return opts, nil
+}
+
+// ParseJoinArgs parses the arguments of the join subcommand.
+func ParseJoinArgs(args []string, output io.Writer) (*JoinOptions, error) {
+	opts := &JoinOptions{}
+
+	fs := flag.NewFlagSet(AppName+" join", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the configuration file")
+	fs.StringVar(&opts.Socket, "socket", "", "path of the daemon socket (default: daemon_socket)")
+	fs.StringVar(&opts.Address, "address", "", "share listener of the daemon (default: share_address)")
+	fs.StringVar(&opts.Name, "name", "main", "session to watch")
+	fs.StringVar(&opts.As, "as", os.Getenv("USER"), "who joins, as shown to the owner and in the audit log")
+	fs.StringVar(&opts.Token, "token", "", "token given by the owner of the session")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s join [-config file] [-socket path | -address addr] [-name session] [-as name] -token token\n", AppName)
+		fmt.Fprintln(output, "The prefix key followed by d leaves.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return nil, errors.New("join takes no arguments")
+	}
+	if opts.Token == "" {
+		return nil, errors.New("join needs the token of the session")
+	}
+	if opts.Socket != "" && opts.Address != "" {
+		return nil, errors.New("-socket and -address can not be combined")
+	}
+	return opts, nil
+}
+
+// ParseWebArgs parses the arguments of the web subcommand.
+func ParseWebArgs(args []string, output io.Writer) (*WebOptions, error) {
+	opts := &WebOptions{}
+
+	fs := flag.NewFlagSet(AppName+" web", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the configuration file")
+	fs.StringVar(&opts.Address, "address", "", "address to listen on (default: web_address)")
+	fs.StringVar(&opts.Token, "token", "", "token browsers need (default: a random one, printed at start)")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s web [-config file] [-address host:port] [-token token] [command [args...]]\n", AppName)
+		fmt.Fprintln(output, "Every browser connection runs command in a session of its own.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.Command = fs.Args()
+	return opts, nil
+}
+
+// ParseSSHArgs parses the arguments of the ssh subcommand.
+func ParseSSHArgs(args []string, output io.Writer) (*SSHOptions, error) {
+	opts := &SSHOptions{}
+
+	fs := flag.NewFlagSet(AppName+" ssh", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the configuration file")
+	fs.StringVar(&opts.Address, "address", "", "address to listen on (default: ssh_address)")
+	fs.StringVar(&opts.HostKey, "host-key", "", "private host key, created if missing (default: ssh_host_key)")
+	fs.StringVar(&opts.AuthorizedKeys, "authorized-keys", "", "keys that may log in (default: ssh_authorized_keys)")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s ssh [-config file] [-address host:port] [-host-key file] [-authorized-keys file] [command [args...]]\n", AppName)
+		fmt.Fprintln(output, "Every SSH session runs command, or the command the client asks for, under the command policy.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.Command = fs.Args()
+	return opts, nil
+}
+
+// DefaultShell returns the user's login shell, falling back to /bin/sh.
+func DefaultShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
@@ -0,0 +1,100 @@
+package terminal_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/export"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArgs(t *testing.T) {
+	opts, err := terminal.ParseArgs([]string{"-record", "--", "htop", "-d", "5"}, io.Discard)
+	require.NoError(t, err)
+	assert.True(t, opts.Record)
+	assert.Equal(t, []string{"htop", "-d", "5"}, opts.Command)
+	assert.False(t, opts.Mux)
+
+	opts, err = terminal.ParseArgs([]string{"-mux"}, io.Discard)
+	require.NoError(t, err)
+	assert.True(t, opts.Mux)
+
+	play, err := terminal.ParsePlayArgs([]string{"-speed", "2", "-idle", "1s", "a.cast"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &terminal.PlayOptions{Path: "a.cast", Speed: 2, IdleLimit: time.Second}, play)
+
+	_, err = terminal.ParsePlayArgs(nil, io.Discard)
+	assert.Error(t, err)
+
+	attach, err := terminal.ParseAttachArgs([]string{"-name", "work", "htop"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &terminal.AttachOptions{Name: "work", Command: []string{"htop"}}, attach)
+
+	_, err = terminal.ParseDaemonArgs([]string{"extra"}, io.Discard)
+	assert.Error(t, err)
+
+	share, err := terminal.ParseShareArgs([]string{"-grant", "2"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &terminal.ShareOptions{Name: "main", Grant: 2}, share)
+	_, err = terminal.ParseShareArgs([]string{"-stop", "-revoke", "1"}, io.Discard)
+	assert.Error(t, err)
+
+	t.Setenv("USER", "ana")
+	join, err := terminal.ParseJoinArgs([]string{"-token", "abc", "-address", "127.0.0.1:7681"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &terminal.JoinOptions{Address: "127.0.0.1:7681", Name: "main", Token: "abc", As: "ana"}, join)
+	_, err = terminal.ParseJoinArgs(nil, io.Discard)
+	assert.Error(t, err, "no token")
+	_, err = terminal.ParseJoinArgs([]string{"-token", "abc", "-socket", "s", "-address", "a"}, io.Discard)
+	assert.Error(t, err)
+
+	web, err := terminal.ParseWebArgs([]string{"-address", ":8080", "htop"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &terminal.WebOptions{Address: ":8080", Command: []string{"htop"}}, web)
+
+	ssh, err := terminal.ParseSSHArgs([]string{"-authorized-keys", "keys", "-address", ":2022"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &terminal.SSHOptions{Address: ":2022", AuthorizedKeys: "keys", Command: []string{}}, ssh)
+}
+
+func TestRecordingPath(t *testing.T) {
+	cfg := &terminal.TerminalConfig{HistoryFile: "/home/ana/.pty_history"}
+	at := time.Date(2025, 1, 2, 15, 4, 5, 0, time.Local)
+	assert.Equal(t, "/home/ana/kariuki-20250102-150405.cast", cfg.RecordingPath("kariuki", at))
+	assert.Equal(t, "/home/ana/kariuki-20250102-150405.html", cfg.ExportPath("kariuki", export.HTML, at))
+}
+
+func TestAuditLogPath(t *testing.T) {
+	cfg := &terminal.TerminalConfig{HistoryFile: "/home/ana/.pty_history"}
+	assert.Equal(t, "/home/ana/kariuki-audit.log", cfg.AuditLogPath("kariuki"))
+
+	cfg.AuditLog = "/var/log/kariuki.log"
+	assert.Equal(t, "/var/log/kariuki.log", cfg.AuditLogPath("kariuki"))
+}
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	cfg := &terminal.TerminalConfig{}
+	assert.Equal(t, "/run/user/1000/kariuki/daemon.sock", cfg.SocketPath("kariuki"))
+
+	cfg.DaemonSocket = "/tmp/k.sock"
+	assert.Equal(t, "/tmp/k.sock", cfg.SocketPath("kariuki"))
+
+	assert.Equal(t, "/tmp/control.sock", cfg.ControlSocketPath("/tmp/k.sock"))
+	cfg.ControlSocket = "/run/kariuki/api.sock"
+	assert.Equal(t, "/run/kariuki/api.sock", cfg.ControlSocketPath("/tmp/k.sock"))
+}
+
+func TestSSHKeyPaths(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/home/ana/.config")
+	cfg := &terminal.TerminalConfig{}
+	assert.Equal(t, "/home/ana/.config/kariuki/ssh_host_ed25519_key", cfg.SSHHostKeyPath("kariuki"))
+	assert.Equal(t, "/home/ana/.config/kariuki/authorized_keys", cfg.SSHAuthorizedKeysPath("kariuki"))
+
+	cfg.SSHHostKey, cfg.SSHAuthorized = "/etc/kariuki/host_key", "/etc/kariuki/keys"
+	assert.Equal(t, "/etc/kariuki/host_key", cfg.SSHHostKeyPath("kariuki"))
+	assert.Equal(t, "/etc/kariuki/keys", cfg.SSHAuthorizedKeysPath("kariuki"))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/daemon"
+	"golang.org/x/term"
+)
+
+// daemonStartTime is how long attach waits for a daemon it started.
+const daemonStartTime = 3 * time.Second
+
+// runDaemon serves sessions on the socket: `kariuki daemon [-socket path]`.
+func runDaemon(args []string) int {
+	opts, err := terminal.ParseDaemonArgs(args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+	cfg, err := terminal.LoadConfig(opts.ConfigPath, terminal.AppName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	installTerminfo(cfg)
+	path := opts.Socket
+	if path == "" {
+		path = cfg.SocketPath(terminal.AppName)
+	}
+
+	l, err := daemon.Listen(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	srv := daemon.NewServer(cfg)
+	terminal.OnReload(srv.ApplyConfig)
+	if cfg.EnableLogging {
+		log, err := audit.Open(cfg.AuditLogPath(terminal.AppName))
+		if err != nil {
+			l.Close()
+			fmt.Fprintf(os.Stderr, "kariuki: failed to open the audit log: %v\n", err)
+			return 1
+		}
+		defer log.Close()
+		srv.SetAuditLog(log)
+	}
+	var shared net.Listener
+	if cfg.ShareAddress != "" {
+		if shared, err = daemon.ListenShared(cfg.ShareAddress); err != nil {
+			l.Close()
+			fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+			return 1
+		}
+		go srv.ServeShared(shared)
+	}
+	controlPath := cfg.ControlSocketPath(path)
+	control, err := daemon.Listen(controlPath)
+	if err != nil {
+		if shared != nil {
+			shared.Close()
+		}
+		l.Close()
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	go srv.ServeAPI(control)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-stop
+		if shared != nil {
+			shared.Close()
+		}
+		control.Close()
+		l.Close()
+	}()
+	if err := srv.Serve(l); err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	os.Remove(controlPath)
+	os.Remove(path)
+	return 0
+}
+
+// attach shows a session of the daemon, starting the daemon if it is not
+// running: `kariuki attach [-name session] [command...]`.
+func attach(args []string) int {
+	opts, err := terminal.ParseAttachArgs(args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+	cfg, err := terminal.LoadConfig(opts.ConfigPath, terminal.AppName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	path := opts.Socket
+	if path == "" {
+		path = cfg.SocketPath(terminal.AppName)
+	}
+
+	if opts.List {
+		return listSessions(path)
+	}
+	client, err := dialOrStart(path, opts.ConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	req := daemon.AttachRequest{
+		Name:    opts.Name,
+		Command: opts.Command,
+		Rows:    cfg.Rows,
+		Cols:    cfg.Cols,
+		Profile: color.DetectProfile(os.Getenv),
+	}
+	stdin := int(os.Stdin.Fd())
+	if term.IsTerminal(stdin) {
+		oldState, err := term.MakeRaw(stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kariuki: failed to set raw mode: %v\n", err)
+			return 1
+		}
+		defer term.Restore(stdin, oldState)
+
+		if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			req.Rows, req.Cols = rows, cols
+		}
+		stop := followHostSize(client)
+		defer stop()
+	}
+
+	result, err := client.Attach(req, os.Stdin, os.Stdout)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "\r\nkariuki: %v\r\n", err)
+		return 1
+	case result.Detached:
+		fmt.Fprintf(os.Stderr, "\r\n[detached from %s]\r\n", opts.Name)
+		return 0
+	}
+	return result.ExitCode
+}
+
+// listSessions prints the sessions of the daemon.
+func listSessions(path string) int {
+	client, err := daemon.Dial(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kariuki: no daemon is running")
+		return 1
+	}
+	defer client.Close()
+	sessions, err := client.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	for _, s := range sessions {
+		state := "detached"
+		if s.Attached {
+			state = "attached"
+		}
+		command := strings.Join(s.Command, " ")
+		if command == "" {
+			command = terminal.DefaultShell()
+		}
+		fmt.Printf("%s\t%s\tpid %d\tsince %s\t%s\n", s.Name, state, s.Pid, s.Started.Format(time.DateTime), command)
+	}
+	return 0
+}
+
+// dialOrStart connects to the daemon, starting it in the background first
+// if nobody listens on the socket.
+func dialOrStart(path, configPath string) (*daemon.Client, error) {
+	if client, err := daemon.Dial(path); err == nil {
+		return client, nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"daemon", "-socket", path}
+	if configPath != "" {
+		args = append(args, "-config", configPath)
+	}
+	cmd := exec.Command(self, args...)
+	// Its own session, so it outlives this terminal.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the daemon: %w", err)
+	}
+	go cmd.Wait()
+
+	deadline := time.Now().Add(daemonStartTime)
+	for {
+		client, err := daemon.Dial(path)
+		if err == nil {
+			return client, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("daemon did not start: %w", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
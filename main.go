@@ -1 +1,153 @@
 package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/asciicast"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/mux"
+	"github.com/FelipePn10/kariuki/pkg/session"
+	"github.com/FelipePn10/kariuki/pkg/terminfo"
+	"golang.org/x/term"
+)
+
+// app is what runs on the host terminal: a session, or several in a mux.
+type app interface {
+	resizer
+	SetColorProfile(color.Profile)
+	SetRecorder(*asciicast.Writer)
+	ApplyConfig(*terminal.TerminalConfig)
+	Run(in io.Reader, out io.Writer) error
+}
+
+// resizer follows the size of the host terminal.
+type resizer interface {
+	Resize(rows, cols int) error
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "play":
+			os.Exit(play(os.Args[2:]))
+		case "daemon":
+			os.Exit(runDaemon(os.Args[2:]))
+		case "attach":
+			os.Exit(attach(os.Args[2:]))
+		case "share":
+			os.Exit(share(os.Args[2:]))
+		case "join":
+			os.Exit(join(os.Args[2:]))
+		case "web":
+			os.Exit(serveWeb(os.Args[2:]))
+		case "ssh":
+			os.Exit(serveSSH(os.Args[2:]))
+		}
+	}
+	os.Exit(run())
+}
+
+// run starts the session and returns the process exit code.
+// It is separate from main so deferred cleanup runs before os.Exit.
+func run() int {
+	opts, err := terminal.ParseArgs(os.Args[1:], os.Stderr)
+	if err != nil {
+		return 2
+	}
+
+	cfg, err := terminal.LoadConfig(opts.ConfigPath, terminal.AppName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	installTerminfo(cfg)
+
+	var s app
+	if opts.Mux || cfg.Multiplexer {
+		s = mux.New(cfg, opts.Command)
+	} else {
+		single := session.NewSession(cfg, opts.Command)
+		if err := single.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+			return 1
+		}
+		defer single.Close()
+		s = single
+	}
+	s.SetColorProfile(color.DetectProfile(os.Getenv))
+	terminal.OnReload(s.ApplyConfig)
+
+	if opts.Record || cfg.RecordSessions {
+		stop, err := startRecording(s, cfg, opts.Command)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+			return 1
+		}
+		defer stop()
+	}
+
+	if cfg.WelcomeMessage != "" {
+		fmt.Println(cfg.WelcomeMessage)
+	}
+
+	// Raw mode hands every keystroke (Ctrl-C included) to the program in the PTY.
+	stdin := int(os.Stdin.Fd())
+	if term.IsTerminal(stdin) {
+		oldState, err := term.MakeRaw(stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kariuki: failed to set raw mode: %v\n", err)
+			return 1
+		}
+		defer term.Restore(stdin, oldState)
+
+		// Follow the host terminal size, starting now.
+		stop := followHostSize(s)
+		defer stop()
+	}
+
+	return session.ExitCode(s.Run(os.Stdin, os.Stdout))
+}
+
+// followHostSize resizes the session whenever the host terminal sends
+// SIGWINCH. The returned function stops watching.
+func followHostSize(s resizer) func() {
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	winch <- syscall.SIGWINCH // Initial sync
+
+	go func() {
+		for range winch {
+			cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+			if err != nil {
+				continue
+			}
+			s.Resize(rows, cols)
+		}
+	}()
+
+	return func() {
+		signal.Stop(winch)
+		close(winch)
+	}
+}
+
+// installTerminfo installs the terminfo entry of kariuki in ~/.terminfo
+// when the term setting asks for it and it is missing, as on the first
+// run. Programs get xterm-256color while it is not installed.
+func installTerminfo(cfg *terminal.TerminalConfig) {
+	if cfg.Term != terminfo.Name || terminfo.Installed(terminfo.Name) {
+		return
+	}
+	dir, err := terminfo.UserDir()
+	if err == nil {
+		err = terminfo.Install(dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: using TERM=%s, the terminfo entry could not be installed: %v\n", terminfo.Fallback, err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package asciicast reads and writes terminal recordings in the asciicast v2
+// format: a JSON header line followed by one JSON array per event,
+// [time, type, data], with time in seconds since the start.
+// See https://docs.asciinema.org/manual/asciicast/v2/.
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Event types.
+const (
+	Output = "o" // Data written to the terminal
+	Input  = "i" // Data typed by the user
+	Resize = "r" // New size, "COLSxROWS"
+)
+
+// Header is the first line of a recording.
+type Header struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// Event is one line after the header.
+type Event struct {
+	Time float64 // Seconds since the start of the recording
+	Type string
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	t := math.Round(e.Time*1e6) / 1e6
+	return json.Marshal([]any{t, e.Type, e.Data})
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("event has %d fields, want 3", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &e.Data)
+}
+
+// Writer records terminal output with timing. It is safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+	pending []byte // Incomplete UTF-8 sequence at the end of the last write
+	now     func() time.Time
+}
+
+// NewWriter writes the header and starts the clock.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	return newWriter(w, h, time.Now)
+}
+
+func newWriter(w io.Writer, h Header, now func() time.Time) (*Writer, error) {
+	start := now()
+	h.Version = 2
+	if h.Timestamp == 0 {
+		h.Timestamp = start.Unix()
+	}
+	line, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, start: start, now: now}, nil
+}
+
+// Write records p as output. Event data must be UTF-8 text, so a character
+// split across writes is kept until it is complete.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := append(w.pending, p...)
+	end := len(data)
+	for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				end = len(data) - i
+			}
+			break
+		}
+	}
+	w.pending = append([]byte(nil), data[end:]...)
+	if end == 0 {
+		return len(p), nil
+	}
+	if err := w.event(Output, string(data[:end])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteResize records a change of the terminal size.
+func (w *Writer) WriteResize(cols, rows int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.event(Resize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (w *Writer) event(kind, data string) error {
+	line, err := json.Marshal(Event{Time: w.now().Sub(w.start).Seconds(), Type: kind, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
+
+// Reader reads a recording.
+type Reader struct {
+	scanner *bufio.Scanner
+	header  Header
+}
+
+// NewReader reads and checks the header.
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("empty recording")
+	}
+	var h Header
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if h.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d", h.Version)
+	}
+	return &Reader{scanner: scanner, header: h}, nil
+}
+
+// Header returns the recording header.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the next event, or io.EOF at the end.
+func (r *Reader) Next() (Event, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return Event{}, fmt.Errorf("invalid event: %w", err)
+		}
+		return e, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
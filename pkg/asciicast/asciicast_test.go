@@ -0,0 +1,86 @@
+package asciicast
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+
+	var buf bytes.Buffer
+	w, err := newWriter(&buf, Header{Width: 80, Height: 24, Env: map[string]string{"TERM": "xterm-256color"}}, clock)
+	require.NoError(t, err)
+
+	now = now.Add(1500 * time.Millisecond)
+	w.Write([]byte("hi\r\n"))
+	e := []byte("é")
+	w.Write(e[:1]) // Held back until the character is complete
+	now = now.Add(time.Second)
+	w.Write(e[1:])
+	require.NoError(t, w.WriteResize(100, 30))
+
+	assert.Equal(t, `{"version":2,"width":80,"height":24,"timestamp":1700000000,"env":{"TERM":"xterm-256color"}}
+[1.5,"o","hi\r\n"]
+[2.5,"o","é"]
+[2.5,"r","100x30"]
+`, buf.String())
+}
+
+func TestReader(t *testing.T) {
+	r, err := NewReader(strings.NewReader("{\"version\":2,\"width\":10,\"height\":5}\n[0.25,\"o\",\"a\"]\n\n[1,\"r\",\"20x5\"]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 10, r.Header().Width)
+
+	e, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, Event{Time: 0.25, Type: Output, Data: "a"}, e)
+	e, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, Resize, e.Type)
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewReader(strings.NewReader(`{"version":1}`))
+	assert.Error(t, err)
+}
+
+func TestPlayer(t *testing.T) {
+	recording := "{\"version\":2,\"width\":10,\"height\":5}\n[0.01,\"o\",\"a\"]\n[100,\"o\",\"b\"]\n[100.02,\"r\",\"1x1\"]\n"
+
+	t.Run("Idle limit and speed", func(t *testing.T) {
+		r, err := NewReader(strings.NewReader(recording))
+		require.NoError(t, err)
+		var out bytes.Buffer
+		start := time.Now()
+		require.NoError(t, NewPlayer(2, 20*time.Millisecond).Play(r, &out))
+		assert.Equal(t, "ab", out.String())
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Pause and stop", func(t *testing.T) {
+		r, err := NewReader(strings.NewReader(recording))
+		require.NoError(t, err)
+		p := NewPlayer(1, 50*time.Millisecond)
+		p.TogglePause()
+		assert.True(t, p.Paused())
+
+		done := make(chan error)
+		go func() { done <- p.Play(r, io.Discard) }()
+		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-done:
+			t.Fatal("played while paused")
+		default:
+		}
+		p.Stop()
+		assert.Equal(t, ErrStopped, <-done)
+	})
+}
@@ -0,0 +1,122 @@
+package asciicast
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrStopped is returned by Play when Stop was called.
+var ErrStopped = errors.New("playback stopped")
+
+// Player replays recordings with speed control, pausing and a cap on
+// idle time.
+type Player struct {
+	Speed     float64       // 2 plays twice as fast; 0 means normal speed
+	IdleLimit time.Duration // Longest wait between events; 0 uses the header's idle_time_limit
+
+	mu      sync.Mutex
+	paused  bool
+	stopped bool
+	changed chan struct{} // Closed and replaced on pause, resume and stop
+}
+
+func NewPlayer(speed float64, idleLimit time.Duration) *Player {
+	return &Player{Speed: speed, IdleLimit: idleLimit, changed: make(chan struct{})}
+}
+
+// TogglePause pauses or resumes playback.
+func (p *Player) TogglePause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = !p.paused
+	p.signal()
+}
+
+// Paused reports whether playback is paused.
+func (p *Player) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Stop ends playback.
+func (p *Player) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	p.signal()
+}
+
+func (p *Player) signal() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Play writes the output events of r to w with their original timing.
+func (p *Player) Play(r *Reader, w io.Writer) error {
+	speed := p.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	idle := p.IdleLimit
+	if idle == 0 && r.Header().IdleTimeLimit > 0 {
+		idle = time.Duration(r.Header().IdleTimeLimit * float64(time.Second))
+	}
+
+	last := 0.0
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		delay := time.Duration((e.Time - last) * float64(time.Second))
+		last = e.Time
+		if idle > 0 && delay > idle {
+			delay = idle
+		}
+		if err := p.wait(time.Duration(float64(delay) / speed)); err != nil {
+			return err
+		}
+
+		if e.Type == Output {
+			if _, err := io.WriteString(w, e.Data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// wait sleeps for d of playing time; time spent paused does not count.
+func (p *Player) wait(d time.Duration) error {
+	for {
+		p.mu.Lock()
+		paused, stopped, changed := p.paused, p.stopped, p.changed
+		p.mu.Unlock()
+
+		switch {
+		case stopped:
+			return ErrStopped
+		case paused:
+			<-changed
+			continue
+		case d <= 0:
+			return nil
+		}
+
+		start := time.Now()
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+			return nil
+		case <-changed:
+			timer.Stop()
+			d -= time.Since(start)
+		}
+	}
+}
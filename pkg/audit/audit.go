@@ -0,0 +1,151 @@
+// Package audit writes a log of what happens in shared and remote
+// sessions: who joined, who was allowed to type, what they typed and which
+// commands were refused. Each line of the log is a JSON object.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Actions of events.
+const (
+	Share   = "share"   // The owner allowed viewers
+	Unshare = "unshare" // The owner stopped sharing
+	Join    = "join"
+	Refuse  = "refuse" // A join without the right token
+	Leave   = "leave"
+	Grant   = "grant"  // A viewer may type
+	Revoke  = "revoke" // A viewer may no longer type
+	Input   = "input"
+	Start   = "start" // A session of a remote user started; the text is its command
+	End     = "end"
+	Deny    = "deny" // The policy refused a command line, the text
+)
+
+// maxLine is the most typed text kept back waiting for the end of a line.
+const maxLine = 1024
+
+// Event is one line of the log.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Session string    `json:"session"`
+	User    string    `json:"user"`             // Who did it
+	Viewer  int       `json:"viewer,omitempty"` // 0 for the owner
+	Action  string    `json:"action"`
+	Via     string    `json:"via,omitempty"`  // How the user connected
+	Text    string    `json:"text,omitempty"` // What was typed
+}
+
+// typist is someone typing in a session.
+type typist struct {
+	session, user string
+	viewer        int
+}
+
+// Logger writes events. A nil Logger logs nothing.
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	lines  map[typist][]byte // Typed text up to the end of the line
+	now    func() time.Time
+	closer io.Closer
+}
+
+func New(w io.Writer) *Logger {
+	return &Logger{w: w, lines: map[typist][]byte{}, now: time.Now}
+}
+
+// Open appends to the log file at path, creating it readable only by the user.
+func Open(path string) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l := New(f)
+	l.closer = f
+	return l, nil
+}
+
+// Log writes e, stamped with the current time.
+func (l *Logger) Log(e Event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.write(e)
+}
+
+// Input records keys typed in a session. They are logged a line at a time.
+func (l *Logger) Input(session, user string, viewer int, p []byte) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := typist{session, user, viewer}
+	line := append(l.lines[t], p...)
+	for {
+		end := bytes.IndexAny(line, "\r\n")
+		if end < 0 {
+			break
+		}
+		l.write(Event{Session: session, User: user, Viewer: viewer, Action: Input, Text: string(line[:end+1])})
+		line = line[end+1:]
+	}
+	if len(line) >= maxLine {
+		l.write(Event{Session: session, User: user, Viewer: viewer, Action: Input, Text: string(line)})
+		line = nil
+	}
+	if len(line) == 0 {
+		delete(l.lines, t)
+	} else {
+		l.lines[t] = append([]byte(nil), line...)
+	}
+}
+
+// Flush logs what someone typed after their last line, when they leave.
+func (l *Logger) Flush(session, user string, viewer int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := typist{session, user, viewer}
+	if line := l.lines[t]; len(line) > 0 {
+		l.write(Event{Session: session, User: user, Viewer: viewer, Action: Input, Text: string(line)})
+	}
+	delete(l.lines, t)
+}
+
+// Close logs unfinished lines and closes the file of Open.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for t, line := range l.lines {
+		l.write(Event{Session: t.session, User: t.user, Viewer: t.viewer, Action: Input, Text: string(line)})
+	}
+	l.lines = map[typist][]byte{}
+	if l.closer != nil {
+		return l.closer.Close()
+	}
+	return nil
+}
+
+// write logs e. The caller holds mu.
+func (l *Logger) write(e Event) {
+	e.Time = l.now()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.w.Write(append(line, '\n'))
+}
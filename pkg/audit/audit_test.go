@@ -0,0 +1,86 @@
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func events(t *testing.T, log string) []audit.Event {
+	t.Helper()
+	var events []audit.Event
+	for _, line := range strings.Split(strings.TrimSpace(log), "\n") {
+		var e audit.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		assert.False(t, e.Time.IsZero())
+		e.Time = e.Time.UTC().Truncate(0)
+		events = append(events, e)
+	}
+	return events
+}
+
+func texts(events []audit.Event) []string {
+	var texts []string
+	for _, e := range events {
+		texts = append(texts, e.User+": "+e.Text)
+	}
+	return texts
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("Lines", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := audit.New(&buf)
+		l.Input("main", "ana", 1, []byte("ls"))
+		l.Input("main", "bob", 0, []byte("echo hi\r"))
+		assert.Equal(t, []string{"bob: echo hi\r"}, texts(events(t, buf.String())))
+
+		l.Input("main", "ana", 1, []byte(" -l\rpwd\rto"))
+		l.Flush("main", "ana", 1)
+		l.Flush("main", "ana", 1)
+		assert.Equal(t, []string{"bob: echo hi\r", "ana: ls -l\r", "ana: pwd\r", "ana: to"}, texts(events(t, buf.String())))
+	})
+
+	t.Run("Long line", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := audit.New(&buf)
+		l.Input("main", "ana", 1, bytes.Repeat([]byte("x"), 2000))
+		got := events(t, buf.String())
+		require.Len(t, got, 1)
+		assert.Len(t, got[0].Text, 2000)
+	})
+
+	t.Run("Open and close", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		l, err := audit.Open(path)
+		require.NoError(t, err)
+		l.Log(audit.Event{Session: "main", User: "ana", Viewer: 1, Action: audit.Join, Via: "unix uid 1000"})
+		l.Input("main", "ana", 1, []byte("uptime"))
+		require.NoError(t, l.Close())
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		got := events(t, string(data))
+		require.Len(t, got, 2)
+		assert.Equal(t, audit.Join, got[0].Action)
+		assert.Equal(t, "unix uid 1000", got[0].Via)
+		assert.Equal(t, audit.Event{Time: got[1].Time, Session: "main", User: "ana", Viewer: 1, Action: audit.Input, Text: "uptime"}, got[1])
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var l *audit.Logger
+		l.Log(audit.Event{Action: audit.Share})
+		l.Input("main", "ana", 1, []byte("ls\r"))
+		assert.NoError(t, l.Close())
+	})
+}
@@ -0,0 +1,144 @@
+package bell
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode is what happens when a program rings the bell (BEL, 0x07).
+type Mode int
+
+const (
+	ModeAudible Mode = iota // Pass the bell to the host terminal
+	ModeVisual              // Flash the screen in inverse video
+	ModeCommand             // Run a hook command, e.g. one that plays a sound
+	ModeNone                // Ignore the bell
+)
+
+const (
+	// DefaultInterval is the minimum time between two rings; bells in
+	// between are dropped so a flood of BEL characters makes one sound.
+	DefaultInterval = 250 * time.Millisecond
+
+	// FlashDuration is how long the visual bell inverts the screen.
+	FlashDuration = 100 * time.Millisecond
+)
+
+// commandPrefix starts a bell_sound setting that runs a command, so a
+// mistyped mode is not taken for one.
+const commandPrefix = "command:"
+
+// ParseMode interprets the bell_sound setting: "system" or "audible",
+// "visual", "none", or "command:" and a command to run. It returns the
+// command, and ModeAudible for a setting it does not know.
+func ParseMode(setting string) (Mode, string, error) {
+	setting = strings.TrimSpace(setting)
+	if command, ok := strings.CutPrefix(setting, commandPrefix); ok {
+		if command = strings.TrimSpace(command); command == "" {
+			return ModeAudible, "", fmt.Errorf("no command after %q", commandPrefix)
+		}
+		return ModeCommand, command, nil
+	}
+	switch strings.ToLower(setting) {
+	case "system", "audible", "beep":
+		return ModeAudible, "", nil
+	case "visual", "flash":
+		return ModeVisual, "", nil
+	case "", "none", "off":
+		return ModeNone, "", nil
+	}
+	return ModeAudible, "", fmt.Errorf("unknown bell %q (want system, visual, none or command:<program>)", setting)
+}
+
+// Actions are the side effects of a ring, provided by the session.
+type Actions struct {
+	Audible func()           // Send BEL to the host
+	Flash   func(on bool)    // Turn the inverse-video flash on or off
+	Run     func(cmd string) // Run the hook command (defaults to RunCommand)
+}
+
+// Bell decides how and whether to ring, with rate limiting.
+type Bell struct {
+	mu       sync.Mutex
+	mode     Mode
+	command  string
+	interval time.Duration
+	last     time.Time
+	actions  Actions
+	now      func() time.Time
+}
+
+func NewBell(setting string, actions Actions) *Bell {
+	if actions.Run == nil {
+		actions.Run = RunCommand
+	}
+	b := &Bell{
+		interval: DefaultInterval,
+		actions:  actions,
+		now:      time.Now,
+	}
+	b.SetMode(setting)
+	return b
+}
+
+// SetMode changes the bell_sound setting, e.g. after a config reload.
+func (b *Bell) SetMode(setting string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mode, b.command, _ = ParseMode(setting)
+}
+
+// SetInterval changes the minimum time between rings.
+func (b *Bell) SetInterval(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.interval = d
+}
+
+// Ring handles one BEL. It reports whether the bell actually rang.
+func (b *Bell) Ring() bool {
+	b.mu.Lock()
+	mode, command := b.mode, b.command
+	if mode == ModeNone {
+		b.mu.Unlock()
+		return false
+	}
+	now := b.now()
+	if !b.last.IsZero() && now.Sub(b.last) < b.interval {
+		b.mu.Unlock()
+		return false
+	}
+	b.last = now
+	b.mu.Unlock()
+
+	switch mode {
+	case ModeAudible:
+		if b.actions.Audible != nil {
+			b.actions.Audible()
+		}
+	case ModeVisual:
+		if b.actions.Flash != nil {
+			b.actions.Flash(true)
+			time.AfterFunc(FlashDuration, func() { b.actions.Flash(false) })
+		}
+	case ModeCommand:
+		b.actions.Run(command)
+	}
+	return true
+}
+
+// RunCommand starts the hook command without waiting for it.
+func RunCommand(command string) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+	cmd := exec.Command(fields[0], fields[1:]...)
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	go cmd.Wait()
+}
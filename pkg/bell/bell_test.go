@@ -0,0 +1,68 @@
+package bell
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMode(t *testing.T) {
+	mode, _, _ := ParseMode("system")
+	assert.Equal(t, ModeAudible, mode)
+	mode, _, _ = ParseMode("Visual")
+	assert.Equal(t, ModeVisual, mode)
+	mode, _, _ = ParseMode("none")
+	assert.Equal(t, ModeNone, mode)
+	mode, cmd, err := ParseMode("command: /usr/bin/paplay /tmp/bell.oga")
+	assert.NoError(t, err)
+	assert.Equal(t, ModeCommand, mode)
+	assert.Equal(t, "/usr/bin/paplay /tmp/bell.oga", cmd)
+
+	// A mistyped mode is not run.
+	mode, cmd, err = ParseMode("visaul")
+	assert.EqualError(t, err, `unknown bell "visaul" (want system, visual, none or command:<program>)`)
+	assert.Equal(t, ModeAudible, mode)
+	assert.Empty(t, cmd)
+	_, _, err = ParseMode("command:")
+	assert.Error(t, err)
+}
+
+func TestRateLimit(t *testing.T) {
+	rings := 0
+	b := NewBell("system", Actions{Audible: func() { rings++ }})
+
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+
+	// A flood of bells rings once.
+	for i := 0; i < 100; i++ {
+		b.Ring()
+	}
+	assert.Equal(t, 1, rings)
+
+	now = now.Add(DefaultInterval)
+	assert.True(t, b.Ring())
+	assert.Equal(t, 2, rings)
+}
+
+func TestModes(t *testing.T) {
+	var ran string
+	flashes := make(chan bool, 2)
+	b := NewBell("visual", Actions{
+		Flash: func(on bool) { flashes <- on },
+		Run:   func(cmd string) { ran = cmd },
+	})
+
+	b.Ring()
+	assert.True(t, <-flashes)
+	assert.False(t, <-flashes)
+
+	b.SetMode("command:/bin/true")
+	b.SetInterval(0)
+	b.Ring()
+	assert.Equal(t, "/bin/true", ran)
+
+	b.SetMode("none")
+	assert.False(t, b.Ring())
+}
@@ -0,0 +1,76 @@
+package charset
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// known maps normalized names (lower case, no "-" or "_") to encodings.
+// A nil encoding means UTF-8, which needs no conversion.
+var known = map[string]encoding.Encoding{
+	"utf8":        nil,
+	"iso88591":    charmap.ISO8859_1,
+	"latin1":      charmap.ISO8859_1,
+	"l1":          charmap.ISO8859_1,
+	"iso885915":   charmap.ISO8859_15,
+	"latin9":      charmap.ISO8859_15,
+	"windows1252": charmap.Windows1252,
+	"cp1252":      charmap.Windows1252,
+	"shiftjis":    japanese.ShiftJIS,
+	"sjis":        japanese.ShiftJIS,
+	"cp932":       japanese.ShiftJIS,
+	"eucjp":       japanese.EUCJP,
+	"euckr":       korean.EUCKR,
+	"cp949":       korean.EUCKR,
+	"gbk":         simplifiedchinese.GBK,
+	"cp936":       simplifiedchinese.GBK,
+	"gb18030":     simplifiedchinese.GB18030,
+	"big5":        traditionalchinese.Big5,
+	"koi8r":       charmap.KOI8R,
+}
+
+// Lookup returns the encoding for a name such as "UTF-8", "ISO-8859-1",
+// "Windows-1252", "Shift_JIS", "EUC-KR" or "GBK". Other IANA names are
+// accepted as well. The encoding is nil for UTF-8.
+func Lookup(name string) (encoding.Encoding, error) {
+	key := strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+	if key == "" {
+		return nil, nil
+	}
+	if enc, ok := known[key]; ok {
+		return enc, nil
+	}
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+	return enc, nil
+}
+
+// NewDecoder returns a reader that converts r from enc to UTF-8.
+// A character split across reads is kept until the rest arrives.
+func NewDecoder(r io.Reader, enc encoding.Encoding) io.Reader {
+	if enc == nil {
+		return r
+	}
+	return transform.NewReader(r, enc.NewDecoder())
+}
+
+// NewEncoder returns a writer that converts UTF-8 to enc before writing to w.
+// Characters enc cannot represent are replaced (usually by '?').
+func NewEncoder(w io.Writer, enc encoding.Encoding) io.Writer {
+	if enc == nil {
+		return w
+	}
+	return transform.NewWriter(w, encoding.ReplaceUnsupported(enc.NewEncoder()))
+}
@@ -0,0 +1,61 @@
+package charset_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/charset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"ISO-8859-1", "latin1", "Windows-1252", "Shift_JIS", "EUC-KR", "GBK", "ISO-8859-5"} {
+		enc, err := charset.Lookup(name)
+		require.NoError(t, err, name)
+		assert.NotNil(t, enc, name)
+	}
+
+	enc, err := charset.Lookup("UTF-8")
+	require.NoError(t, err)
+	assert.Nil(t, enc)
+
+	_, err = charset.Lookup("klingon")
+	assert.Error(t, err)
+}
+
+func TestRoundTrip(t *testing.T) {
+	cases := map[string]struct {
+		text    string
+		encoded []byte
+	}{
+		"ISO-8859-1":   {"café", []byte{'c', 'a', 'f', 0xe9}},
+		"Windows-1252": {"€5", []byte{0x80, '5'}},
+		"Shift_JIS":    {"日本", []byte{0x93, 0xfa, 0x96, 0x7b}},
+		"EUC-KR":       {"한", []byte{0xc7, 0xd1}},
+		"GBK":          {"中", []byte{0xd6, 0xd0}},
+	}
+
+	for name, tc := range cases {
+		enc, err := charset.Lookup(name)
+		require.NoError(t, err)
+
+		decoded, err := io.ReadAll(charset.NewDecoder(bytes.NewReader(tc.encoded), enc))
+		require.NoError(t, err)
+		assert.Equal(t, tc.text, string(decoded), name)
+
+		var out bytes.Buffer
+		_, err = charset.NewEncoder(&out, enc).Write([]byte(tc.text))
+		require.NoError(t, err)
+		assert.Equal(t, tc.encoded, out.Bytes(), name)
+	}
+}
+
+func TestEscapeSequencesPassThrough(t *testing.T) {
+	enc, _ := charset.Lookup("Shift_JIS")
+	input := append([]byte("\x1b[1m"), 0x93, 0xfa, '\r', '\n')
+	decoded, err := io.ReadAll(charset.NewDecoder(bytes.NewReader(input), enc))
+	require.NoError(t, err)
+	assert.Equal(t, "\x1b[1m日\r\n", string(decoded))
+}
@@ -0,0 +1,81 @@
+// Package clipboard decides which OSC 52 clipboard requests from programs
+// reach the host terminal.
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Mode is what programs may do with the clipboard.
+type Mode int
+
+const (
+	WriteOnly Mode = iota // Programs may set the clipboard
+	ReadWrite             // And also read it, which leaks whatever was copied
+	Disabled
+)
+
+// DefaultMaxSize is the largest text, in bytes, a program may copy.
+const DefaultMaxSize = 1 << 20
+
+var modeNames = map[string]Mode{
+	"write-only": WriteOnly,
+	"read-write": ReadWrite,
+	"disabled":   Disabled,
+}
+
+// ParseMode converts the clipboard setting: "write-only", "read-write" or "disabled".
+func ParseMode(name string) (Mode, error) {
+	mode, ok := modeNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return WriteOnly, fmt.Errorf("unknown policy %q (want write-only, read-write or disabled)", name)
+	}
+	return mode, nil
+}
+
+func (m Mode) String() string {
+	switch m {
+	case ReadWrite:
+		return "read-write"
+	case Disabled:
+		return "disabled"
+	default:
+		return "write-only"
+	}
+}
+
+// Policy limits OSC 52 requests.
+type Policy struct {
+	Mode    Mode
+	MaxSize int // Largest decoded text a program may copy; 0 means no limit
+}
+
+// Allow reports whether an OSC 52 request may be passed on. payload is the
+// part after "52;": the selection, ';' and base64 text or "?" to read.
+func (p Policy) Allow(payload string) bool {
+	_, data, ok := strings.Cut(payload, ";")
+	if !ok {
+		return false
+	}
+	switch {
+	case p.Mode == Disabled:
+		return false
+	case data == "?":
+		return p.Mode == ReadWrite
+	case p.MaxSize > 0 && base64.StdEncoding.DecodedLen(len(data)) > p.MaxSize:
+		return false
+	}
+	return true
+}
+
+// EncodedLimit returns the longest payload Allow can accept, so a filter
+// knows when to stop buffering a request. It returns 0 for no limit.
+func (p Policy) EncodedLimit() int {
+	if p.MaxSize <= 0 {
+		return 0
+	}
+	// Selection names are a few letters; allow some room for them.
+	return base64.StdEncoding.EncodedLen(p.MaxSize) + 16
+}
@@ -0,0 +1,37 @@
+package clipboard_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/clipboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	mode, err := clipboard.ParseMode("Read-Write")
+	require.NoError(t, err)
+	assert.Equal(t, clipboard.ReadWrite, mode)
+
+	_, err = clipboard.ParseMode("sometimes")
+	assert.Error(t, err)
+}
+
+func TestPolicy(t *testing.T) {
+	hello := "c;" + base64.StdEncoding.EncodeToString([]byte("hello"))
+
+	writeOnly := clipboard.Policy{Mode: clipboard.WriteOnly, MaxSize: 8}
+	assert.True(t, writeOnly.Allow(hello))
+	assert.False(t, writeOnly.Allow("c;?"), "reading is off unless read-write")
+	assert.False(t, writeOnly.Allow("c;"+base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 9)))))
+	assert.False(t, writeOnly.Allow("garbage"))
+
+	readWrite := clipboard.Policy{Mode: clipboard.ReadWrite}
+	assert.True(t, readWrite.Allow("c;?"))
+	assert.True(t, readWrite.Allow("c;"+strings.Repeat("A", 1<<22)))
+
+	disabled := clipboard.Policy{Mode: clipboard.Disabled}
+	assert.False(t, disabled.Allow(hello))
+}
@@ -0,0 +1,90 @@
+package color
+
+import "fmt"
+
+// Color is a terminal color: the terminal default, one of the 256 indexed
+// palette entries, or a 24-bit RGB value.
+//
+// The top byte holds the kind and the lower 24 bits the value, so a Color is
+// cheap to copy and compare inside screen cells.
+type Color uint32
+
+const (
+	kindDefault uint32 = 0
+	kindIndexed uint32 = 1 << 24
+	kindRGB     uint32 = 2 << 24
+
+	kindMask  uint32 = 0xff << 24
+	valueMask uint32 = 0xffffff
+)
+
+// Default is the terminal's configured foreground or background color.
+const Default Color = 0
+
+// The 16 ANSI palette entries.
+const (
+	Black Color = Color(kindIndexed) | iota
+	Red
+	Green
+	Yellow
+	Blue
+	Magenta
+	Cyan
+	White
+	BrightBlack
+	BrightRed
+	BrightGreen
+	BrightYellow
+	BrightBlue
+	BrightMagenta
+	BrightCyan
+	BrightWhite
+)
+
+// Indexed returns the palette color n (0-255).
+func Indexed(n uint8) Color {
+	return Color(kindIndexed | uint32(n))
+}
+
+// RGB returns a 24-bit color.
+func RGB(r, g, b uint8) Color {
+	return Color(kindRGB | uint32(r)<<16 | uint32(g)<<8 | uint32(b))
+}
+
+// IsDefault reports whether c is the terminal default color.
+func (c Color) IsDefault() bool {
+	return uint32(c)&kindMask == kindDefault
+}
+
+// IsIndexed reports whether c is a palette color.
+func (c Color) IsIndexed() bool {
+	return uint32(c)&kindMask == kindIndexed
+}
+
+// IsRGB reports whether c is a 24-bit color.
+func (c Color) IsRGB() bool {
+	return uint32(c)&kindMask == kindRGB
+}
+
+// Index returns the palette index of an indexed color.
+func (c Color) Index() uint8 {
+	return uint8(uint32(c) & valueMask)
+}
+
+// Components returns the red, green and blue parts of an RGB color.
+func (c Color) Components() (r, g, b uint8) {
+	v := uint32(c) & valueMask
+	return uint8(v >> 16), uint8(v >> 8), uint8(v)
+}
+
+func (c Color) String() string {
+	switch {
+	case c.IsIndexed():
+		return fmt.Sprintf("%d", c.Index())
+	case c.IsRGB():
+		r, g, b := c.Components()
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	default:
+		return "default"
+	}
+}
@@ -0,0 +1,55 @@
+package color_test
+
+import (
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	valid := map[string]color.Color{
+		"default":       color.Default,
+		"red":           color.Red,
+		"Bright_Blue":   color.BrightBlue,
+		"brightwhite":   color.BrightWhite,
+		"208":           color.Indexed(208),
+		"#ff8000":       color.RGB(0xff, 0x80, 0x00),
+		"#0f0":          color.RGB(0, 0xff, 0),
+		"rgb:ff/80/00":  color.RGB(0xff, 0x80, 0x00),
+		"rgb:ffff/0/8":  color.RGB(0xff, 0, 0x88),
+		"navy":          color.RGB(0, 0, 0x80),
+		"rebeccapurple": color.RGB(0x66, 0x33, 0x99),
+	}
+	for input, want := range valid {
+		got, err := color.Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "256", "#12345", "#gggggg", "rgb:ff/00", "notacolor"} {
+		_, err := color.Parse(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	orange := color.RGB(0xff, 0x87, 0x00)
+	assert.Equal(t, orange, color.TrueColor.Convert(orange))
+	assert.Equal(t, color.Indexed(208), color.ANSI256.Convert(orange))
+	assert.Equal(t, color.BrightRed, color.ANSI16.Convert(color.RGB(0xff, 0x10, 0x10)))
+	assert.Equal(t, color.Indexed(196), color.ANSI256.Convert(color.Indexed(196)))
+	assert.Equal(t, color.Default, color.NoColor.Convert(color.Red))
+}
+
+func TestDetectProfile(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	assert.Equal(t, color.TrueColor, color.DetectProfile(env(map[string]string{"COLORTERM": "truecolor", "TERM": "xterm"})))
+	assert.Equal(t, color.ANSI256, color.DetectProfile(env(map[string]string{"TERM": "xterm-256color"})))
+	assert.Equal(t, color.TrueColor, color.DetectProfile(env(map[string]string{"TERM": "kariuki"})))
+	assert.Equal(t, color.ANSI16, color.DetectProfile(env(map[string]string{"TERM": "vt100"})))
+	assert.Equal(t, color.NoColor, color.DetectProfile(env(map[string]string{"TERM": "dumb"})))
+}
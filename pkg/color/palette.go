@@ -0,0 +1,63 @@
+package color
+
+// ansi16 holds the xterm default values of the 16 ANSI colors.
+var ansi16 = [16][3]uint8{
+	{0x00, 0x00, 0x00}, {0xcd, 0x00, 0x00}, {0x00, 0xcd, 0x00}, {0xcd, 0xcd, 0x00},
+	{0x00, 0x00, 0xee}, {0xcd, 0x00, 0xcd}, {0x00, 0xcd, 0xcd}, {0xe5, 0xe5, 0xe5},
+	{0x7f, 0x7f, 0x7f}, {0xff, 0x00, 0x00}, {0x00, 0xff, 0x00}, {0xff, 0xff, 0x00},
+	{0x5c, 0x5c, 0xff}, {0xff, 0x00, 0xff}, {0x00, 0xff, 0xff}, {0xff, 0xff, 0xff},
+}
+
+// cubeLevels are the component values of the 6x6x6 color cube (16-231).
+var cubeLevels = [6]uint8{0x00, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+
+// PaletteRGB returns the xterm default RGB value of palette entry n.
+func PaletteRGB(n uint8) (r, g, b uint8) {
+	switch {
+	case n < 16:
+		c := ansi16[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36], cubeLevels[(n/6)%6], cubeLevels[n%6]
+	default:
+		v := 8 + 10*(n-232)
+		return v, v, v
+	}
+}
+
+// ToRGB resolves c to 24-bit values. Default colors resolve to def.
+func (c Color) ToRGB(def Color) (r, g, b uint8) {
+	if c.IsDefault() {
+		if def.IsDefault() {
+			return 0, 0, 0
+		}
+		c = def
+	}
+	if c.IsIndexed() {
+		return PaletteRGB(c.Index())
+	}
+	return c.Components()
+}
+
+// nearest returns the palette entry in [from, to) closest to r, g, b.
+func nearest(r, g, b uint8, from, to int) uint8 {
+	best, bestDist := from, -1
+	for i := from; i < to; i++ {
+		pr, pg, pb := PaletteRGB(uint8(i))
+		d := distance(r, g, b, pr, pg, pb)
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return uint8(best)
+}
+
+// distance is a cheap perceptual color distance ("redmean" weighting).
+func distance(r1, g1, b1, r2, g2, b2 uint8) int {
+	rmean := (int(r1) + int(r2)) / 2
+	dr := int(r1) - int(r2)
+	dg := int(g1) - int(g2)
+	db := int(b1) - int(b2)
+	return ((512+rmean)*dr*dr)>>8 + 4*dg*dg + ((767-rmean)*db*db)>>8
+}
@@ -0,0 +1,120 @@
+package color
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ansiNames maps the ANSI color names to palette indexes. "bright" variants
+// are accepted as "brightred", "bright_red" or "bright-red".
+var ansiNames = map[string]uint8{
+	"black": 0, "red": 1, "green": 2, "yellow": 3,
+	"blue": 4, "magenta": 5, "cyan": 6, "white": 7,
+}
+
+// Parse converts a color setting. Accepted forms:
+//
+//	default                 the terminal default
+//	red, bright_red         ANSI names (palette 0-15)
+//	0 .. 255                xterm-256 palette index
+//	#rgb, #rrggbb           hex RGB
+//	rgb:r/g/b               X11 syntax, 1 to 4 hex digits per component
+//	navy, rebeccapurple     CSS names that are not ANSI names
+func Parse(s string) (Color, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return Default, fmt.Errorf("invalid color %q: empty value", s)
+	}
+	if name == "default" {
+		return Default, nil
+	}
+
+	if n, ok := ansiNames[name]; ok {
+		return Indexed(n), nil
+	}
+	for _, prefix := range []string{"bright_", "bright-", "bright"} {
+		if base, found := strings.CutPrefix(name, prefix); found {
+			if n, ok := ansiNames[base]; ok {
+				return Indexed(n + 8), nil
+			}
+		}
+	}
+
+	if name[0] >= '0' && name[0] <= '9' {
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 0 || n > 255 {
+			return Default, fmt.Errorf("invalid color %q: palette index must be 0-255", s)
+		}
+		return Indexed(uint8(n)), nil
+	}
+
+	if hex, ok := strings.CutPrefix(name, "#"); ok {
+		return parseHex(s, hex)
+	}
+	if spec, ok := strings.CutPrefix(name, "rgb:"); ok {
+		return parseX11(s, spec)
+	}
+
+	if v, ok := cssColors[strings.NewReplacer("_", "", "-", "", " ", "").Replace(name)]; ok {
+		return RGB(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+	}
+	return Default, fmt.Errorf("invalid color %q: use an ANSI or CSS name, 0-255, #rrggbb or rgb:rr/gg/bb", s)
+}
+
+// MustParse is like Parse but panics on invalid input. Meant for constants.
+func MustParse(s string) Color {
+	c, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func parseHex(orig, hex string) (Color, error) {
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return Default, fmt.Errorf("invalid color %q: hex colors need 3 or 6 digits", orig)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Default, fmt.Errorf("invalid color %q: %q is not hexadecimal", orig, hex)
+	}
+	return RGB(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+}
+
+// parseX11 decodes "rr/gg/bb" where each part has 1 to 4 hex digits and is
+// scaled to 8 bits, as XParseColor does.
+func parseX11(orig, spec string) (Color, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 {
+		return Default, fmt.Errorf("invalid color %q: expected rgb:rr/gg/bb", orig)
+	}
+	var rgb [3]uint8
+	for i, p := range parts {
+		if len(p) < 1 || len(p) > 4 {
+			return Default, fmt.Errorf("invalid color %q: each component needs 1 to 4 hex digits", orig)
+		}
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil {
+			return Default, fmt.Errorf("invalid color %q: %q is not hexadecimal", orig, p)
+		}
+		max := uint64(1)<<(4*len(p)) - 1
+		rgb[i] = uint8(v * 255 / max)
+	}
+	return RGB(rgb[0], rgb[1], rgb[2]), nil
+}
+
+// X11 formats c as "rgb:rrrr/gggg/bbbb", the form used in OSC color replies.
+func (c Color) X11(def Color) string {
+	r, g, b := c.ToRGB(def)
+	return fmt.Sprintf("rgb:%02x%02x/%02x%02x/%02x%02x", r, r, g, g, b, b)
+}
+
+// Hex formats c as "#rrggbb".
+func (c Color) Hex(def Color) string {
+	r, g, b := c.ToRGB(def)
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
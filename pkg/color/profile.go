@@ -0,0 +1,77 @@
+package color
+
+import "strings"
+
+// Profile is the color capability of a terminal.
+type Profile int
+
+const (
+	NoColor   Profile = iota
+	ANSI16            // The 16 ANSI colors
+	ANSI256           // The xterm 256-color palette
+	TrueColor         // 24-bit RGB
+)
+
+// DetectProfile guesses the capability of the host terminal from its
+// environment (COLORTERM, TERM, NO_COLOR).
+func DetectProfile(getenv func(string) string) Profile {
+	if getenv("NO_COLOR") != "" {
+		return NoColor
+	}
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return TrueColor
+	}
+	term := getenv("TERM")
+	switch {
+	case term == "" || term == "dumb":
+		return NoColor
+	case strings.Contains(term, "truecolor") || strings.Contains(term, "direct"):
+		return TrueColor
+	case term == "kariuki":
+		// Converts colors for the terminal it runs in.
+		return TrueColor
+	case strings.Contains(term, "256color"):
+		return ANSI256
+	}
+	return ANSI16
+}
+
+// Convert returns the closest color the profile can show. Default colors
+// are kept; with NoColor every color becomes Default.
+func (p Profile) Convert(c Color) Color {
+	if c.IsDefault() {
+		return c
+	}
+	switch p {
+	case NoColor:
+		return Default
+	case ANSI16:
+		if c.IsIndexed() && c.Index() < 16 {
+			return c
+		}
+		r, g, b := c.ToRGB(Default)
+		return Indexed(nearest(r, g, b, 0, 16))
+	case ANSI256:
+		if c.IsIndexed() {
+			return c
+		}
+		// The 16 base colors are often themed by the user, so only the
+		// cube and gray ramp are used as targets.
+		r, g, b := c.Components()
+		return Indexed(nearest(r, g, b, 16, 256))
+	}
+	return c
+}
+
+func (p Profile) String() string {
+	switch p {
+	case ANSI16:
+		return "ansi16"
+	case ANSI256:
+		return "ansi256"
+	case TrueColor:
+		return "truecolor"
+	}
+	return "none"
+}
@@ -0,0 +1,211 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/session"
+)
+
+// killTimeout is how long a terminated program has to exit after SIGHUP
+// before it is killed.
+const killTimeout = 5 * time.Second
+
+// Input is text the control API sends to a session as if typed.
+type Input struct {
+	Data string
+}
+
+// Snapshot is the screen of a session.
+type Snapshot struct {
+	Rows      int
+	Cols      int
+	Lines     []string // Text of each row, without trailing blanks
+	CursorRow int      // Zero based
+	CursorCol int
+	AltScreen bool // A full screen program such as vi runs
+	Title     string
+}
+
+// apiError is the body of failed control API requests.
+type apiError struct {
+	Error string
+}
+
+// API returns the control API, for tools that manage sessions: JSON over
+// HTTP, on a socket readable only by the user (see ServeAPI).
+//
+//	GET    /sessions               []SessionInfo
+//	POST   /sessions               AttachRequest, starts a session; SessionInfo
+//	GET    /sessions/{name}        SessionInfo
+//	DELETE /sessions/{name}        hangs up the program, killing it if it stays; exit status
+//	POST   /sessions/{name}/input  Input
+//	POST   /sessions/{name}/resize Size
+//	GET    /sessions/{name}/screen Snapshot
+//
+// Errors are answered with a status code and {"Error": message}.
+func (srv *Server) API() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, srv.Sessions())
+	})
+	mux.HandleFunc("POST /sessions", srv.apiStart)
+	mux.HandleFunc("GET /sessions/{name}", srv.apiSession(func(w http.ResponseWriter, r *http.Request, e *entry) {
+		srv.mu.Lock()
+		info := srv.info(e)
+		srv.mu.Unlock()
+		writeJSON(w, http.StatusOK, info)
+	}))
+	mux.HandleFunc("DELETE /sessions/{name}", srv.apiSession(srv.apiTerminate))
+	mux.HandleFunc("POST /sessions/{name}/input", srv.apiSession(srv.apiInput))
+	mux.HandleFunc("POST /sessions/{name}/resize", srv.apiSession(srv.apiResize))
+	mux.HandleFunc("GET /sessions/{name}/screen", srv.apiSession(srv.apiScreen))
+	return mux
+}
+
+// ServeAPI answers control API requests from l until it is closed. Create
+// l with Listen, so only the user can connect.
+func (srv *Server) ServeAPI(l net.Listener) error {
+	err := (&http.Server{Handler: srv.API()}).Serve(l)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// apiSession finds the session named in the path for handle.
+func (srv *Server) apiSession(handle func(http.ResponseWriter, *http.Request, *entry)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		srv.mu.Lock()
+		e, ok := srv.sessions[name]
+		srv.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no session %q", name))
+			return
+		}
+		handle(w, r, e)
+	}
+}
+
+func (srv *Server) apiStart(w http.ResponseWriter, r *http.Request) {
+	var req AttachRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Name == "" {
+		req.Name = DefaultName
+	}
+	if err := req.checkSize(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if _, ok := srv.sessions[req.Name]; ok {
+		writeError(w, http.StatusConflict, fmt.Errorf("session %q exists", req.Name))
+		return
+	}
+	e, err := srv.start(req.Name, req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, srv.info(e))
+}
+
+// apiTerminate hangs up the program and waits for it to exit.
+func (srv *Server) apiTerminate(w http.ResponseWriter, r *http.Request, e *entry) {
+	e.session.Hangup()
+	select {
+	case <-e.done:
+	case <-time.After(killTimeout):
+		e.session.Kill()
+		<-e.done
+	}
+	srv.mu.Lock()
+	code := e.code
+	srv.mu.Unlock()
+	writeJSON(w, http.StatusOK, exitStatus{Code: code})
+}
+
+// apiInput types into the session, under its command policy. The audit log
+// records the input as the owner's.
+func (srv *Server) apiInput(w http.ResponseWriter, r *http.Request, e *entry) {
+	var in Input
+	if err := readJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	srv.mu.Lock()
+	e.typist = nil
+	srv.audit.Log(audit.Event{Session: e.name, User: srv.owner, Action: audit.Input, Via: "control api", Text: in.Data})
+	srv.mu.Unlock()
+	if err := e.session.Type([]byte(in.Data)); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) apiResize(w http.ResponseWriter, r *http.Request, e *entry) {
+	var size Size
+	if err := readJSON(r, &size); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := session.CheckSize(size.Rows, size.Cols); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := e.session.Resize(size.Rows, size.Cols); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) apiScreen(w http.ResponseWriter, r *http.Request, e *entry) {
+	screen := e.session.Screen()
+	rows, cols := screen.Size()
+	cursor := screen.Cursor()
+	lines := screen.Lines()
+	snap := Snapshot{
+		Rows:      rows,
+		Cols:      cols,
+		Lines:     make([]string, len(lines)),
+		CursorRow: cursor.Y,
+		CursorCol: cursor.X,
+		AltScreen: screen.AltScreen(),
+		Title:     screen.Title(),
+	}
+	for i, l := range lines {
+		snap.Lines[i] = l.Text()
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxFrame))
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
@@ -0,0 +1,118 @@
+package daemon_test
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	l, err := daemon.Listen(path)
+	require.NoError(t, err)
+	defer l.Close()
+	srv := daemon.NewServer(&terminal.TerminalConfig{Rows: 24, Cols: 80, MaxSessionTime: time.Hour, Prompt: "$ ", BlockedCommands: []string{"touch"}})
+	var log hostBuffer
+	srv.SetAuditLog(audit.New(&log))
+	go srv.ServeAPI(l)
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	call := func(method, url, body string, v any) int {
+		t.Helper()
+		req, err := http.NewRequest(method, "http://kariuki"+url, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := httpClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if v != nil {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+		return resp.StatusCode
+	}
+	screen := func() daemon.Snapshot {
+		var snap daemon.Snapshot
+		require.Equal(t, http.StatusOK, call("GET", "/sessions/ops/screen", "", &snap))
+		return snap
+	}
+
+	var info daemon.SessionInfo
+	assert.Equal(t, http.StatusCreated, call("POST", "/sessions", `{"Name": "ops", "Command": ["sh"], "Rows": 10, "Cols": 40}`, &info))
+	assert.Equal(t, "ops", info.Name)
+	assert.NotZero(t, info.Pid)
+	assert.NotEmpty(t, info.Owner)
+	assert.InDelta(t, 3600, info.TimeLeftSeconds, 60)
+
+	t.Run("Errors", func(t *testing.T) {
+		var e struct{ Error string }
+		assert.Equal(t, http.StatusConflict, call("POST", "/sessions", `{"Name": "ops"}`, &e))
+		assert.Equal(t, `session "ops" exists`, e.Error)
+		assert.Equal(t, http.StatusNotFound, call("GET", "/sessions/nope/screen", "", &e))
+		assert.Equal(t, http.StatusBadRequest, call("POST", "/sessions/ops/input", `"ls"`, &e))
+		assert.Equal(t, http.StatusBadRequest, call("POST", "/sessions/ops/resize", `{"Rows": 0}`, &e))
+		assert.Equal(t, http.StatusBadRequest, call("POST", "/sessions/ops/resize", `{"Rows": 24, "Cols": 65535}`, &e))
+		assert.Equal(t, "invalid size 65535x24", e.Error)
+		assert.Equal(t, http.StatusBadRequest, call("POST", "/sessions", `{"Name": "huge", "Rows": 65535, "Cols": 80}`, &e))
+	})
+
+	t.Run("Input and screen", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, call("POST", "/sessions/ops/input", `{"Data": "stty size\r"}`, nil))
+		assert.Eventually(t, func() bool { return strings.Contains(strings.Join(screen().Lines, "\n"), "10 40") }, 5*time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, http.StatusNoContent, call("POST", "/sessions/ops/resize", `{"Rows": 12, "Cols": 50}`, nil))
+		assert.Equal(t, http.StatusNoContent, call("POST", "/sessions/ops/input", `{"Data": "clear; stty size\r"}`, nil))
+		assert.Eventually(t, func() bool {
+			snap := screen()
+			return len(snap.Lines) == 12 && snap.Cols == 50 && snap.Lines[0] == "12 50"
+		}, 5*time.Second, 10*time.Millisecond)
+		snap := screen()
+		assert.Equal(t, 1, snap.CursorRow)
+		assert.False(t, snap.AltScreen)
+
+		// Input is audited even though the session is not shared.
+		assert.Contains(t, log.String(), `"action":"input","via":"control api","text":"stty size\r"`)
+	})
+
+	t.Run("Blocked command", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Equal(t, http.StatusNoContent, call("POST", "/sessions/ops/input", `{"Data": "touch `+dir+`/x\r"}`, nil))
+		assert.Eventually(t, func() bool { return strings.Contains(log.String(), `"action":"deny","text":"touch `+dir+`/x"`) }, 5*time.Second, 10*time.Millisecond)
+		assert.NoFileExists(t, filepath.Join(dir, "x"))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		time.Sleep(50 * time.Millisecond)
+		var sessions []map[string]any
+		assert.Equal(t, http.StatusOK, call("GET", "/sessions", "", &sessions))
+		require.Len(t, sessions, 1)
+		assert.ElementsMatch(t, []string{"Name", "Owner", "Command", "Pid", "Started", "IdleSeconds", "TimeLeftSeconds", "Attached"}, slices.Collect(maps.Keys(sessions[0])))
+		assert.Equal(t, []any{"sh"}, sessions[0]["Command"])
+		assert.GreaterOrEqual(t, sessions[0]["IdleSeconds"], 0.05)
+		assert.Less(t, sessions[0]["IdleSeconds"], 60.0)
+		assert.InDelta(t, 3600, sessions[0]["TimeLeftSeconds"], 60)
+		assert.Equal(t, false, sessions[0]["Attached"])
+	})
+
+	var exit struct{ Code int }
+	assert.Equal(t, http.StatusOK, call("DELETE", "/sessions/ops", "", &exit))
+	assert.NotZero(t, exit.Code, "hung up")
+	var sessions []daemon.SessionInfo
+	assert.Equal(t, http.StatusOK, call("GET", "/sessions", "", &sessions))
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,156 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Result is how an attached client was let go.
+type Result struct {
+	Detached bool // By the user, or by another client attaching
+	ExitCode int  // Of the program, when it exited
+}
+
+// Client is a connection to the daemon.
+type Client struct {
+	c    *conn
+	left atomic.Bool // The viewer's input ended
+}
+
+// Dial connects to the daemon listening on the socket at path.
+func Dial(path string) (*Client, error) {
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{c: newConn(c)}, nil
+}
+
+// DialShared connects to the listener of ListenShared at address.
+func DialShared(address string) (*Client, error) {
+	network := "tcp"
+	if filepath.IsAbs(address) {
+		network = "unix"
+	}
+	c, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{c: newConn(c)}, nil
+}
+
+func (cl *Client) Close() error {
+	return cl.c.Close()
+}
+
+// List returns the sessions of the daemon. The connection can not be used
+// for anything else afterwards.
+func (cl *Client) List() ([]SessionInfo, error) {
+	if err := cl.c.send(frameList, nil); err != nil {
+		return nil, err
+	}
+	typ, payload, err := cl.c.receive()
+	if err != nil {
+		return nil, err
+	}
+	if typ == frameError {
+		return nil, errors.New(string(payload))
+	}
+	var infos []SessionInfo
+	return infos, decodeJSON(payload, &infos)
+}
+
+// Share changes who may watch and type in a session, and reports how it is
+// shared. The connection can not be used for anything else afterwards.
+func (cl *Client) Share(req ShareRequest) (Sharing, error) {
+	if err := cl.c.sendJSON(frameShare, req); err != nil {
+		return Sharing{}, err
+	}
+	typ, payload, err := cl.c.receive()
+	if err != nil {
+		return Sharing{}, err
+	}
+	if typ == frameError {
+		return Sharing{}, errors.New(string(payload))
+	}
+	var status Sharing
+	return status, decodeJSON(payload, &status)
+}
+
+// Attach shows a session on out, repainted as it is now, and sends in to
+// it until the client is detached, the program exits or the daemon goes
+// away.
+func (cl *Client) Attach(req AttachRequest, in io.Reader, out io.Writer) (Result, error) {
+	if err := cl.c.sendJSON(frameAttach, req); err != nil {
+		return Result{}, err
+	}
+	go cl.sendInput(in)
+	return cl.show(out)
+}
+
+// Join watches a shared session on out and sends in to it, which the
+// program only gets while the owner lets the viewer type. It returns when
+// in ends, as if detached, or the owner stops sharing.
+func (cl *Client) Join(req JoinRequest, in io.Reader, out io.Writer) (Result, error) {
+	if err := cl.c.sendJSON(frameJoin, req); err != nil {
+		return Result{}, err
+	}
+	go func() {
+		cl.sendInput(in)
+		cl.left.Store(true)
+		cl.c.Close()
+	}()
+	return cl.show(out)
+}
+
+// show writes the output of the session to out until the client is let go.
+func (cl *Client) show(out io.Writer) (Result, error) {
+	for {
+		typ, payload, err := cl.c.receive()
+		if err != nil {
+			if cl.left.Load() {
+				return Result{Detached: true}, nil
+			}
+			return Result{}, fmt.Errorf("connection to the daemon lost: %w", err)
+		}
+		switch typ {
+		case frameOutput:
+			if _, err := out.Write(payload); err != nil {
+				return Result{}, err
+			}
+		case frameDetached:
+			return Result{Detached: true}, nil
+		case frameExit:
+			var status exitStatus
+			err := decodeJSON(payload, &status)
+			return Result{ExitCode: status.Code}, err
+		case frameError:
+			return Result{}, errors.New(string(payload))
+		}
+	}
+}
+
+// Resize tells the attached session the new size of the terminal.
+func (cl *Client) Resize(rows, cols int) error {
+	return cl.c.sendJSON(frameResize, Size{Rows: rows, Cols: cols})
+}
+
+// sendInput sends keys from in until it ends or the connection is closed.
+func (cl *Client) sendInput(in io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if cl.c.send(frameInput, buf[:n]) != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
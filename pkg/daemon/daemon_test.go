@@ -0,0 +1,257 @@
+package daemon_test
+
+import (
+	"bytes"
+	"io"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hostBuffer is the terminal of a test client.
+type hostBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *hostBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *hostBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// client is an attached test client.
+type client struct {
+	cl     *daemon.Client
+	keys   *io.PipeWriter
+	host   *hostBuffer
+	result chan daemon.Result
+}
+
+func attach(t *testing.T, path string, req daemon.AttachRequest) *client {
+	t.Helper()
+	cl, err := daemon.Dial(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { cl.Close() })
+
+	in, keys := io.Pipe()
+	c := &client{cl: cl, keys: keys, host: &hostBuffer{}, result: make(chan daemon.Result, 1)}
+	go func() {
+		res, err := cl.Attach(req, in, c.host)
+		assert.NoError(t, err)
+		c.result <- res
+	}()
+	return c
+}
+
+// waitPrompt waits until the shell has printed its prompt, so what is typed
+// next is not echoed before it.
+func (c *client) waitPrompt(t *testing.T) {
+	t.Helper()
+	require.Eventually(t, func() bool { return prompt.MatchString(c.host.String()) }, 5*time.Second, 10*time.Millisecond)
+}
+
+// prompt matches the output of sh up to its first prompt, which may end
+// with a shell integration mark.
+var prompt = regexp.MustCompile(`[$#] (\x1b\]133;B\a)?$`)
+
+func (c *client) wait(t *testing.T) daemon.Result {
+	t.Helper()
+	select {
+	case res := <-c.result:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("client was not let go")
+		return daemon.Result{}
+	}
+}
+
+func list(t *testing.T, path string) []daemon.SessionInfo {
+	t.Helper()
+	cl, err := daemon.Dial(path)
+	require.NoError(t, err)
+	defer cl.Close()
+	sessions, err := cl.List()
+	require.NoError(t, err)
+	return sessions
+}
+
+func TestDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d.sock")
+	l, err := daemon.Listen(path)
+	require.NoError(t, err)
+	defer l.Close()
+	go daemon.NewServer(&terminal.TerminalConfig{Rows: 24, Cols: 80, Prompt: "$ "}).Serve(l)
+
+	_, err = daemon.Listen(path)
+	assert.Error(t, err, "second daemon on the same socket")
+
+	req := daemon.AttachRequest{Name: "work", Command: []string{"sh"}, Rows: 10, Cols: 40}
+	first := attach(t, path, req)
+	first.waitPrompt(t)
+	io.WriteString(first.keys, "echo one\r")
+	assert.Eventually(t, func() bool { return strings.Contains(first.host.String(), "one") }, 5*time.Second, 10*time.Millisecond)
+
+	// The program keeps running after the prefix key and d.
+	io.WriteString(first.keys, "sleep 0.3; echo later\r\x1dd")
+	assert.Equal(t, daemon.Result{Detached: true}, first.wait(t))
+	sessions := list(t, path)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "work", sessions[0].Name)
+	assert.False(t, sessions[0].Attached)
+	time.Sleep(500 * time.Millisecond)
+
+	second := attach(t, path, req)
+	assert.Eventually(t, func() bool { return strings.Contains(second.host.String(), "later") }, 5*time.Second, 10*time.Millisecond)
+	assert.Regexp(t, `\x1b\[1;1H. echo one`, second.host.String(), "repainted")
+
+	// A new client takes the session over.
+	third := attach(t, path, req)
+	assert.Equal(t, daemon.Result{Detached: true}, second.wait(t))
+	assert.Eventually(t, func() bool { return strings.Contains(third.host.String(), "later") }, 5*time.Second, 10*time.Millisecond)
+
+	// Sizes larger than a session holds are ignored, or refused when it
+	// starts.
+	require.NoError(t, third.cl.Resize(65535, 65535))
+	io.WriteString(third.keys, "stty size\r")
+	assert.Eventually(t, func() bool { return strings.Contains(third.host.String(), "10 40") }, 5*time.Second, 10*time.Millisecond)
+	cl, err := daemon.Dial(path)
+	require.NoError(t, err)
+	_, err = cl.Attach(daemon.AttachRequest{Name: "huge", Rows: 65535, Cols: 80}, strings.NewReader(""), io.Discard)
+	assert.EqualError(t, err, "invalid size 80x65535")
+	cl.Close()
+
+	io.WriteString(third.keys, "exit 3\r")
+	assert.Equal(t, daemon.Result{ExitCode: 3}, third.wait(t))
+	assert.Eventually(t, func() bool { return len(list(t, path)) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func join(t *testing.T, cl *daemon.Client, req daemon.JoinRequest) *client {
+	t.Helper()
+	t.Cleanup(func() { cl.Close() })
+	in, keys := io.Pipe()
+	c := &client{keys: keys, host: &hostBuffer{}, result: make(chan daemon.Result, 1)}
+	go func() {
+		res, _ := cl.Join(req, in, c.host)
+		c.result <- res
+	}()
+	return c
+}
+
+func changeSharing(t *testing.T, path string, req daemon.ShareRequest) daemon.Sharing {
+	t.Helper()
+	cl, err := daemon.Dial(path)
+	require.NoError(t, err)
+	defer cl.Close()
+	status, err := cl.Share(req)
+	require.NoError(t, err)
+	return status
+}
+
+func TestShare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d.sock")
+	l, err := daemon.Listen(path)
+	require.NoError(t, err)
+	defer l.Close()
+	srv := daemon.NewServer(&terminal.TerminalConfig{Rows: 24, Cols: 80, Prompt: "$ ", BlockedCommands: []string{"touch"}})
+	var log hostBuffer
+	srv.SetAuditLog(audit.New(&log))
+	go srv.Serve(l)
+
+	shared, err := daemon.ListenShared("127.0.0.1:0")
+	require.NoError(t, err)
+	defer shared.Close()
+	go srv.ServeShared(shared)
+	_, err = daemon.ListenShared("192.0.2.1:7681")
+	assert.Error(t, err, "not a loopback address")
+
+	owner := attach(t, path, daemon.AttachRequest{Name: "work", Command: []string{"sh"}, Rows: 10, Cols: 40})
+	owner.waitPrompt(t)
+	io.WriteString(owner.keys, "echo one\r")
+	assert.Eventually(t, func() bool { return strings.Contains(owner.host.String(), "one") }, 5*time.Second, 10*time.Millisecond)
+
+	status := changeSharing(t, path, daemon.ShareRequest{Name: "work", Action: daemon.ShareStart})
+	require.True(t, status.Shared)
+	assert.Equal(t, shared.Addr().String(), status.Address)
+	assert.Equal(t, status.Token, changeSharing(t, path, daemon.ShareRequest{Name: "work", Action: daemon.ShareStart}).Token)
+
+	cl, err := daemon.DialShared(shared.Addr().String())
+	require.NoError(t, err)
+	in, keys := io.Pipe()
+	_, err = cl.Join(daemon.JoinRequest{Name: "work", Token: "wrong"}, in, io.Discard)
+	assert.Error(t, err)
+	keys.Close()
+	cl.Close()
+
+	cl, err = daemon.DialShared(shared.Addr().String())
+	require.NoError(t, err)
+	viewer := join(t, cl, daemon.JoinRequest{Name: "work", Token: status.Token, User: "ana"})
+	assert.Eventually(t, func() bool { return strings.Contains(viewer.host.String(), "one") }, 5*time.Second, 10*time.Millisecond)
+
+	// Read-only until the owner grants input.
+	io.WriteString(viewer.keys, "echo dropped\r")
+	time.Sleep(200 * time.Millisecond)
+	status = changeSharing(t, path, daemon.ShareRequest{Name: "work", Action: daemon.ShareGrant, Viewer: 1})
+	require.Len(t, status.Viewers, 1)
+	assert.Equal(t, "ana", status.Viewers[0].User)
+	assert.True(t, status.Viewers[0].CanType)
+	io.WriteString(viewer.keys, "echo granted\r")
+	assert.Eventually(t, func() bool { return strings.Contains(owner.host.String(), "granted") }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return strings.Contains(viewer.host.String(), "granted") }, 5*time.Second, 10*time.Millisecond)
+	assert.NotContains(t, owner.host.String(), "dropped")
+
+	// A viewer types under the command policy.
+	dir := t.TempDir()
+	io.WriteString(viewer.keys, "touch "+dir+"/x\r")
+	assert.Eventually(t, func() bool { return strings.Contains(log.String(), `"action":"deny"`) }, 5*time.Second, 10*time.Millisecond)
+	assert.NoFileExists(t, filepath.Join(dir, "x"))
+
+	// The account of a local viewer is known; the name it gives is kept too.
+	cl, err = daemon.Dial(path)
+	require.NoError(t, err)
+	local := join(t, cl, daemon.JoinRequest{Name: "work", Token: status.Token, User: "bob"})
+	account, err := user.Current()
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		status := changeSharing(t, path, daemon.ShareRequest{Name: "work", Action: daemon.ShareStatus})
+		return len(status.Viewers) == 2 && status.Viewers[1].User == account.Username+" (bob)" &&
+			status.Viewers[1].Via == "unix uid "+account.Uid
+	}, 5*time.Second, 10*time.Millisecond)
+
+	changeSharing(t, path, daemon.ShareRequest{Name: "work", Action: daemon.ShareStop})
+	assert.Equal(t, daemon.Result{Detached: true}, viewer.wait(t))
+	assert.Equal(t, daemon.Result{Detached: true}, local.wait(t))
+	assert.False(t, changeSharing(t, path, daemon.ShareRequest{Name: "work", Action: daemon.ShareStatus}).Shared)
+
+	io.WriteString(owner.keys, "exit\r")
+	owner.wait(t)
+	assert.Eventually(t, func() bool { return strings.Contains(log.String(), `"action":"leave"`) }, 5*time.Second, 10*time.Millisecond)
+	for _, line := range []string{
+		`"action":"share"`,
+		`"user":"unknown","action":"refuse","via":"tcp 127.0.0.1:`,
+		`"user":"ana","viewer":1,"action":"join","via":"tcp 127.0.0.1:`,
+		`"viewer":1,"action":"grant"`,
+		`"user":"ana","viewer":1,"action":"input","text":"echo granted\r"`,
+		`"user":"ana","viewer":1,"action":"deny","via":"tcp 127.0.0.1:`,
+		`"action":"unshare"`,
+	} {
+		assert.Contains(t, log.String(), line)
+	}
+	assert.NotContains(t, log.String(), "dropped")
+}
@@ -0,0 +1,203 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/session"
+)
+
+// Frames on the socket are a type byte, a big-endian uint32 length and
+// the payload. Clients send attach, join, input, resize, list and share;
+// the server answers with output, detached, exit, sessions, sharing
+// and error.
+const (
+	frameAttach   byte = 'A' // AttachRequest
+	frameJoin     byte = 'J' // JoinRequest
+	frameInput    byte = 'I' // Keys for the program
+	frameResize   byte = 'R' // Size
+	frameList     byte = 'L' // No payload
+	frameShare    byte = 'H' // ShareRequest
+	frameOutput   byte = 'O' // Bytes for the host terminal
+	frameDetached byte = 'D' // No payload
+	frameExit     byte = 'X' // exitStatus
+	frameSessions byte = 'S' // []SessionInfo
+	frameShared   byte = 'V' // Sharing
+	frameError    byte = 'E' // Message
+)
+
+// maxFrame is the largest payload a peer accepts.
+const maxFrame = 1 << 20
+
+// AttachRequest asks for a session by name, started with Command at the
+// given size if there is none.
+type AttachRequest struct {
+	Name    string
+	Command []string // The user's shell when empty
+	Rows    int
+	Cols    int
+	Profile color.Profile // Colors the client's terminal can show
+}
+
+// checkSize returns why the size of req may not be used. A size that is
+// not given leaves it to the config.
+func (req AttachRequest) checkSize() error {
+	if req.Rows <= 0 || req.Cols <= 0 {
+		return nil
+	}
+	return session.CheckSize(req.Rows, req.Cols)
+}
+
+// Size is a terminal size sent when the client's terminal is resized.
+type Size struct {
+	Rows int
+	Cols int
+}
+
+// SessionInfo describes a session kept by the daemon.
+type SessionInfo struct {
+	Name    string
+	Owner   string // Account the daemon runs as
+	Command []string
+	Pid     int
+	Started time.Time
+	// Seconds since the last input or output, and until MaxSessionTime
+	// ends the session, zero without a limit. Seconds rather than
+	// durations, which JSON would show in nanoseconds.
+	IdleSeconds     float64
+	TimeLeftSeconds float64
+	Attached        bool
+}
+
+// JoinRequest asks to watch a shared session.
+type JoinRequest struct {
+	Name    string
+	Token   string        // Given to the owner when sharing started
+	User    string        // Who joins, for connections that do not tell
+	Profile color.Profile // Colors the viewer's terminal can show
+}
+
+// ShareAction is what the owner of a session asks of ShareRequest.
+type ShareAction string
+
+const (
+	ShareStart  ShareAction = "start"  // Let viewers join; sharing again keeps the token
+	ShareStop   ShareAction = "stop"   // Disconnect the viewers
+	ShareStatus ShareAction = "status" // Only report
+	ShareGrant  ShareAction = "grant"  // Let Viewer type
+	ShareRevoke ShareAction = "revoke" // Make Viewer read-only again
+)
+
+// ShareRequest changes who may watch and type in a session.
+type ShareRequest struct {
+	Name   string
+	Action ShareAction
+	Viewer int // ID of the viewer to grant or revoke input
+}
+
+// Sharing describes a shared session.
+type Sharing struct {
+	Shared  bool
+	Token   string
+	Address string // Extra listener viewers can join on, if any
+	Viewers []ViewerInfo
+}
+
+// ViewerInfo describes a viewer of a shared session.
+type ViewerInfo struct {
+	ID      int
+	User    string
+	Via     string // The socket and peer it came from
+	CanType bool
+	Since   time.Time
+}
+
+type exitStatus struct {
+	Code int
+}
+
+// conn is a socket carrying frames. Writes may come from several goroutines.
+type conn struct {
+	net.Conn
+	r   *bufio.Reader
+	wmu sync.Mutex
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{Conn: c, r: bufio.NewReader(c)}
+}
+
+// send writes one frame.
+func (c *conn) send(typ byte, payload []byte) error {
+	if len(payload) > maxFrame {
+		return fmt.Errorf("frame of %d bytes is too large", len(payload))
+	}
+	frame := make([]byte, 5+len(payload))
+	frame[0] = typ
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Write(frame)
+	return err
+}
+
+func (c *conn) sendJSON(typ byte, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.send(typ, payload)
+}
+
+func (c *conn) sendError(err error) error {
+	return c.send(frameError, []byte(err.Error()))
+}
+
+// receive reads one frame.
+func (c *conn) receive() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:5])
+	if n > maxFrame {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// outputWriter sends what is written as output frames.
+type outputWriter struct {
+	c *conn
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	for written := 0; written < len(p); {
+		n := min(len(p)-written, maxFrame)
+		if err := w.c.send(frameOutput, p[written:written+n]); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return len(p), nil
+}
+
+func decodeJSON(payload []byte, v any) error {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+	return nil
+}
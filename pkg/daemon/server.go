@@ -0,0 +1,342 @@
+// Package daemon keeps sessions running in a background server, so clients
+// can detach from them and attach again later, from another terminal.
+// Clients talk to the server over a Unix domain socket. The owner of a
+// session can share it with viewers, who watch it and may type in it
+// when allowed. Tools manage sessions through a control API, JSON over
+// HTTP on a socket of its own.
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/session"
+)
+
+// DefaultName is the session clients attach to when they give no name.
+const DefaultName = "main"
+
+// entry is a session kept by the server.
+type entry struct {
+	name    string
+	command []string
+	session *session.Session
+	started time.Time
+
+	// Guarded by Server.mu.
+	client   *conn         // Attached client, nil while detached
+	released chan struct{} // Closed when the client has let go of the session
+	share    *share        // Nil while not shared
+	exited   bool
+	code     int     // Exit status, once exited
+	typist   *viewer // Who typed last, nil for the owner
+
+	done chan struct{} // Closed when the program has exited
+}
+
+// Server owns sessions and attaches clients to them.
+type Server struct {
+	mu           sync.Mutex
+	config       *terminal.TerminalConfig
+	sessions     map[string]*entry
+	owner        string        // Account the daemon runs as
+	shareAddress string        // Of ServeShared
+	audit        *audit.Logger // Nil without an audit log
+}
+
+func NewServer(config *terminal.TerminalConfig) *Server {
+	owner := strconv.Itoa(os.Getuid())
+	if u, err := user.Current(); err == nil {
+		owner = u.Username
+	}
+	return &Server{config: config, sessions: map[string]*entry{}, owner: owner}
+}
+
+// ApplyConfig updates the settings of every session.
+func (srv *Server) ApplyConfig(config *terminal.TerminalConfig) {
+	srv.mu.Lock()
+	srv.config = config
+	var sessions []*session.Session
+	for _, e := range srv.sessions {
+		sessions = append(sessions, e.session)
+	}
+	srv.mu.Unlock()
+
+	for _, s := range sessions {
+		s.ApplyConfig(config)
+	}
+}
+
+// Listen creates the socket at path, readable only by the user. A socket
+// left behind by a server that is gone is replaced.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve handles clients from l until it is closed.
+func (srv *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go srv.handle(newConn(c))
+	}
+}
+
+// Sessions lists the running sessions by name.
+func (srv *Server) Sessions() []SessionInfo {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	infos := make([]SessionInfo, 0, len(srv.sessions))
+	for _, e := range srv.sessions {
+		infos = append(infos, srv.info(e))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// info describes a session. The caller holds mu.
+func (srv *Server) info(e *entry) SessionInfo {
+	left, _ := e.session.TimeLeft()
+	return SessionInfo{
+		Name:            e.name,
+		Owner:           srv.owner,
+		Command:         e.command,
+		Pid:             e.session.Pid(),
+		Started:         e.started,
+		IdleSeconds:     e.session.Idle().Seconds(),
+		TimeLeftSeconds: left.Seconds(),
+		Attached:        e.client != nil,
+	}
+}
+
+// handle answers the first frame of a client.
+func (srv *Server) handle(c *conn) {
+	defer c.Close()
+	typ, payload, err := c.receive()
+	if err != nil {
+		return
+	}
+	switch typ {
+	case frameList:
+		c.sendJSON(frameSessions, srv.Sessions())
+	case frameAttach:
+		var req AttachRequest
+		if err := decodeJSON(payload, &req); err != nil {
+			c.sendError(err)
+			return
+		}
+		if err := req.checkSize(); err != nil {
+			c.sendError(err)
+			return
+		}
+		srv.attach(c, req)
+	case frameShare:
+		var req ShareRequest
+		if err := decodeJSON(payload, &req); err != nil {
+			c.sendError(err)
+			return
+		}
+		status, err := srv.changeSharing(req)
+		if err != nil {
+			c.sendError(err)
+			return
+		}
+		c.sendJSON(frameShared, status)
+	case frameJoin:
+		srv.join(c, payload)
+	default:
+		c.sendError(fmt.Errorf("unexpected frame %q", typ))
+	}
+}
+
+// attach shows a session to the client until either goes away or the
+// user detaches. A client already attached to the session is detached.
+func (srv *Server) attach(c *conn, req AttachRequest) {
+	e, err := srv.acquire(c, req)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+	s := e.session
+	s.SetColorProfile(req.Profile)
+	if req.Rows > 0 && req.Cols > 0 {
+		s.Resize(req.Rows, req.Cols)
+	}
+
+	in, keys := io.Pipe()
+	go func() {
+		keys.CloseWithError(srv.readClient(c, e, keys))
+	}()
+	detached := s.Attach(in, outputWriter{c})
+	in.Close()
+
+	srv.mu.Lock()
+	e.client = nil
+	close(e.released)
+	srv.mu.Unlock()
+	if detached {
+		c.send(frameDetached, nil)
+	}
+}
+
+// acquire finds or starts the session of req and makes c its client,
+// once an earlier client has let go of it.
+func (srv *Server) acquire(c *conn, req AttachRequest) (*entry, error) {
+	name := req.Name
+	if name == "" {
+		name = DefaultName
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	e, ok := srv.sessions[name]
+	if !ok {
+		var err error
+		if e, err = srv.start(name, req); err != nil {
+			return nil, err
+		}
+	}
+	for e.client != nil {
+		// The other client's reader fails, which ends its Attach.
+		previous, released := e.client, e.released
+		previous.send(frameDetached, nil)
+		previous.Close()
+		srv.mu.Unlock()
+		<-released
+		srv.mu.Lock()
+	}
+	if e.exited {
+		return nil, fmt.Errorf("session %q has exited", name)
+	}
+	e.client = c
+	e.released = make(chan struct{})
+	return e, nil
+}
+
+// start runs a new session in the background. The caller holds mu.
+func (srv *Server) start(name string, req AttachRequest) (*entry, error) {
+	config := *srv.config
+	if req.Rows > 0 && req.Cols > 0 {
+		config.Rows, config.Cols = req.Rows, req.Cols
+	}
+	s := session.NewSession(&config, req.Command)
+	e := &entry{name: name, command: req.Command, session: s, started: time.Now(), done: make(chan struct{})}
+	// The policy finds the command line after the prompt.
+	s.UsePrompt()
+	s.SetPolicy(config.CommandPolicy(), func(line string, err error) { srv.denied(e, line) })
+	if err := s.Start(); err != nil {
+		return nil, err
+	}
+	s.OnOutput(func() { srv.changed(e) })
+	srv.sessions[name] = e
+	go srv.serve(e)
+	return e, nil
+}
+
+// denied logs a command line the policy refused, as typed by whoever typed
+// in the session last.
+func (srv *Server) denied(e *entry, line string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	event := audit.Event{Session: e.name, User: srv.owner, Action: audit.Deny, Text: line}
+	if v := e.typist; v != nil {
+		event.User, event.Viewer, event.Via = v.User, v.ID, v.Via
+	}
+	srv.audit.Log(event)
+}
+
+// serve keeps the screen of a session up to date while no client is
+// attached, and tells the client when the program exits.
+func (srv *Server) serve(e *entry) {
+	code := session.ExitCode(e.session.Serve())
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	e.exited, e.code = true, code
+	close(e.done)
+	delete(srv.sessions, e.name)
+	if e.client != nil {
+		// Closing the client ends its Attach.
+		e.client.sendJSON(frameExit, exitStatus{Code: code})
+		e.client.Close()
+	}
+	if e.share != nil {
+		for _, v := range e.share.viewers {
+			v.c.sendJSON(frameExit, exitStatus{Code: code})
+			v.c.Close()
+		}
+	}
+}
+
+// readClient passes the client's keys to Attach through keys and applies
+// its size changes, until the client goes away. While the session is
+// shared, the audit log records what the owner types.
+func (srv *Server) readClient(c *conn, e *entry, keys io.Writer) error {
+	defer func() {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		srv.audit.Flush(e.name, srv.owner, 0)
+	}()
+	for {
+		typ, payload, err := c.receive()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case frameInput:
+			srv.mu.Lock()
+			e.typist = nil
+			if e.share != nil {
+				srv.audit.Input(e.name, srv.owner, 0, payload)
+			}
+			srv.mu.Unlock()
+			if _, err := keys.Write(payload); err != nil {
+				return err
+			}
+		case frameResize:
+			var size Size
+			if err := decodeJSON(payload, &size); err != nil {
+				return err
+			}
+			// Sizes a client may not ask for are ignored.
+			if session.CheckSize(size.Rows, size.Cols) == nil {
+				e.session.Resize(size.Rows, size.Cols)
+			}
+		default:
+			return fmt.Errorf("unexpected frame %q", typ)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"golang.org/x/sys/unix"
+)
+
+// viewerFrameTime is the shortest time between two screens sent to a viewer.
+const viewerFrameTime = 30 * time.Millisecond
+
+// share is a session the owner lets viewers watch. Guarded by Server.mu.
+type share struct {
+	token   string
+	viewers map[int]*viewer
+	nextID  int
+}
+
+// viewer watches a shared session. Viewers see the screen model rather
+// than the program output, so they see the same whether or not the owner
+// is attached, and their terminals never answer the program's queries.
+type viewer struct {
+	ViewerInfo // CanType is guarded by Server.mu
+	c          *conn
+	out        io.Writer
+	redraw     chan struct{}
+	done       chan struct{}
+}
+
+// ListenShared opens an extra listener on which viewers can join shared
+// sessions, and nothing else: a loopback address such as 127.0.0.1:7681,
+// or the path of a Unix socket that other local users may connect to.
+func ListenShared(address string) (net.Listener, error) {
+	if filepath.IsAbs(address) {
+		os.Remove(address)
+		l, err := net.Listen("unix", address)
+		if err != nil {
+			return nil, err
+		}
+		// Viewers are known by their account; the token keeps the others out.
+		if err := os.Chmod(address, 0o666); err != nil {
+			l.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("share address %s is not a loopback address", address)
+	}
+	return net.Listen("tcp", address)
+}
+
+// ServeShared lets viewers join shared sessions through l until it is
+// closed. Clients of l can not attach, list or share sessions.
+func (srv *Server) ServeShared(l net.Listener) error {
+	srv.mu.Lock()
+	srv.shareAddress = l.Addr().String()
+	srv.mu.Unlock()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			c := newConn(c)
+			defer c.Close()
+			typ, payload, err := c.receive()
+			if err != nil {
+				return
+			}
+			if typ != frameJoin {
+				c.sendError(errors.New("only joining shared sessions is allowed here"))
+				return
+			}
+			srv.join(c, payload)
+		}()
+	}
+}
+
+// SetAuditLog records who joins shared sessions and what is typed in them
+// to l.
+func (srv *Server) SetAuditLog(l *audit.Logger) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.audit = l
+}
+
+// changeSharing carries out a request of the owner.
+func (srv *Server) changeSharing(req ShareRequest) (Sharing, error) {
+	name := req.Name
+	if name == "" {
+		name = DefaultName
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	e, ok := srv.sessions[name]
+	if !ok {
+		return Sharing{}, fmt.Errorf("no session %q", name)
+	}
+	event := audit.Event{Session: name, User: srv.owner, Viewer: req.Viewer}
+
+	switch req.Action {
+	case ShareStart:
+		if e.share == nil {
+			token, err := newToken()
+			if err != nil {
+				return Sharing{}, err
+			}
+			e.share = &share{token: token, viewers: map[int]*viewer{}}
+			event.Action = audit.Share
+			srv.audit.Log(event)
+		}
+	case ShareStop:
+		if e.share != nil {
+			for _, v := range e.share.viewers {
+				// Its join ends when the connection fails.
+				v.c.send(frameDetached, nil)
+				v.c.Close()
+			}
+			e.share = nil
+			event.Action = audit.Unshare
+			srv.audit.Log(event)
+		}
+	case ShareGrant, ShareRevoke:
+		var v *viewer
+		if e.share != nil {
+			v = e.share.viewers[req.Viewer]
+		}
+		if v == nil {
+			return Sharing{}, fmt.Errorf("no viewer %d of session %q", req.Viewer, name)
+		}
+		v.CanType = req.Action == ShareGrant
+		event.Action = audit.Revoke
+		if v.CanType {
+			event.Action = audit.Grant
+		}
+		srv.audit.Log(event)
+	case ShareStatus:
+	default:
+		return Sharing{}, fmt.Errorf("unknown share action %q", req.Action)
+	}
+	return srv.sharing(e), nil
+}
+
+// sharing describes how e is shared. The caller holds mu.
+func (srv *Server) sharing(e *entry) Sharing {
+	if e.share == nil {
+		return Sharing{}
+	}
+	status := Sharing{Shared: true, Token: e.share.token, Address: srv.shareAddress}
+	for _, v := range e.share.viewers {
+		status.Viewers = append(status.Viewers, v.ViewerInfo)
+	}
+	sort.Slice(status.Viewers, func(i, j int) bool { return status.Viewers[i].ID < status.Viewers[j].ID })
+	return status
+}
+
+// join shows a shared session to a viewer until it goes away, the owner
+// stops sharing or the program exits. The viewer's keys reach the
+// program only while the owner lets it type.
+func (srv *Server) join(c *conn, payload []byte) {
+	var req JoinRequest
+	if err := decodeJSON(payload, &req); err != nil {
+		c.sendError(err)
+		return
+	}
+	e, v, err := srv.addViewer(c, req)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+	defer srv.removeViewer(e, v)
+	go srv.drawViewer(e, v)
+
+	for {
+		typ, payload, err := c.receive()
+		if err != nil {
+			return
+		}
+		switch typ {
+		case frameInput:
+			srv.mu.Lock()
+			canType, log := v.CanType && !e.exited, srv.audit
+			if canType {
+				e.typist = v
+			}
+			srv.mu.Unlock()
+			if canType {
+				log.Input(e.name, v.User, v.ID, payload)
+				// Viewers type under the command policy, as the owner does.
+				e.session.Type(payload)
+			}
+		case frameResize:
+			// The owner's terminal sets the size.
+		default:
+			c.sendError(fmt.Errorf("unexpected frame %q", typ))
+			return
+		}
+	}
+}
+
+// addViewer checks the token of req and adds c to the viewers of the session.
+func (srv *Server) addViewer(c *conn, req JoinRequest) (*entry, *viewer, error) {
+	name := req.Name
+	if name == "" {
+		name = DefaultName
+	}
+	user, via := identify(c, req.User)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	e, ok := srv.sessions[name]
+	if !ok || e.share == nil || subtle.ConstantTimeCompare([]byte(req.Token), []byte(e.share.token)) != 1 {
+		srv.audit.Log(audit.Event{Session: name, User: user, Action: audit.Refuse, Via: via})
+		return nil, nil, fmt.Errorf("session %q is not shared with this token", name)
+	}
+	e.share.nextID++
+	v := &viewer{
+		ViewerInfo: ViewerInfo{ID: e.share.nextID, User: user, Via: via, Since: time.Now()},
+		c:          c,
+		out:        vt.NewColorFilter(outputWriter{c}, req.Profile),
+		redraw:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	v.redraw <- struct{}{}
+	e.share.viewers[v.ID] = v
+	srv.audit.Log(audit.Event{Session: name, User: user, Viewer: v.ID, Action: audit.Join, Via: via})
+	return e, v, nil
+}
+
+func (srv *Server) removeViewer(e *entry, v *viewer) {
+	close(v.done)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if e.share != nil && e.share.viewers[v.ID] == v {
+		delete(e.share.viewers, v.ID)
+	}
+	srv.audit.Flush(e.name, v.User, v.ID)
+	srv.audit.Log(audit.Event{Session: e.name, User: v.User, Viewer: v.ID, Action: audit.Leave, Via: v.Via})
+}
+
+// changed asks the viewers of e to draw the screen again.
+func (srv *Server) changed(e *entry) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if e.share == nil {
+		return
+	}
+	for _, v := range e.share.viewers {
+		select {
+		case v.redraw <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// drawViewer sends the screen to a viewer whenever it changes.
+func (srv *Server) drawViewer(e *entry, v *viewer) {
+	// The viewer's terminal starts from a clear screen.
+	io.WriteString(v.out, "\x1b[0m\x1b[H\x1b[2J")
+	for {
+		select {
+		case <-v.redraw:
+		case <-v.done:
+			return
+		}
+		var frame bytes.Buffer
+		// Cursor keys of the viewer must match what the program expects.
+		if e.session.Screen().PrivateMode(1) {
+			frame.WriteString("\x1b[?1h")
+		} else {
+			frame.WriteString("\x1b[?1l")
+		}
+		e.session.Screen().Render(&frame)
+		if _, err := v.out.Write(frame.Bytes()); err != nil {
+			return
+		}
+		time.Sleep(viewerFrameTime)
+	}
+}
+
+// identify names the peer of c. Local Unix sockets tell its account; over
+// TCP the viewer's own word is all there is, so its address is kept too.
+func identify(c *conn, claimed string) (name, via string) {
+	if claimed == "" {
+		claimed = "unknown"
+	}
+	uc, ok := c.Conn.(*net.UnixConn)
+	if !ok {
+		return claimed, "tcp " + c.RemoteAddr().String()
+	}
+	uid, err := peerUID(uc)
+	if err != nil {
+		return claimed, "unix"
+	}
+	via = "unix uid " + strconv.Itoa(uid)
+	account, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return claimed, via
+	}
+	if claimed != "unknown" && claimed != account.Username {
+		// A shared account; who is behind it is only claimed.
+		return account.Username + " (" + claimed + ")", via
+	}
+	return account.Username, via
+}
+
+// peerUID returns the user id of the process on the other end of c.
+func peerUID(c *net.UnixConn) (int, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+	return int(cred.Uid), nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,201 @@
+// Package export writes screen and scrollback lines to files as plain
+// text, text with ANSI escape sequences, or styled HTML.
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+// Format is an export file format.
+type Format int
+
+const (
+	Plain Format = iota
+	ANSI
+	HTML
+)
+
+var formatNames = map[string]Format{
+	"text":  Plain,
+	"plain": Plain,
+	"ansi":  ANSI,
+	"html":  HTML,
+}
+
+// ParseFormat converts a format name: "text" (or "plain"), "ansi" or "html".
+func ParseFormat(name string) (Format, error) {
+	f, ok := formatNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Plain, fmt.Errorf("unknown format %q (want text, ansi or html)", name)
+	}
+	return f, nil
+}
+
+// Extension returns the usual file name extension of the format.
+func (f Format) Extension() string {
+	switch f {
+	case ANSI:
+		return ".ansi"
+	case HTML:
+		return ".html"
+	}
+	return ".txt"
+}
+
+// Theme resolves default colors in HTML output.
+type Theme struct {
+	Fg, Bg color.Color
+}
+
+// Write writes lines in format f. Soft-wrapped rows are joined into the
+// lines the program wrote.
+func Write(w io.Writer, lines []vt.Line, f Format, theme Theme) error {
+	bw := bufio.NewWriter(w)
+	switch f {
+	case ANSI:
+		writeANSI(bw, lines)
+	case HTML:
+		writeHTML(bw, lines, theme)
+	default:
+		writePlain(bw, lines)
+	}
+	return bw.Flush()
+}
+
+func writePlain(w *bufio.Writer, lines []vt.Line) {
+	for _, l := range lines {
+		for _, c := range content(l) {
+			w.WriteString(c.Char())
+		}
+		if !l.Wrapped {
+			w.WriteByte('\n')
+		}
+	}
+}
+
+func writeANSI(w *bufio.Writer, lines []vt.Line) {
+	current := vt.Style{}
+	for _, l := range lines {
+		for _, c := range content(l) {
+			if c.Style != current {
+				w.WriteString("\x1b[" + c.Style.SGR() + "m")
+				current = c.Style
+			}
+			w.WriteString(c.Char())
+		}
+		if !l.Wrapped {
+			if current != (vt.Style{}) {
+				w.WriteString("\x1b[0m")
+				current = vt.Style{}
+			}
+			w.WriteByte('\n')
+		}
+	}
+}
+
+func writeHTML(w *bufio.Writer, lines []vt.Line, theme Theme) {
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>kariuki export</title>
+</head>
+<body style="margin:0;background:%s">
+<pre style="margin:0;padding:1em;color:%s;background:%s;font-family:monospace">`,
+		theme.Bg.Hex(color.Black), theme.Fg.Hex(color.White), theme.Bg.Hex(color.Black))
+
+	for _, l := range lines {
+		cells := content(l)
+		for i := 0; i < len(cells); {
+			j := i
+			var text strings.Builder
+			for j < len(cells) && cells[j].Style == cells[i].Style {
+				text.WriteString(cells[j].Char())
+				j++
+			}
+			css := styleCSS(cells[i].Style, theme)
+			if css == "" {
+				w.WriteString(html.EscapeString(text.String()))
+			} else {
+				fmt.Fprintf(w, `<span style="%s">%s</span>`, css, html.EscapeString(text.String()))
+			}
+			i = j
+		}
+		if !l.Wrapped {
+			w.WriteByte('\n')
+		}
+	}
+	w.WriteString("</pre>\n</body>\n</html>\n")
+}
+
+// styleCSS returns the inline CSS of a style, empty for the default one.
+func styleCSS(s vt.Style, theme Theme) string {
+	fg, bg := s.Fg, s.Bg
+	if s.Has(vt.AttrReverse) {
+		fg, bg = bg, fg
+		if fg.IsDefault() {
+			fg = theme.Bg
+		}
+		if bg.IsDefault() {
+			bg = theme.Fg
+		}
+	}
+	if s.Has(vt.AttrInvisible) {
+		fg = bg
+		if fg.IsDefault() {
+			fg = theme.Bg
+		}
+	}
+
+	var css []string
+	if !fg.IsDefault() {
+		css = append(css, "color:"+fg.Hex(theme.Fg))
+	}
+	if !bg.IsDefault() {
+		css = append(css, "background:"+bg.Hex(theme.Bg))
+	}
+	if s.Has(vt.AttrBold) {
+		css = append(css, "font-weight:bold")
+	}
+	if s.Has(vt.AttrFaint) {
+		css = append(css, "opacity:0.6")
+	}
+	if s.Has(vt.AttrItalic) {
+		css = append(css, "font-style:italic")
+	}
+	var decorations []string
+	if s.Has(vt.AttrUnderline) {
+		decorations = append(decorations, "underline")
+	}
+	if s.Has(vt.AttrStrikethrough) {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		css = append(css, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	return strings.Join(css, ";")
+}
+
+// content returns the cells of a line without trailing blanks. Rows that
+// continue on the next one keep all their cells.
+func content(l vt.Line) []vt.Cell {
+	if l.Wrapped {
+		return l.Cells
+	}
+	end := len(l.Cells)
+	for end > 0 {
+		c := l.Cells[end-1]
+		if c.Rune != 0 && c.Rune != ' ' || c.Continuation || !c.Bg.IsDefault() {
+			break
+		}
+		end--
+	}
+	return l.Cells[:end]
+}
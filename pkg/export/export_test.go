@@ -0,0 +1,64 @@
+package export_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/export"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func screenLines(t *testing.T, cols int, output string) []vt.Line {
+	t.Helper()
+	s := vt.NewScreen(5, cols)
+	_, err := s.Write([]byte(output))
+	require.NoError(t, err)
+	return s.History()
+}
+
+func write(t *testing.T, lines []vt.Line, f export.Format) string {
+	t.Helper()
+	var b strings.Builder
+	theme := export.Theme{Fg: color.MustParse("white"), Bg: color.MustParse("navy")}
+	require.NoError(t, export.Write(&b, lines, f, theme))
+	return b.String()
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]export.Format{"text": export.Plain, "plain": export.Plain, "ANSI": export.ANSI, "html": export.HTML} {
+		f, err := export.ParseFormat(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, f, name)
+	}
+	_, err := export.ParseFormat("pdf")
+	assert.Error(t, err)
+
+	assert.Equal(t, ".txt", export.Plain.Extension())
+	assert.Equal(t, ".html", export.HTML.Extension())
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("Plain joins wrapped rows", func(t *testing.T) {
+		lines := screenLines(t, 5, "abcdefg\r\n\x1b[31mred\x1b[0m  \r\n")
+		assert.Equal(t, "abcdefg\nred\n", write(t, lines, export.Plain))
+	})
+
+	t.Run("ANSI", func(t *testing.T) {
+		lines := screenLines(t, 10, "a\x1b[1;32mbold\x1b[0m\r\nplain")
+		assert.Equal(t, "a\x1b[0;1;32mbold\x1b[0m\nplain\n", write(t, lines, export.ANSI))
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		lines := screenLines(t, 20, "<a>&\x1b[4;38;2;1;2;3mx\x1b[0m \x1b[7mrev\x1b[0m")
+		html := write(t, lines, export.HTML)
+
+		assert.Contains(t, html, `background:#000080`)
+		assert.Contains(t, html, "&lt;a&gt;&amp;")
+		assert.Contains(t, html, `<span style="color:#010203;text-decoration:underline">x</span>`)
+		assert.Contains(t, html, `<span style="color:#000080;background:#e5e5e5">rev</span>`)
+		assert.True(t, strings.HasSuffix(html, "</html>\n"))
+	})
+}
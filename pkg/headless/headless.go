@@ -0,0 +1,180 @@
+// Package headless runs programs on a PTY against kariuki's screen model,
+// without a display, so terminal programs can be tested like any other code:
+// start the program, type keys, wait for the screen to show something and
+// compare it with a golden file.
+package headless
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/creack/pty"
+)
+
+// ErrTimeout is returned when a wait does not finish in time.
+var ErrTimeout = errors.New("headless: timed out")
+
+// Options configure the terminal a program runs in.
+type Options struct {
+	Rows, Cols int      // Defaults to 24x80
+	Env        []string // Added to the current environment
+	Dir        string   // Working directory
+}
+
+// Terminal is a program running on a PTY whose output feeds a vt.Screen.
+type Terminal struct {
+	screen *vt.Screen
+	cmd    *exec.Cmd
+	ptmx   *os.File
+
+	mu      sync.Mutex
+	changed chan struct{} // Closed and replaced on every screen update
+	done    chan struct{} // Closed when the program output ends
+	waitErr error
+}
+
+// Start runs argv in a new terminal.
+func Start(opts Options, argv ...string) (*Terminal, error) {
+	if len(argv) == 0 {
+		return nil, errors.New("headless: no command")
+	}
+	if opts.Rows < 1 {
+		opts.Rows = 24
+	}
+	if opts.Cols < 1 {
+		opts.Cols = 80
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = append(append(os.Environ(), "TERM=xterm-256color"), opts.Env...)
+	cmd.Dir = opts.Dir
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(opts.Rows), Cols: uint16(opts.Cols)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %q: %w", argv[0], err)
+	}
+
+	t := &Terminal{
+		screen:  vt.NewScreen(opts.Rows, opts.Cols),
+		cmd:     cmd,
+		ptmx:    ptmx,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	// There is no other terminal to answer queries such as DA and DSR.
+	t.screen.SetReplyWriter(ptmx)
+	go t.readOutput()
+	return t, nil
+}
+
+func (t *Terminal) readOutput() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.ptmx.Read(buf)
+		if n > 0 {
+			t.screen.Write(buf[:n])
+			t.notify()
+		}
+		if err != nil {
+			break
+		}
+	}
+	t.waitErr = t.cmd.Wait()
+	close(t.done)
+	t.notify()
+}
+
+func (t *Terminal) notify() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// Screen returns the screen model of the terminal.
+func (t *Terminal) Screen() *vt.Screen {
+	return t.screen
+}
+
+// Write sends raw input to the program.
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.ptmx.Write(p)
+}
+
+// Type sends text as if typed.
+func (t *Terminal) Type(text string) error {
+	_, err := io.WriteString(t.ptmx, text)
+	return err
+}
+
+// Press sends keys, encoded for the current cursor key mode.
+func (t *Terminal) Press(keys ...Key) error {
+	app := t.screen.Modes().AppCursorKeys
+	for _, k := range keys {
+		if err := t.Type(k.encode(app)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resize changes the terminal size and signals the program.
+func (t *Terminal) Resize(rows, cols int) error {
+	if err := pty.Setsize(t.ptmx, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}); err != nil {
+		return err
+	}
+	t.screen.Resize(rows, cols)
+	return t.cmd.Process.Signal(syscall.SIGWINCH)
+}
+
+// WaitFor waits until cond is true for the screen.
+func (t *Terminal) WaitFor(cond func(*vt.Screen) bool, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		t.mu.Lock()
+		changed := t.changed
+		t.mu.Unlock()
+
+		if cond(t.screen) {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return fmt.Errorf("%w waiting for the screen:\n%s", ErrTimeout, t.screen.String())
+		}
+	}
+}
+
+// WaitForText waits until text is shown on the screen.
+func (t *Terminal) WaitForText(text string, timeout time.Duration) error {
+	return t.WaitFor(func(s *vt.Screen) bool {
+		return strings.Contains(s.String(), text)
+	}, timeout)
+}
+
+// WaitExit waits for the program to exit and returns its exit status.
+func (t *Terminal) WaitExit(timeout time.Duration) (int, error) {
+	select {
+	case <-t.done:
+	case <-time.After(timeout):
+		return -1, fmt.Errorf("%w waiting for the program to exit", ErrTimeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(t.waitErr, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, t.waitErr
+}
+
+// Close ends the program (SIGHUP) and releases the PTY.
+func (t *Terminal) Close() error {
+	return t.ptmx.Close()
+}
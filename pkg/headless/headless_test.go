@@ -0,0 +1,66 @@
+package headless_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/headless"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const timeout = 5 * time.Second
+
+func TestTerminal(t *testing.T) {
+	t.Run("Golden snapshot", func(t *testing.T) {
+		term, err := headless.Start(headless.Options{Rows: 4, Cols: 20},
+			"printf", `\033[1;31mred\033[0m plain\r\n\033[4munder\033[0m`)
+		require.NoError(t, err)
+		defer term.Close()
+
+		code, err := term.WaitExit(timeout)
+		require.NoError(t, err)
+		assert.Equal(t, 0, code)
+		headless.MatchGolden(t, "testdata/colors.golden", headless.Snapshot(term.Screen()))
+	})
+
+	t.Run("Keys and wait", func(t *testing.T) {
+		term, err := headless.Start(headless.Options{Rows: 5, Cols: 30},
+			"sh", "-c", `printf 'name? '; read name; echo "hi $name"; read x`)
+		require.NoError(t, err)
+		defer term.Close()
+
+		require.NoError(t, term.WaitForText("name?", timeout))
+		require.NoError(t, term.Type("bob"))
+		require.NoError(t, term.Press(headless.KeyEnter))
+		require.NoError(t, term.WaitForText("hi bob", timeout))
+
+		require.NoError(t, term.Press(headless.Ctrl('c')))
+		code, err := term.WaitExit(timeout)
+		require.NoError(t, err)
+		assert.NotEqual(t, 0, code)
+	})
+
+	t.Run("Queries are answered", func(t *testing.T) {
+		// The program asks for the cursor position and prints the answer.
+		term, err := headless.Start(headless.Options{},
+			"sh", "-c", `stty raw -echo; printf 'ab\033[6n'; r=$(dd bs=6 count=1 2>/dev/null); printf '%s' "$r" | od -An -c`)
+		require.NoError(t, err)
+		defer term.Close()
+		require.NoError(t, term.WaitForText("[   1   ;   3   R", timeout))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		term, err := headless.Start(headless.Options{}, "sleep", "5")
+		require.NoError(t, err)
+		defer term.Close()
+		err = term.WaitFor(func(s *vt.Screen) bool { return false }, 50*time.Millisecond)
+		assert.ErrorIs(t, err, headless.ErrTimeout)
+	})
+}
+
+func TestKeys(t *testing.T) {
+	assert.Equal(t, headless.Key("\x03"), headless.Ctrl('c'))
+	assert.Equal(t, headless.Key("\x1bx"), headless.Alt("x"))
+}
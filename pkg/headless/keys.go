@@ -0,0 +1,50 @@
+package headless
+
+// Key is a key press that Press encodes like xterm does.
+type Key string
+
+const (
+	KeyEnter     Key = "\r"
+	KeyTab       Key = "\t"
+	KeyBackspace Key = "\x7f"
+	KeyEsc       Key = "\x1b"
+	KeyUp        Key = "\x1b[A"
+	KeyDown      Key = "\x1b[B"
+	KeyRight     Key = "\x1b[C"
+	KeyLeft      Key = "\x1b[D"
+	KeyHome      Key = "\x1b[H"
+	KeyEnd       Key = "\x1b[F"
+	KeyInsert    Key = "\x1b[2~"
+	KeyDelete    Key = "\x1b[3~"
+	KeyPageUp    Key = "\x1b[5~"
+	KeyPageDown  Key = "\x1b[6~"
+	KeyF1        Key = "\x1bOP"
+	KeyF2        Key = "\x1bOQ"
+	KeyF3        Key = "\x1bOR"
+	KeyF4        Key = "\x1bOS"
+)
+
+// Ctrl returns the key c pressed with Control, e.g. Ctrl('c').
+func Ctrl(c byte) Key {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	return Key(string(rune(c & 0x1f)))
+}
+
+// Alt returns the key k pressed with Alt (sent with an Esc prefix).
+func Alt(k Key) Key {
+	return "\x1b" + k
+}
+
+// encode returns the bytes of a key. In application cursor mode (DECCKM)
+// the arrows, Home and End are sent as SS3 sequences.
+func (k Key) encode(appCursor bool) string {
+	if appCursor {
+		switch k {
+		case KeyUp, KeyDown, KeyRight, KeyLeft, KeyHome, KeyEnd:
+			return "\x1bO" + string(k[2:])
+		}
+	}
+	return string(k)
+}
@@ -0,0 +1,117 @@
+package headless
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+// UpdateEnv is the environment variable that makes MatchGolden write the
+// golden files instead of comparing: UPDATE_GOLDEN=1 go test ./...
+const UpdateEnv = "UPDATE_GOLDEN"
+
+var attrNames = []struct {
+	attr vt.Attr
+	name string
+}{
+	{vt.AttrBold, "bold"},
+	{vt.AttrFaint, "faint"},
+	{vt.AttrItalic, "italic"},
+	{vt.AttrUnderline, "underline"},
+	{vt.AttrBlink, "blink"},
+	{vt.AttrReverse, "reverse"},
+	{vt.AttrInvisible, "invisible"},
+	{vt.AttrStrikethrough, "strikethrough"},
+}
+
+// Snapshot describes a screen as text: its size and cursor, the rows, and
+// then the runs of cells that are not in the default style, e.g.
+//
+//	size 3x20 cursor 5,1
+//	hello
+//	world
+//
+//	--- styles
+//	0:0-4 bold fg=1
+func Snapshot(s *vt.Screen) string {
+	var b strings.Builder
+	rows, cols := s.Size()
+	cur := s.Cursor()
+	fmt.Fprintf(&b, "size %dx%d cursor %d,%d", rows, cols, cur.X, cur.Y)
+	if !cur.Visible {
+		b.WriteString(" hidden")
+	}
+	b.WriteString("\n")
+
+	lines := s.Lines()
+	for _, l := range lines {
+		b.WriteString(l.Text() + "\n")
+	}
+
+	var styles []string
+	for y, l := range lines {
+		for x := 0; x < len(l.Cells); {
+			desc := describeCell(s, l.Cells[x])
+			end := x
+			for end+1 < len(l.Cells) && describeCell(s, l.Cells[end+1]) == desc {
+				end++
+			}
+			if desc != "" {
+				styles = append(styles, fmt.Sprintf("%d:%d-%d %s", y, x, end, desc))
+			}
+			x = end + 1
+		}
+	}
+	if len(styles) > 0 {
+		b.WriteString("\n--- styles\n")
+		b.WriteString(strings.Join(styles, "\n") + "\n")
+	}
+	return b.String()
+}
+
+// describeCell returns the attributes, colors and link of a cell, empty for
+// the default style.
+func describeCell(s *vt.Screen, c vt.Cell) string {
+	var parts []string
+	for _, a := range attrNames {
+		if c.Has(a.attr) {
+			parts = append(parts, a.name)
+		}
+	}
+	if !c.Fg.IsDefault() {
+		parts = append(parts, "fg="+c.Fg.String())
+	}
+	if !c.Bg.IsDefault() {
+		parts = append(parts, "bg="+c.Bg.String())
+	}
+	if h, ok := s.Hyperlink(c.Link); ok {
+		parts = append(parts, "link="+h.URI)
+	}
+	return strings.Join(parts, " ")
+}
+
+// MatchGolden compares got with the file at path and fails the test when
+// they differ. With UPDATE_GOLDEN set the file is written instead.
+func MatchGolden(t testing.TB, path, got string) {
+	t.Helper()
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with %s=1 to create it)", err, UpdateEnv)
+	}
+	if string(want) != got {
+		t.Errorf("screen does not match %s\n--- want\n%s--- got\n%s", path, want, got)
+	}
+}
@@ -0,0 +1,140 @@
+package mux
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+const (
+	// Host terminal: the alternate screen, cleared, with pastes bracketed so
+	// they can be checked, while the mux runs.
+	hostEnter = "\x1b[?1049h\x1b[H\x1b[2J\x1b[?2004h"
+	// Back to the main screen with the default colors and cursor (OSC 110/111).
+	hostLeave = "\x1b[0m\x1b[?2004l\x1b[?1049l\x1b]110\x1b\\\x1b]111\x1b\\\x1b[0 q\x1b[?25h"
+
+	// frameTime is the shortest time between two draws, so programs that
+	// write a lot do not redraw the host for every read.
+	frameTime = 10 * time.Millisecond
+
+	maxTabName = 20
+)
+
+// hostModes are the modes of the focused program that change what the host
+// terminal sends.
+type hostModes struct {
+	appCursor bool // DECCKM: cursor keys send ESC O
+}
+
+// setHostModes makes the host send keys as the focused program expects.
+// The caller holds mu.
+func (m *Mux) setHostModes(want hostModes) {
+	if want.appCursor != m.modes.appCursor {
+		m.writeHost(decset(1, want.appCursor))
+	}
+	m.modes = want
+}
+
+func decset(mode int, on bool) string {
+	if on {
+		return fmt.Sprintf("\x1b[?%dh", mode)
+	}
+	return fmt.Sprintf("\x1b[?%dl", mode)
+}
+
+// drawLoop draws the host terminal when asked to, until the mux is done.
+func (m *Mux) drawLoop() {
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-m.redraw:
+		}
+		m.draw()
+		time.Sleep(frameTime)
+	}
+}
+
+// draw shows the panes of the active tab, the dividers between them and
+// the tab bar. The focused pane is drawn last so the host cursor is its.
+func (m *Mux) draw() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.tabs) == 0 || m.host == nil {
+		return
+	}
+	t := m.tabs[m.active]
+
+	var buf bytes.Buffer
+	for _, leaf := range t.root.leaves() {
+		if leaf != t.focus {
+			leaf.pane.session.Screen().RenderAt(&buf, leaf.area.top, leaf.area.left, false)
+		}
+	}
+	for _, split := range t.root.splits() {
+		drawDivider(&buf, split.divider(), split.split)
+	}
+	m.drawTabBar(&buf)
+
+	screen := t.focus.pane.session.Screen()
+	screen.RenderAt(&buf, t.focus.area.top, t.focus.area.left, true)
+	m.writeHost(buf.String())
+	m.setHostModes(hostModes{appCursor: screen.PrivateMode(1)})
+}
+
+func drawDivider(buf *bytes.Buffer, r rect, split Split) {
+	if split == SideBySide {
+		for y := 0; y < r.rows; y++ {
+			fmt.Fprintf(buf, "\x1b[%d;%dH│", r.top+y+1, r.left+1)
+		}
+		return
+	}
+	fmt.Fprintf(buf, "\x1b[%d;%dH%s", r.top+1, r.left+1, strings.Repeat("─", r.cols))
+}
+
+// drawTabBar draws the bottom row: the tabs, the active one highlighted,
+// and the last message.
+func (m *Mux) drawTabBar(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "\x1b[%d;1H\x1b[0;7m", m.rows)
+	width := 0
+	for i, t := range m.tabs {
+		label := fmt.Sprintf(" %d:%s ", i+1, tabName(t))
+		if width+vt.StringWidth(label) > m.cols {
+			break
+		}
+		if i == m.active {
+			buf.WriteString("\x1b[0;1m" + label + "\x1b[0;7m")
+		} else {
+			buf.WriteString(label)
+		}
+		width += vt.StringWidth(label)
+	}
+	if m.message != "" {
+		msg := " " + m.message + " "
+		for msg != "" && width+vt.StringWidth(msg) > m.cols {
+			_, size := utf8.DecodeRuneInString(msg)
+			msg = msg[size:]
+		}
+		buf.WriteString(strings.Repeat(" ", m.cols-width-vt.StringWidth(msg)) + msg)
+		width = m.cols
+	}
+	buf.WriteString(strings.Repeat(" ", m.cols-width) + "\x1b[0m")
+}
+
+// tabName is the title of the focused program, or its name, cut to
+// maxTabName characters.
+func tabName(t *tab) string {
+	p := t.focus.pane
+	name := p.session.Screen().Title()
+	if name == "" {
+		name = p.name
+	}
+	if runes := []rune(name); len(runes) > maxTabName {
+		name = string(runes[:maxTabName-1]) + "…"
+	}
+	return name
+}
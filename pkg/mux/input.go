@@ -0,0 +1,247 @@
+package mux
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/FelipePn10/kariuki/pkg/paste"
+	"github.com/FelipePn10/kariuki/pkg/session"
+)
+
+// copyInput sends host input to the focused pane, running the key bindings
+// that follow the prefix key.
+func (m *Mux) copyInput(in io.Reader) {
+	buf := make([]byte, 4096)
+	pasting := false
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			pasting = m.filterInput(buf[:n], pasting)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// filterInput handles one read of host input and returns whether it ended
+// inside a paste, where the prefix key is only text.
+func (m *Mux) filterInput(data []byte, pasting bool) bool {
+	m.mu.Lock()
+	prefix := m.config.Prefix()
+	m.mu.Unlock()
+
+	for len(data) > 0 {
+		if pasting {
+			// The paste is searched whole, as the end delimiter may be
+			// split between reads.
+			m.pasted = append(m.pasted, data...)
+			end := bytes.Index(m.pasted, []byte(paste.End))
+			if end < 0 {
+				return true
+			}
+			text, rest := string(m.pasted[:end]), m.pasted[end+len(paste.End):]
+			m.pasted = nil
+			m.endPaste(text)
+			data, pasting = rest, false
+			continue
+		}
+		if text, ok := m.takePending(); ok {
+			if s := m.live(); s != nil && (data[0] == 'y' || data[0] == 'Y') {
+				s.Paste(text)
+			}
+			data = data[1:]
+			continue
+		}
+		if m.takePrefixed() {
+			m.runBinding(data[0], prefix)
+			data = data[1:]
+			continue
+		}
+
+		n := len(data)
+		if i := bytes.IndexByte(data, prefix); i >= 0 {
+			n = i
+		}
+		if start := bytes.Index(data[:n], []byte(paste.Start)); start >= 0 {
+			m.send(data[:start])
+			data = data[start+len(paste.Start):]
+			pasting = true
+			continue
+		}
+		if n == 0 {
+			m.mu.Lock()
+			m.prefixed = true
+			m.mu.Unlock()
+			data = data[1:]
+			continue
+		}
+		m.send(data[:n])
+		data = data[n:]
+	}
+	return pasting
+}
+
+// endPaste sends a paste to the focused pane, as its session would from
+// its own host: the policy may refuse it, and a paste that needs confirming
+// waits for y in the tab bar.
+func (m *Mux) endPaste(text string) {
+	s := m.focused()
+	if s == nil {
+		return
+	}
+	report, err := s.CheckPaste(text)
+	if err == nil && !report.NeedsConfirmation() {
+		if s := m.live(); s != nil {
+			s.Paste(text)
+		}
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.requestRedraw()
+	if err != nil {
+		m.message = err.Error()
+		return
+	}
+	m.pending = &text
+	m.message = fmt.Sprintf("paste %d line(s)? [y/N]", len(report.Lines))
+	if len(report.Warnings) > 0 {
+		m.message = report.Warnings[0].Reason + "; " + m.message
+	}
+}
+
+// takePending returns the paste waiting for confirmation, if any, and
+// forgets it.
+func (m *Mux) takePending() (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		return "", false
+	}
+	text := *m.pending
+	m.pending, m.message = nil, ""
+	m.requestRedraw()
+	return text, true
+}
+
+// takePrefixed reports whether the prefix key was the last key, and forgets it.
+func (m *Mux) takePrefixed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefixed := m.prefixed
+	m.prefixed = false
+	return prefixed
+}
+
+// send writes input to the focused pane.
+func (m *Mux) send(p []byte) {
+	if s := m.live(); s != nil {
+		s.Write(p)
+	}
+}
+
+// live returns the session of the focused pane, showing its live screen
+// again if the user was reading its scrollback.
+func (m *Mux) live() *session.Session {
+	s := m.focused()
+	if s != nil && s.Screen().ViewOffset() > 0 {
+		s.Screen().ScrollViewToBottom()
+		m.requestRedraw()
+	}
+	return s
+}
+
+// runBinding handles the key pressed after the prefix key.
+func (m *Mux) runBinding(key, prefix byte) {
+	if key == prefix {
+		m.send([]byte{key})
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.tabs) == 0 {
+		return
+	}
+	defer m.requestRedraw()
+	m.message = ""
+	t := m.tabs[m.active]
+	var err error
+	switch key {
+	case 'c':
+		err = m.newTab()
+	case 'n':
+		m.active = (m.active + 1) % len(m.tabs)
+	case 'p':
+		m.active = (m.active + len(m.tabs) - 1) % len(m.tabs)
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if i := int(key - '1'); i < len(m.tabs) {
+			m.active = i
+		}
+	case '%', '|':
+		err = m.splitPane(SideBySide)
+	case '"', '-':
+		err = m.splitPane(Stacked)
+	case 'o':
+		leaves := t.root.leaves()
+		for i, leaf := range leaves {
+			if leaf == t.focus {
+				t.focus = leaves[(i+1)%len(leaves)]
+				break
+			}
+		}
+	case 'h', 'j', 'k', 'l':
+		if leaf := neighbor(t, key); leaf != nil {
+			t.focus = leaf
+		}
+	case 'H':
+		t.focus.resize(SideBySide, -2)
+		m.layout(t)
+	case 'L':
+		t.focus.resize(SideBySide, 2)
+		m.layout(t)
+	case 'K':
+		t.focus.resize(Stacked, -1)
+		m.layout(t)
+	case 'J':
+		t.focus.resize(Stacked, 1)
+		m.layout(t)
+	case '[':
+		t.focus.pane.session.Screen().PageUp()
+	case ']':
+		t.focus.pane.session.Screen().PageDown()
+	case 'x':
+		// The pane goes away when its program exits, in paneExited.
+		t.focus.pane.session.Hangup()
+	case 'e':
+		var path string
+		if path, err = t.focus.pane.session.ExportFile(); err == nil {
+			m.message = "exported to " + path
+		}
+	}
+	if err != nil {
+		m.message = err.Error()
+	}
+}
+
+// neighbor returns the pane next to the focused one in the direction of
+// a vi key, lined up with the cursor.
+func neighbor(t *tab, key byte) *node {
+	area := t.focus.area
+	cursor := t.focus.pane.session.Screen().Cursor()
+	y, x := area.top+min(cursor.Y, area.rows-1), area.left+min(cursor.X, area.cols-1)
+	switch key {
+	case 'h':
+		x = area.left - 2
+	case 'l':
+		x = area.left + area.cols + 1
+	case 'k':
+		y = area.top - 2
+	case 'j':
+		y = area.top + area.rows + 1
+	}
+	return t.root.at(y, x)
+}
@@ -0,0 +1,172 @@
+package mux
+
+import "github.com/FelipePn10/kariuki/pkg/session"
+
+// Split is how a pane is divided in two.
+type Split int
+
+const (
+	SideBySide Split = iota // Left and right of a vertical divider
+	Stacked                 // Above and below a horizontal divider
+)
+
+// rect is an area of the host terminal, counted from 0.
+type rect struct {
+	top, left, rows, cols int
+}
+
+func (r rect) contains(y, x int) bool {
+	return y >= r.top && y < r.top+r.rows && x >= r.left && x < r.left+r.cols
+}
+
+// pane is a session shown in part of a tab.
+type pane struct {
+	session *session.Session
+	name    string // Program name, shown when it sets no title
+}
+
+// node is a pane, or a split of its area between two nodes.
+type node struct {
+	parent   *node
+	pane     *pane // Leaves only
+	split    Split
+	ratio    float64 // Share of the first child, without the divider
+	children [2]*node
+	area     rect // Set by layout
+}
+
+func (n *node) leaf() bool {
+	return n.pane != nil
+}
+
+// layout gives n and its children their areas. A divider of one cell
+// separates the two children of a split.
+func (n *node) layout(area rect) {
+	n.area = area
+	if n.leaf() {
+		return
+	}
+	first, second := area, area
+	if n.split == SideBySide {
+		a, b := divide(area.cols, n.ratio)
+		first.cols = a
+		second.left, second.cols = area.left+a+1, b
+	} else {
+		a, b := divide(area.rows, n.ratio)
+		first.rows = a
+		second.top, second.rows = area.top+a+1, b
+	}
+	n.children[0].layout(first)
+	n.children[1].layout(second)
+}
+
+// divide splits size cells, less one for the divider, at ratio. Both parts
+// get at least one cell when there is room.
+func divide(size int, ratio float64) (int, int) {
+	avail := size - 1
+	if avail < 2 {
+		return max(avail, 1), 1
+	}
+	a := min(max(int(ratio*float64(avail)+0.5), 1), avail-1)
+	return a, avail - a
+}
+
+// divider returns the cells between the children of a split.
+func (n *node) divider() rect {
+	first := n.children[0].area
+	if n.split == SideBySide {
+		return rect{top: n.area.top, left: first.left + first.cols, rows: n.area.rows, cols: 1}
+	}
+	return rect{top: first.top + first.rows, left: n.area.left, rows: 1, cols: n.area.cols}
+}
+
+// leaves returns the panes under n, left to right and top to bottom.
+func (n *node) leaves() []*node {
+	if n.leaf() {
+		return []*node{n}
+	}
+	return append(n.children[0].leaves(), n.children[1].leaves()...)
+}
+
+// splits returns the split nodes under n.
+func (n *node) splits() []*node {
+	if n.leaf() {
+		return nil
+	}
+	return append([]*node{n}, append(n.children[0].splits(), n.children[1].splits()...)...)
+}
+
+// splitLeaf turns leaf n into a split with its pane first and p second.
+func (n *node) splitLeaf(split Split, p *pane) *node {
+	first := &node{parent: n, pane: n.pane}
+	second := &node{parent: n, pane: p}
+	n.pane = nil
+	n.split = split
+	n.ratio = 0.5
+	n.children = [2]*node{first, second}
+	return second
+}
+
+// remove takes leaf n out of the tree rooted at root and returns the new
+// root, nil when n was the last pane. Its sibling takes the whole area.
+func (n *node) remove(root *node) *node {
+	parent := n.parent
+	if parent == nil {
+		return nil
+	}
+	sibling := parent.children[0]
+	if sibling == n {
+		sibling = parent.children[1]
+	}
+	sibling.parent = parent.parent
+	if parent.parent == nil {
+		return sibling
+	}
+	grand := parent.parent
+	if grand.children[0] == parent {
+		grand.children[0] = sibling
+	} else {
+		grand.children[1] = sibling
+	}
+	return root
+}
+
+// find returns the leaf showing p, or nil.
+func (n *node) find(p *pane) *node {
+	for _, leaf := range n.leaves() {
+		if leaf.pane == p {
+			return leaf
+		}
+	}
+	return nil
+}
+
+// at returns the leaf whose area holds the cell y, x, or nil.
+func (n *node) at(y, x int) *node {
+	for _, leaf := range n.leaves() {
+		if leaf.area.contains(y, x) {
+			return leaf
+		}
+	}
+	return nil
+}
+
+// resize moves the divider of the closest split of the given kind above
+// leaf n by delta cells.
+func (n *node) resize(split Split, delta int) {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.split != split {
+			continue
+		}
+		size := p.area.cols
+		if split == Stacked {
+			size = p.area.rows
+		}
+		if size < 3 {
+			return
+		}
+		p.ratio += float64(delta) / float64(size-1)
+		p.ratio = min(max(p.ratio, 0), 1)
+		return
+	}
+}
@@ -0,0 +1,304 @@
+// Package mux runs several sessions on one host terminal, in tabs that
+// are split into panes. Keys pressed after the prefix key (PrefixKey)
+// manage them:
+//
+//	c        new tab
+//	n, p     next and previous tab; 1-9 goes to a tab
+//	% or |   split the pane side by side
+//	" or -   split the pane in two stacked panes
+//	o        next pane; h, j, k, l go to the pane left, below, above, right
+//	H, J, K, L  move the divider of the pane
+//	[, ]     page through the pane's scrollback
+//	x        close the pane
+//	e        export the pane's scrollback
+//
+// Pressing the prefix key twice sends it to the program.
+//
+// Pastes are checked as in a single session, and confirmed in the tab bar.
+// Panes ring the host's bell. Programs in panes get no mouse reports and
+// can not set the host clipboard (OSC 52), and links in them are not opened
+// with Ctrl-click: the mux draws the panes from their screens rather than
+// passing program output through.
+package mux
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/asciicast"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/session"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+// maxTabs is how many tabs fit the tab bar's number keys.
+const maxTabs = 9
+
+// tab is a layout of panes, one of which has the keyboard.
+type tab struct {
+	root  *node
+	focus *node
+}
+
+// Mux shows tabs of panes on a host terminal. Its bottom row lists the tabs.
+type Mux struct {
+	argv    []string // Program started in new panes
+	profile color.Profile
+
+	mu         sync.Mutex
+	config     *terminal.TerminalConfig
+	tabs       []*tab
+	active     int
+	rows, cols int // Host terminal size
+	host       io.Writer
+	recorder   *asciicast.Writer
+	prefixed   bool
+	pending    *string // Paste waiting for the user's answer
+	message    string  // Shown in the tab bar until the next key binding
+	modes      hostModes
+	done       chan struct{} // Closed when the last pane is gone
+
+	redraw chan struct{}
+	pasted []byte // Paste read so far; only used by copyInput
+}
+
+func New(config *terminal.TerminalConfig, argv []string) *Mux {
+	if len(argv) == 0 {
+		argv = []string{terminal.DefaultShell()}
+	}
+	return &Mux{
+		argv:    argv,
+		profile: color.TrueColor,
+		config:  config,
+		rows:    config.Rows,
+		cols:    config.Cols,
+		done:    make(chan struct{}),
+		redraw:  make(chan struct{}, 1),
+	}
+}
+
+// SetColorProfile sets what the host terminal can show.
+func (m *Mux) SetColorProfile(profile color.Profile) {
+	m.profile = profile
+}
+
+// SetRecorder records what the host terminal shows from now on.
+func (m *Mux) SetRecorder(rec *asciicast.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recorder = rec
+}
+
+// ApplyConfig updates the settings of every pane.
+func (m *Mux) ApplyConfig(config *terminal.TerminalConfig) {
+	m.mu.Lock()
+	m.config = config
+	var panes []*pane
+	for _, t := range m.tabs {
+		for _, leaf := range t.root.leaves() {
+			panes = append(panes, leaf.pane)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, p := range panes {
+		p.session.ApplyConfig(config)
+	}
+	m.requestRedraw()
+}
+
+// Run starts a first tab and shows the tabs on out, sending keys from in to
+// the focused pane, until every pane is closed.
+func (m *Mux) Run(in io.Reader, out io.Writer) error {
+	m.mu.Lock()
+	m.host = vt.NewColorFilter(out, m.profile)
+	m.writeHost(hostEnter + m.theme())
+	err := m.newTab()
+	m.mu.Unlock()
+	if err != nil {
+		m.leave()
+		return err
+	}
+
+	go m.copyInput(in)
+	go m.drawLoop()
+	<-m.done
+	m.leave()
+	return nil
+}
+
+// leave gives the host terminal back as it was before Run.
+func (m *Mux) leave() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setHostModes(hostModes{})
+	m.writeHost(hostLeave)
+}
+
+// Resize sets the size of the host terminal and lays the panes out again.
+func (m *Mux) Resize(rows, cols int) error {
+	if rows < 2 || cols < 1 {
+		return fmt.Errorf("invalid size %dx%d", cols, rows)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rows, m.cols = rows, cols
+	for _, t := range m.tabs {
+		m.layout(t)
+	}
+	if m.recorder != nil {
+		m.recorder.WriteResize(cols, rows)
+	}
+	m.requestRedraw()
+	return nil
+}
+
+// paneArea is the host area for panes, above the tab bar.
+func (m *Mux) paneArea() rect {
+	return rect{rows: m.rows - 1, cols: m.cols}
+}
+
+// layout sizes the panes of t to the host terminal.
+func (m *Mux) layout(t *tab) {
+	t.root.layout(m.paneArea())
+	for _, leaf := range t.root.leaves() {
+		leaf.pane.session.Resize(leaf.area.rows, leaf.area.cols)
+	}
+}
+
+// startPane starts a session of the given size. The caller holds mu.
+func (m *Mux) startPane(rows, cols int) (*pane, error) {
+	config := *m.config
+	config.Rows, config.Cols = max(rows, 1), max(cols, 1)
+	s := session.NewSession(&config, m.argv)
+	if err := s.Start(); err != nil {
+		return nil, err
+	}
+	p := &pane{session: s, name: filepath.Base(m.argv[0])}
+	s.OnOutput(m.requestRedraw)
+	s.OnBell(func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.writeHost("\a")
+	})
+	go func() {
+		s.Serve()
+		m.paneExited(p)
+	}()
+	return p, nil
+}
+
+// newTab opens a tab with one pane after the others and shows it. The
+// caller holds mu.
+func (m *Mux) newTab() error {
+	if len(m.tabs) == maxTabs {
+		return errors.New("too many tabs")
+	}
+	area := m.paneArea()
+	p, err := m.startPane(area.rows, area.cols)
+	if err != nil {
+		return err
+	}
+	root := &node{pane: p}
+	root.layout(area)
+	m.tabs = append(m.tabs, &tab{root: root, focus: root})
+	m.active = len(m.tabs) - 1
+	m.requestRedraw()
+	return nil
+}
+
+// splitPane splits the focused pane and moves to the new one. The caller
+// holds mu.
+func (m *Mux) splitPane(split Split) error {
+	t := m.tabs[m.active]
+	area := t.focus.area
+	if split == SideBySide && area.cols < 3 || split == Stacked && area.rows < 3 {
+		return errors.New("pane too small to split")
+	}
+	p, err := m.startPane(area.rows, area.cols)
+	if err != nil {
+		return err
+	}
+	t.focus = t.focus.splitLeaf(split, p)
+	m.layout(t)
+	m.requestRedraw()
+	return nil
+}
+
+// paneExited removes the pane of a program that exited, and its tab when
+// it was the last pane there.
+func (m *Mux) paneExited(p *pane) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.tabs {
+		leaf := t.root.find(p)
+		if leaf == nil {
+			continue
+		}
+		t.root = leaf.remove(t.root)
+		if t.root == nil {
+			m.tabs = append(m.tabs[:i], m.tabs[i+1:]...)
+			if m.active >= i && m.active > 0 {
+				m.active--
+			}
+		} else {
+			if t.focus == leaf {
+				t.focus = t.root.leaves()[0]
+			}
+			m.layout(t)
+		}
+		break
+	}
+	if len(m.tabs) == 0 {
+		close(m.done)
+		return
+	}
+	m.requestRedraw()
+}
+
+// focused returns the session that has the keyboard, nil once every pane
+// is gone.
+func (m *Mux) focused() *session.Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.tabs) == 0 {
+		return nil
+	}
+	return m.tabs[m.active].focus.pane.session
+}
+
+// requestRedraw asks drawLoop to draw the host terminal again.
+func (m *Mux) requestRedraw() {
+	select {
+	case m.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// writeHost sends a control sequence of our own to the host terminal. The
+// caller holds mu.
+func (m *Mux) writeHost(seq string) {
+	if m.host == nil {
+		return
+	}
+	io.WriteString(m.host, seq)
+	if m.recorder != nil {
+		m.recorder.Write([]byte(seq))
+	}
+}
+
+// theme sets the host default colors to TextColor and BgColor.
+func (m *Mux) theme() string {
+	var seq string
+	if fg := m.config.Foreground(); !fg.IsDefault() {
+		seq += "\x1b]10;" + fg.Hex(color.Default) + "\x1b\\"
+	}
+	if bg := m.config.Background(); !bg.IsDefault() {
+		seq += "\x1b]11;" + bg.Hex(color.Default) + "\x1b\\"
+	}
+	return seq
+}
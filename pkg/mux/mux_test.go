@@ -0,0 +1,193 @@
+package mux
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayout(t *testing.T) {
+	t.Run("Divide", func(t *testing.T) {
+		a, b := divide(81, 0.5)
+		assert.Equal(t, []int{40, 40}, []int{a, b})
+		a, b = divide(10, 0)
+		assert.Equal(t, []int{1, 8}, []int{a, b})
+		a, b = divide(10, 1)
+		assert.Equal(t, []int{8, 1}, []int{a, b})
+	})
+
+	t.Run("Split and remove", func(t *testing.T) {
+		p1, p2, p3 := &pane{name: "1"}, &pane{name: "2"}, &pane{name: "3"}
+		root := &node{pane: p1}
+		second := root.splitLeaf(SideBySide, p2)
+		third := second.splitLeaf(Stacked, p3)
+		root.layout(rect{rows: 11, cols: 81})
+
+		assert.Equal(t, rect{rows: 11, cols: 40}, root.find(p1).area)
+		assert.Equal(t, rect{left: 41, rows: 5, cols: 40}, root.find(p2).area)
+		assert.Equal(t, rect{top: 6, left: 41, rows: 5, cols: 40}, third.area)
+		assert.Equal(t, rect{left: 40, rows: 11, cols: 1}, root.divider())
+		assert.Equal(t, third, root.at(7, 50))
+		assert.Nil(t, root.at(3, 40))
+
+		third.resize(SideBySide, -10)
+		root.layout(rect{rows: 11, cols: 81})
+		assert.Equal(t, 30, root.find(p1).area.cols)
+
+		root = root.find(p2).remove(root)
+		root.layout(rect{rows: 11, cols: 81})
+		assert.Equal(t, rect{left: 31, rows: 11, cols: 50}, root.find(p3).area)
+
+		root = root.find(p1).remove(root)
+		assert.Same(t, root, root.find(p3))
+		assert.Nil(t, root.remove(root))
+	})
+}
+
+// screenBuffer is the host terminal of a test: output written from
+// several goroutines.
+type screenBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *screenBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *screenBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// panes returns the number of panes of each tab.
+func (m *Mux) panes() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var counts []int
+	for _, t := range m.tabs {
+		counts = append(counts, len(t.root.leaves()))
+	}
+	return counts
+}
+
+func TestMux(t *testing.T) {
+	config := &terminal.TerminalConfig{Rows: 12, Cols: 60, PrefixKey: "C-b"}
+	m := New(config, []string{"sh"})
+	in, keys := io.Pipe()
+	defer keys.Close()
+	var host screenBuffer
+
+	done := make(chan error, 1)
+	go func() { done <- m.Run(in, &host) }()
+	type_ := func(s string) {
+		_, err := io.WriteString(keys, s)
+		require.NoError(t, err)
+	}
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		require.Eventually(t, cond, 5*time.Second, 10*time.Millisecond)
+	}
+
+	waitFor(func() bool { return len(m.panes()) == 1 })
+	type_("echo hi\r")
+	waitFor(func() bool { return strings.Contains(m.focused().Screen().String(), "hi") })
+
+	type_("\x02%")
+	waitFor(func() bool { return assert.ObjectsAreEqual([]int{2}, m.panes()) })
+	rows, cols := m.focused().Screen().Size()
+	assert.Equal(t, []int{11, 29}, []int{rows, cols})
+
+	type_("\x02c")
+	waitFor(func() bool { return assert.ObjectsAreEqual([]int{2, 1}, m.panes()) })
+	waitFor(func() bool { return strings.Contains(host.String(), "2:sh") })
+
+	type_("exit\r")
+	waitFor(func() bool { return assert.ObjectsAreEqual([]int{2}, m.panes()) })
+	waitFor(func() bool { return strings.Contains(host.String(), "│") })
+
+	type_("\x02x")
+	waitFor(func() bool { return assert.ObjectsAreEqual([]int{1}, m.panes()) })
+	type_("exit\r")
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mux did not exit")
+	}
+	assert.True(t, strings.HasSuffix(host.String(), hostLeave))
+}
+
+func TestMuxHost(t *testing.T) {
+	config := &terminal.TerminalConfig{Rows: 12, Cols: 60, PrefixKey: "C-b", BellSound: "audible", BlockedCommands: []string{"rm -rf /"}}
+	m := New(config, []string{"sh"})
+	in, keys := io.Pipe()
+	defer keys.Close()
+	var host screenBuffer
+
+	done := make(chan error, 1)
+	go func() { done <- m.Run(in, &host) }()
+	type_ := func(s string) {
+		_, err := io.WriteString(keys, s)
+		require.NoError(t, err)
+	}
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		require.Eventually(t, cond, 5*time.Second, 10*time.Millisecond)
+	}
+	screen := func() string { return m.focused().Screen().String() }
+	// hostSince returns what the host got after a call to mark.
+	var marked int
+	mark := func() { marked = len(host.String()) }
+	hostSince := func() string { return host.String()[marked:] }
+	waitFor(func() bool { return len(m.panes()) == 1 })
+	waitFor(func() bool { return strings.ContainsAny(screen(), "$#") })
+	assert.Contains(t, host.String(), "\x1b[?2004h", "pastes are bracketed")
+
+	// A paste on one line is sent as is.
+	type_("\x1b[200~echo one\x1b[201~\r")
+	waitFor(func() bool { return strings.Contains(screen(), "\none") })
+
+	// One that runs commands right away waits for y in the tab bar.
+	type_("\x1b[200~echo two\necho three\n\x1b[201~")
+	waitFor(func() bool { return strings.Contains(host.String(), "paste 2 line(s)? [y/N]") })
+	type_("n")
+	type_("\x1b[200~rm -rf /\x1b[201~")
+	waitFor(func() bool {
+		return strings.Contains(host.String(), `blocked command "rm -rf /"; paste 1 line(s)? [y/N]`)
+	})
+	mark()
+	type_("n")
+	type_("\x1b[200~echo four\necho five\n\x1b[201~")
+	waitFor(func() bool { return strings.Contains(hostSince(), "paste 2 line(s)? [y/N]") })
+	type_("y")
+	waitFor(func() bool { return strings.Count(screen(), "five") == 2 })
+	assert.NotContains(t, screen(), "two")
+	assert.NotContains(t, screen(), "rm -rf")
+
+	// The bell rings on the host; clipboard requests do not reach it.
+	mark()
+	type_("printf 'x\\a\\033]52;c;aGk=\\a'; echo rang\r")
+	waitFor(func() bool { return strings.Contains(screen(), "\nxrang") })
+	waitFor(func() bool { return strings.Contains(hostSince(), "\a") })
+	assert.NotContains(t, host.String(), "\x1b]52;")
+
+	type_("exit\r")
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mux did not exit")
+	}
+}
@@ -0,0 +1,102 @@
+// Package paste checks pasted text before it reaches a shell.
+package paste
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Start and End delimit a bracketed paste (DECSET 2004).
+	Start = "\x1b[200~"
+	End   = "\x1b[201~"
+)
+
+// Warning is a reason to confirm a paste before it runs.
+type Warning struct {
+	Line   int // Zero based line of the paste, -1 for the whole paste
+	Reason string
+}
+
+// Report is the result of checking a paste.
+type Report struct {
+	Lines     []string
+	MultiLine bool // Contains a newline, so the shell runs it without Enter
+	Warnings  []Warning
+}
+
+// NeedsConfirmation reports whether the user should see the paste first.
+func (r Report) NeedsConfirmation() bool {
+	return r.MultiLine || len(r.Warnings) > 0
+}
+
+// Check looks for newlines, control characters and blocked commands in text.
+func Check(text string, blocked []string) Report {
+	var r Report
+	r.MultiLine = strings.ContainsAny(text, "\r\n")
+
+	normalized := strings.ReplaceAll(text, "\r\n", "\n")
+	normalized = strings.ReplaceAll(normalized, "\r", "\n")
+	r.Lines = strings.Split(strings.TrimSuffix(normalized, "\n"), "\n")
+
+	for i, line := range r.Lines {
+		if hasControl(line) {
+			r.Warnings = append(r.Warnings, Warning{Line: i, Reason: "control characters"})
+		}
+		for _, cmd := range blocked {
+			if MatchesCommand(line, cmd) {
+				r.Warnings = append(r.Warnings, Warning{Line: i, Reason: fmt.Sprintf("blocked command %q", cmd)})
+			}
+		}
+	}
+	return r
+}
+
+// Bracket wraps text in bracketed paste delimiters. An end delimiter
+// inside the text is removed so the paste cannot end early and have the
+// rest run as typed input.
+func Bracket(text string) string {
+	return Start + Sanitize(text) + End
+}
+
+// Sanitize removes paste delimiters from text.
+func Sanitize(text string) string {
+	text = strings.ReplaceAll(text, End, "")
+	return strings.ReplaceAll(text, Start, "")
+}
+
+func hasControl(line string) bool {
+	for _, r := range line {
+		if (r < 0x20 && r != '\t') || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesCommand reports whether cmd appears in line as whole words,
+// ignoring differences in spacing: "rm  -rf /" matches "rm -rf /", but
+// "rm -rf /tmp/x" does not. "mkfs" also matches "mkfs.ext4".
+func MatchesCommand(line, cmd string) bool {
+	line = strings.Join(strings.Fields(line), " ")
+	cmd = strings.Join(strings.Fields(cmd), " ")
+	if cmd == "" {
+		return false
+	}
+	for from := 0; ; {
+		i := strings.Index(line[from:], cmd)
+		if i < 0 {
+			return false
+		}
+		i += from
+		end := i + len(cmd)
+		if (i == 0 || isBoundary(line[i-1])) && (end == len(line) || isBoundary(line[end]) || line[end] == '*' || line[end] == '.') {
+			return true
+		}
+		from = i + 1
+	}
+}
+
+func isBoundary(c byte) bool {
+	return strings.IndexByte(" ;&|()`\"'", c) >= 0
+}
@@ -0,0 +1,48 @@
+package paste_test
+
+import (
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/paste"
+	"github.com/stretchr/testify/assert"
+)
+
+var blocked = []string{"rm -rf /", "mkfs"}
+
+func TestCheck(t *testing.T) {
+	t.Run("Single line", func(t *testing.T) {
+		r := paste.Check("ls -la", blocked)
+		assert.False(t, r.NeedsConfirmation())
+		assert.Equal(t, []string{"ls -la"}, r.Lines)
+	})
+
+	t.Run("Multi-line", func(t *testing.T) {
+		r := paste.Check("cd /tmp\r\nls\n", blocked)
+		assert.True(t, r.MultiLine)
+		assert.True(t, r.NeedsConfirmation())
+		assert.Equal(t, []string{"cd /tmp", "ls"}, r.Lines)
+		assert.Empty(t, r.Warnings)
+	})
+
+	t.Run("Trailing newline runs immediately", func(t *testing.T) {
+		assert.True(t, paste.Check("ls\n", blocked).NeedsConfirmation())
+	})
+
+	t.Run("Blocked command", func(t *testing.T) {
+		r := paste.Check("echo ok\nsudo  rm -rf / --no-preserve-root", blocked)
+		assert.Equal(t, []paste.Warning{{Line: 1, Reason: `blocked command "rm -rf /"`}}, r.Warnings)
+
+		assert.Len(t, paste.Check("x; rm -rf /*", blocked).Warnings, 1)
+		assert.Len(t, paste.Check("mkfs.ext4 /dev/sdb1", blocked).Warnings, 1)
+		assert.Empty(t, paste.Check("rm -rf /tmp/build", blocked).Warnings)
+	})
+
+	t.Run("Control characters", func(t *testing.T) {
+		r := paste.Check("ls\x1b[201~ rm", nil)
+		assert.Equal(t, []paste.Warning{{Line: 0, Reason: "control characters"}}, r.Warnings)
+	})
+}
+
+func TestBracket(t *testing.T) {
+	assert.Equal(t, "\x1b[200~ab\x1b[201~", paste.Bracket("a\x1b[201~b"))
+}
@@ -0,0 +1,135 @@
+// Package policy decides which command lines a restricted session may run.
+//
+// The check reads shell command lines, it does not sandbox the programs
+// they start: an allowed program that runs others (an editor, a pager
+// with a shell escape) can run anything the account may. Give restricted
+// users accounts that limit what they can do, and let the policy guard
+// against mistakes and keep the audit log honest.
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FelipePn10/kariuki/pkg/paste"
+)
+
+// Policy limits the command lines of a session.
+type Policy struct {
+	Allowed []string // Programs that may run, by name or path; any when empty
+	Blocked []string // Commands refused wherever they appear, as with pastes
+}
+
+// shellVariables are variables whose assignment makes the shell run other
+// programs or read its command lines differently, so setting one counts as
+// a program, as PATH and LD_ variables do.
+var shellVariables = map[string]bool{
+	"PATH": true, "PROMPT_COMMAND": true, "PS0": true, "PS1": true, "PS2": true,
+	"PS3": true, "PS4": true, "ENV": true, "BASH_ENV": true, "IFS": true,
+	"SHELLOPTS": true, "BASHOPTS": true,
+}
+
+// keywords are shell words that come before the program of a command.
+var keywords = map[string]bool{
+	"!": true, "if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true, "time": true,
+}
+
+// Restricted reports whether p refuses anything.
+func (p Policy) Restricted() bool {
+	return len(p.Allowed) > 0 || len(p.Blocked) > 0
+}
+
+// Check returns why line may not run, or nil.
+func (p Policy) Check(line string) error {
+	for _, cmd := range p.Blocked {
+		if paste.MatchesCommand(line, cmd) {
+			return fmt.Errorf("%q is blocked", cmd)
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return nil
+	}
+	// Substitutions run commands the check can not see.
+	for _, s := range []string{"`", "$(", "<(", ">("} {
+		if strings.Contains(line, s) {
+			return fmt.Errorf("%q is not allowed", s)
+		}
+	}
+	for _, command := range splitCommands(line) {
+		program, assignment := commandProgram(command)
+		if program == "" && assignment != "" {
+			// Variables set for the shell itself stay set.
+			return fmt.Errorf("%q is not allowed", assignment)
+		}
+		if program != "" && !p.allowed(program) {
+			return fmt.Errorf("%q is not allowed", program)
+		}
+	}
+	return nil
+}
+
+func (p Policy) allowed(program string) bool {
+	for _, a := range p.Allowed {
+		if program == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Programs returns the program of each command of line: the first word
+// after separators, leaving out variable assignments and keywords such as
+// if and do. Quotes are kept, so a quoted program name matches nothing.
+// Setting PATH, LD_ variables or variables the shell runs, such as
+// PROMPT_COMMAND, changes which program runs, so such an assignment counts
+// as the program.
+func Programs(line string) []string {
+	var programs []string
+	for _, command := range splitCommands(line) {
+		if program, _ := commandProgram(command); program != "" {
+			programs = append(programs, program)
+		}
+	}
+	return programs
+}
+
+// splitCommands splits line at the separators of shell commands.
+func splitCommands(line string) []string {
+	return strings.FieldsFunc(line, func(r rune) bool {
+		return strings.ContainsRune(";&|(){}\n", r)
+	})
+}
+
+// commandProgram returns the program of a command and its first variable
+// assignment; the program is "" for a command that only sets variables.
+func commandProgram(command string) (program, assignment string) {
+	for _, word := range strings.Fields(command) {
+		if keywords[word] {
+			continue
+		}
+		if isAssignment(word) {
+			if assignment == "" {
+				assignment = word
+			}
+			continue
+		}
+		return word, assignment
+	}
+	return "", assignment
+}
+
+// isAssignment reports whether word sets a variable, as in LANG=C ls,
+// other than one of the loader and the shell variables.
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" || shellVariables[name] || strings.HasPrefix(name, "LD_") {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && (i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrograms(t *testing.T) {
+	assert.Equal(t, []string{"ls", "grep", "cat"}, policy.Programs("ls -l | grep x && LANG=C cat y"))
+	assert.Equal(t, []string{"test", "echo"}, policy.Programs("if test -f x; then echo y; fi"))
+	assert.Equal(t, []string{"'rm'"}, policy.Programs("  'rm' -rf x"))
+	assert.Empty(t, policy.Programs("A=1 ;"))
+	assert.Equal(t, []string{"PROMPT_COMMAND=sh"}, policy.Programs("PROMPT_COMMAND=sh"))
+}
+
+func TestCheck(t *testing.T) {
+	p := policy.Policy{Allowed: []string{"ls", "cat", "/usr/bin/tail"}, Blocked: []string{"rm -rf /"}}
+	assert.True(t, p.Restricted())
+	assert.False(t, policy.Policy{}.Restricted())
+
+	for _, line := range []string{"", "ls -l", "cat a | /usr/bin/tail -n 3", "ls; cat x > y"} {
+		assert.NoError(t, p.Check(line), line)
+	}
+	for line, reason := range map[string]string{
+		"rm -rf /":          `"rm -rf /" is blocked`,
+		"ls; vi x":          `"vi" is not allowed`,
+		"tail x":            `"tail" is not allowed`,
+		"cat $(cat list)":   `"$(" is not allowed`,
+		"ls `whoami`":       `"` + "`" + `" is not allowed`,
+		"ls & sh":           `"sh" is not allowed`,
+		"LANG=C ls x || sh": `"sh" is not allowed`,
+		"PATH=. ls":         `"PATH=." is not allowed`,
+		"PROMPT_COMMAND=sh": `"PROMPT_COMMAND=sh" is not allowed`,
+		"BASH_ENV=/tmp/x":   `"BASH_ENV=/tmp/x" is not allowed`,
+		"ENV=x ls":          `"ENV=x" is not allowed`,
+		"PS1=x; ls":         `"PS1=x" is not allowed`,
+		"IFS=/ ls":          `"IFS=/" is not allowed`,
+		"SHELLOPTS=x":       `"SHELLOPTS=x" is not allowed`,
+		"BASHOPTS=x":        `"BASHOPTS=x" is not allowed`,
+		"A=1; ls":           `"A=1" is not allowed`,
+		"ls; LANG=C":        `"LANG=C" is not allowed`,
+	} {
+		assert.EqualError(t, p.Check(line), reason, line)
+	}
+
+	// Without a list of allowed programs only blocked commands are refused.
+	p = policy.Policy{Blocked: []string{"mkfs"}}
+	assert.NoError(t, p.Check("vi $(ls)"))
+	assert.Error(t, p.Check("mkfs.ext4 /dev/sda1"))
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"io"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+// Attach shows a session started with Serve on a host terminal that did
+// not see its output so far, as when a client reattaches to a daemon: the
+// host gets the program's modes and the screen is repainted, then program
+// output is passed through to out and input read from in goes to the
+// program. It returns when in ends or the user detaches with the prefix
+// key and d, and reports which.
+func (s *Session) Attach(in io.Reader, out io.Writer) (detached bool) {
+	s.attach(out, true)
+	defer s.detach()
+
+	// Input state left by an earlier client, which may have gone away
+	// in the middle of a paste.
+	s.paste, s.prefixed = pasteState{}, false
+	s.detachable, s.detaching = true, false
+	defer func() { s.detachable = false }()
+	s.copyInput(in)
+	return s.detaching
+}
+
+// attach makes out the host terminal, optionally giving it the program's
+// modes and screen first.
+func (s *Session) attach(out io.Writer, repaint bool) {
+	s.hostMu.Lock()
+	s.host = vt.NewColorFilter(vt.NewQueryFilter(out), s.profile)
+	s.clipFilter = vt.NewOSCFilter(s.host, 52, s.allowClipboard, s.clipboard.EncodedLimit())
+	s.hostOutput = vt.NewBellFilter(s.clipFilter)
+	s.hostMouse = false
+	s.confirming = false
+	// The host answers queries now; they reach the program as input.
+	s.screen.SetReplyWriter(nil)
+	if repaint {
+		io.WriteString(s.host, s.screen.ModeSequence())
+		s.screen.Render(s.host)
+	}
+	s.hostMu.Unlock()
+
+	s.writeTheme()
+	s.mu.Lock()
+	mouse := s.config.EnableMouse
+	s.mu.Unlock()
+	if mouse {
+		s.setHostMouse(true)
+	}
+	s.writeCursorStyle()
+	s.writeHost(hostPasteOn)
+}
+
+// detach gives the host terminal its modes back and stops writing to it.
+// The screen model answers the program's queries until the next attach.
+func (s *Session) detach() {
+	s.writeHost(hostPasteOff + hostCursorDefault)
+	s.setHostMouse(false)
+	s.writeHost(hostThemeDefault)
+	if s.screen.AltScreen() {
+		s.writeHost("\x1b[?1049l")
+	}
+
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.host, s.clipFilter, s.hostOutput = nil, nil, nil
+	s.confirming = false
+	s.screen.SetReplyWriter(s)
+}
@@ -0,0 +1,52 @@
+package session
+
+import "io"
+
+// OnBell sets a function called when the audible bell rings while no host
+// terminal is attached, so what shows the screen, such as the mux, can
+// ring its own.
+func (s *Session) OnBell(fn func()) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.onBell = fn
+}
+
+// ring passes the audible bell to the host terminal, or to OnBell.
+func (s *Session) ring() {
+	s.hostMu.Lock()
+	onBell := s.onBell
+	if s.host != nil {
+		io.WriteString(s.host, "\a")
+		onBell = nil
+	}
+	s.hostMu.Unlock()
+	if onBell != nil {
+		onBell()
+	}
+}
+
+// flash turns the visual bell on or off. The host terminal shows it with
+// reverse video (DECSCNM), which is undone if the program had set it.
+// Without a host, OnOutput is told, so what shows the screen draws it.
+func (s *Session) flash(on bool) {
+	s.screen.SetVisualBell(on)
+	s.hostMu.Lock()
+	onOutput := s.onOutput
+	if s.host != nil {
+		onOutput = nil
+	}
+	s.hostMu.Unlock()
+	if onOutput != nil {
+		onOutput()
+		return
+	}
+	if s.screen.ViewOffset() > 0 {
+		s.repaint()
+		return
+	}
+	if on == s.screen.PrivateMode(5) {
+		s.writeHost("\x1b[?5l")
+	} else {
+		s.writeHost("\x1b[?5h")
+	}
+}
@@ -0,0 +1,19 @@
+package session
+
+import "github.com/FelipePn10/kariuki/pkg/clipboard"
+
+// setClipboardPolicy changes which OSC 52 requests reach the host.
+func (s *Session) setClipboardPolicy(policy clipboard.Policy) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.clipboard = policy
+	if s.clipFilter != nil {
+		s.clipFilter.SetLimit(policy.EncodedLimit())
+	}
+}
+
+// allowClipboard decides on an OSC 52 request from the program. It runs
+// while copyOutput holds hostMu.
+func (s *Session) allowClipboard(payload string) bool {
+	return s.clipboard.Allow(payload)
+}
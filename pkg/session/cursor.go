@@ -0,0 +1,106 @@
+package session
+
+import (
+	"io"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"golang.org/x/sys/unix"
+)
+
+// foregroundInterval is the least time between two checks of the foreground
+// process group of the PTY, an ioctl that output would otherwise cost.
+const foregroundInterval = 100 * time.Millisecond
+
+const (
+	// hostCursorDefault asks the host terminal for its own default cursor.
+	hostCursorDefault = "\x1b[0 q"
+	// hostThemeDefault resets the host foreground and background (OSC 110/111).
+	hostThemeDefault = "\x1b]110\x1b\\\x1b]111\x1b\\"
+)
+
+// writeTheme sets the host default colors to TextColor and BgColor.
+func (s *Session) writeTheme() {
+	fg, bg := s.screen.DefaultColors()
+	var seq string
+	if !fg.IsDefault() {
+		seq += "\x1b]10;" + fg.Hex(color.Default) + "\x1b\\"
+	}
+	if !bg.IsDefault() {
+		seq += "\x1b]11;" + bg.Hex(color.Default) + "\x1b\\"
+	}
+	if seq != "" {
+		s.writeHost(seq)
+	}
+}
+
+// writeCursorStyle shows the screen's cursor style on the host terminal.
+func (s *Session) writeCursorStyle() {
+	shape, blink := s.screen.CursorStyle()
+	s.writeHost(vt.CursorStyleSequence(shape, blink))
+}
+
+// checkForeground restores the configured cursor style when the shell gets
+// the terminal back from a program (such as vi) that changed the cursor and
+// did not reset it. It looks at most every foregroundInterval, once more
+// after output that came too soon. The caller holds hostMu.
+func (s *Session) checkForeground() {
+	if s.fgStopped {
+		return
+	}
+	if wait := foregroundInterval - time.Since(s.fgChecked); wait > 0 {
+		if s.fgTimer == nil {
+			s.fgTimer = time.AfterFunc(wait, func() {
+				s.hostMu.Lock()
+				defer s.hostMu.Unlock()
+				s.fgTimer = nil
+				s.checkForeground()
+			})
+		}
+		return
+	}
+	s.fgChecked = time.Now()
+
+	pgrp := s.foregroundGroup()
+	if pgrp == 0 || pgrp == s.fgGroup {
+		return
+	}
+	previous := s.fgGroup
+	s.fgGroup = pgrp
+
+	if previous != 0 && pgrp == s.Pid() && s.screen.CursorStyleOverridden() {
+		s.screen.ResetCursorStyle()
+		if s.host != nil {
+			shape, blink := s.screen.CursorStyle()
+			io.WriteString(s.host, vt.CursorStyleSequence(shape, blink))
+		}
+	}
+}
+
+// stopForegroundChecks cancels a pending checkForeground and any later one.
+func (s *Session) stopForegroundChecks() {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.fgStopped = true
+	if s.fgTimer != nil {
+		s.fgTimer.Stop()
+		s.fgTimer = nil
+	}
+}
+
+// foregroundGroup returns the foreground process group of the PTY, 0 if unknown.
+func (s *Session) foregroundGroup() int {
+	conn, err := s.ptmx.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var pgrp int
+	conn.Control(func(fd uintptr) {
+		pgrp, err = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	})
+	if err != nil {
+		return 0
+	}
+	return pgrp
+}
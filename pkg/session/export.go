@@ -0,0 +1,73 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/export"
+)
+
+// maxExports is how many exports ExportFile numbers within one second.
+const maxExports = 100
+
+// Export writes the selection, or the scrollback and screen when nothing
+// is selected. Colors the program left at the default are those of the
+// configured theme, whatever the program set with OSC 10 and 11.
+func (s *Session) Export(w io.Writer, f export.Format) error {
+	lines := s.screen.SelectedLines()
+	if lines == nil {
+		lines = s.screen.History()
+	}
+	s.mu.Lock()
+	theme := export.Theme{Fg: s.config.Foreground(), Bg: s.config.Background()}
+	s.mu.Unlock()
+	return export.Write(w, lines, f, theme)
+}
+
+// ExportFile exports in the configured format to a new file next to the
+// recordings and returns its path. Exports in the same second are
+// numbered, e.g. kariuki-20250102-150405-2.html.
+func (s *Session) ExportFile() (string, error) {
+	s.mu.Lock()
+	f := s.config.Export()
+	path := s.config.ExportPath(terminal.AppName, f, time.Now())
+	s.mu.Unlock()
+
+	base := strings.TrimSuffix(path, f.Extension())
+	for n := 2; ; n++ {
+		err := writeExport(path, func(w io.Writer) error { return s.Export(w, f) })
+		if !errors.Is(err, fs.ErrExist) || n > maxExports {
+			return path, err
+		}
+		path = fmt.Sprintf("%s-%d%s", base, n, f.Extension())
+	}
+}
+
+// exportFile runs ExportFile and tells the user where the export went.
+func (s *Session) exportFile() {
+	path, err := s.ExportFile()
+	if err != nil {
+		s.notify(fmt.Sprintf("export failed: %v", err))
+		return
+	}
+	s.notify("exported to " + path)
+}
+
+// writeExport creates path, never replacing an existing file.
+func writeExport(path string, write func(io.Writer) error) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,198 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/paste"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+// copyOutput feeds program output to the screen model and the host terminal
+// until the program side of the PTY is closed.
+func (s *Session) copyOutput() error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.output.Read(buf)
+		if n > 0 {
+			s.touch()
+			s.lastOutput.Store(time.Now().UnixNano())
+			s.hostMu.Lock()
+			s.screen.Write(buf[:n])
+			if s.recorder != nil {
+				s.recorder.Write(buf[:n])
+			}
+			// While the user looks at the scrollback the host shows our own
+			// rendering, and a paste confirmation hides the screen; the
+			// live screen is repainted when they return.
+			if s.hostOutput != nil && s.screen.ViewOffset() == 0 && !s.confirming {
+				s.hostOutput.Write(buf[:n])
+			}
+			s.writeStatus()
+			s.checkForeground()
+			onOutput := s.onOutput
+			s.hostMu.Unlock()
+
+			if s.screen.TakeBell() {
+				s.bell.Ring()
+			}
+			if onOutput != nil {
+				onOutput()
+			}
+		}
+		if err != nil {
+			// Reading the master returns EIO once the child side is closed.
+			if errors.Is(err, syscall.EIO) || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// copyInput sends host input to the program. Mouse reports from the host
+// are taken out of the stream and handled by handleMouse.
+func (s *Session) copyInput(in io.Reader) {
+	buf := make([]byte, 4096)
+	var pending []byte
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			pending = s.filterInput(append(pending, buf[:n]...))
+		}
+		if s.detaching {
+			return
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				s.sendInput(pending)
+			}
+			return
+		}
+	}
+}
+
+// filterInput writes input to the program and returns an incomplete
+// sequence that must wait for the next read.
+func (s *Session) filterInput(data []byte) []byte {
+	for len(data) > 0 && !s.detaching {
+		var n int
+		switch {
+		case s.paste.active:
+			n = s.readPaste(data)
+		case s.paste.pending != nil:
+			s.answerPaste(data[0])
+			n = 1
+		case s.prefixed:
+			s.prefixed = false
+			s.runBinding(data[0])
+			n = 1
+		default:
+			n = s.readKeys(data)
+		}
+		if n == 0 {
+			return append([]byte(nil), data...)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// readKeys sends typed input to the program up to the next paste, mouse
+// report, prefix key or, under a policy, key that runs the command line,
+// which it handles. It returns how many bytes of data it used, 0 when data
+// starts with an incomplete sequence.
+func (s *Session) readKeys(data []byte) int {
+	mouse := s.mouseEnabled()
+	prefix := s.prefixKey()
+	restricted := s.commandPolicy().Restricted()
+	for i := 0; i < len(data); i++ {
+		if data[i] == prefix {
+			if i > 0 {
+				s.sendInput(data[:i])
+				return i
+			}
+			s.prefixed = true
+			return 1
+		}
+		if restricted && isAcceptKey(data[i]) {
+			if i > 0 {
+				s.sendInput(data[:i])
+				return i
+			}
+			s.acceptLine(data[i])
+			return 1
+		}
+		if data[i] != 0x1b {
+			continue
+		}
+		var ev vt.MouseEvent
+		n := parsePasteStart(data[i:])
+		isPaste := n != 0
+		if !isPaste && mouse {
+			ev, n = parseMouse(data[i:])
+		}
+		switch {
+		case n == 0:
+			continue
+		case i > 0:
+			s.sendInput(data[:i])
+			return i
+		case n < 0:
+			return 0
+		case isPaste:
+			s.paste.active = true
+		default:
+			s.handleMouse(ev)
+		}
+		return n
+	}
+	s.sendInput(data)
+	return len(data)
+}
+
+// parsePasteStart returns the length of a paste start delimiter at the
+// start of data, -1 if data may be the beginning of one and 0 otherwise.
+// Like parseMouse, it does not hold back a lone Esc key.
+func parsePasteStart(data []byte) int {
+	switch {
+	case bytes.HasPrefix(data, []byte(paste.Start)):
+		return len(paste.Start)
+	case len(data) >= len("\x1b[2") && bytes.HasPrefix([]byte(paste.Start), data):
+		return -1
+	}
+	return 0
+}
+
+// sendInput writes to the program, converting to its encoding.
+func (s *Session) sendInput(p []byte) (int, error) {
+	s.inputMu.Lock()
+	defer s.inputMu.Unlock()
+	if s.input == nil {
+		return 0, errors.New("session not started")
+	}
+	s.touch()
+	s.lastInput.Store(time.Now().UnixNano())
+	return s.input.Write(p)
+}
+
+// writeHost sends a control sequence of our own to the host terminal.
+func (s *Session) writeHost(seq string) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	if s.host != nil {
+		io.WriteString(s.host, seq)
+	}
+}
+
+// repaint draws the screen model on the host terminal.
+func (s *Session) repaint() {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	if s.host != nil {
+		s.screen.Render(s.host)
+	}
+}
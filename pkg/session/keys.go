@@ -0,0 +1,50 @@
+package session
+
+import (
+	"fmt"
+	"time"
+)
+
+// notifyTime is how long a message from notify stays on the screen.
+const notifyTime = 3 * time.Second
+
+// prefixKey returns the key that starts kariuki's key bindings.
+func (s *Session) prefixKey() byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.Prefix()
+}
+
+// runBinding handles the key pressed after the prefix key. The prefix key
+// itself is sent to the program; unbound keys are dropped.
+func (s *Session) runBinding(key byte) {
+	switch key {
+	case s.prefixKey():
+		s.sendInput([]byte{key})
+	case 'e':
+		s.exportFile()
+	case 'p':
+		s.jumpPrompt(false)
+	case 'n':
+		s.jumpPrompt(true)
+	case 'o':
+		s.copyCommandOutput()
+	case 'd':
+		s.detaching = s.detachable
+	}
+}
+
+// notify shows a message on the bottom row of the host terminal until the
+// screen is repainted a few seconds later.
+func (s *Session) notify(msg string) {
+	rows, cols := s.screen.Size()
+	s.writeHost(fmt.Sprintf("\x1b7\x1b[%d;1H\x1b[0;7m\x1b[2K %s \x1b[0m\x1b8",
+		rows, truncate(printable("kariuki: "+msg), cols-2)))
+	time.AfterFunc(notifyTime, func() {
+		s.hostMu.Lock()
+		defer s.hostMu.Unlock()
+		if s.host != nil && !s.confirming {
+			s.screen.Render(s.host)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"time"
+)
+
+// limitCheckInterval is the longest time between two checks of the session
+// limits, so changed settings apply soon.
+const limitCheckInterval = time.Minute
+
+// touch records input or output for InactivityClose.
+func (s *Session) touch() {
+	s.lastActivity.Store(time.Now().UnixNano())
+}
+
+// checkLimits hangs up the program once the session has run for
+// MaxSessionTime, or nothing was typed or printed for InactivityClose, and
+// otherwise checks again when the first of them could be reached. Zero
+// turns a limit off. The limits count whether a host is attached or not.
+func (s *Session) checkLimits() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	now := time.Now()
+	next := limitCheckInterval
+	reason := ""
+	if limit := s.config.MaxSessionTime; limit > 0 {
+		left := s.started.Add(limit).Sub(now)
+		if left <= 0 {
+			reason = "maximum session time reached"
+		}
+		next = min(next, left)
+	}
+	if limit := s.config.InactivityClose; limit > 0 {
+		left := time.Unix(0, s.lastActivity.Load()).Add(limit).Sub(now)
+		if left <= 0 {
+			reason = "closed after inactivity"
+		}
+		next = min(next, left)
+	}
+	if reason != "" {
+		go s.expire(reason)
+		return
+	}
+	s.limitTimer = time.AfterFunc(next, s.checkLimits)
+}
+
+// expire tells the user why the session ends and hangs up the program.
+func (s *Session) expire(reason string) {
+	s.writeHost("\r\n\x1b[0;7m kariuki: " + reason + " \x1b[0m\r\n")
+	s.Hangup()
+}
+
+// Idle returns how long nothing was typed or printed.
+func (s *Session) Idle() time.Duration {
+	return time.Since(time.Unix(0, s.lastActivity.Load()))
+}
+
+// TimeLeft returns how long the session may still run before
+// MaxSessionTime ends it, and false when there is no limit.
+func (s *Session) TimeLeft() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := s.config.MaxSessionTime
+	if limit <= 0 || s.started.IsZero() {
+		return 0, false
+	}
+	return max(s.started.Add(limit).Sub(time.Now()), 0), true
+}
@@ -0,0 +1,36 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+// LinkTarget returns what a link points to: the URI of OSC 8 links and
+// URLs, or the absolute path of a file link. Paths starting with ~/ are in
+// the home directory; other relative paths are taken from the working
+// directory of the program in the foreground, which printed them.
+func (s *Session) LinkTarget(l vt.Link) string {
+	if l.Kind != vt.LinkFile || filepath.IsAbs(l.URI) {
+		return l.URI
+	}
+	if rest, ok := strings.CutPrefix(l.URI, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return l.URI
+		}
+		return filepath.Join(home, rest)
+	}
+	pid := s.foregroundGroup()
+	if pid == 0 {
+		pid = s.Pid()
+	}
+	cwd, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	if err != nil {
+		return l.URI
+	}
+	return filepath.Join(cwd, l.URI)
+}
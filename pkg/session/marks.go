@@ -0,0 +1,99 @@
+package session
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+// UsePrompt starts the shell with the prompt setting as its prompt, marked
+// with OSC 133 so the screen knows where each command and its output are,
+// and with the exit status of the last command. bash and zsh also mark
+// where the output starts; for other shells it starts below the command
+// line.
+//
+// NewSession calls it for the default shell when the shell_prompt
+// setting is on.
+func (s *Session) UsePrompt() {
+	if s.prompted {
+		return
+	}
+	s.prompted = true
+	prompt := s.config.Prompt
+	switch filepath.Base(s.argv[0]) {
+	case "bash":
+		s.SetEnv(`PS1=\[\e]133;D;$?\a\e]133;A\a\]`+prompt+`\[\e]133;B\a\]`, `PS0=\e]133;C\a`)
+	case "zsh":
+		s.SetEnv(`PS1=%{`+"\x1b]133;D;%?\a\x1b]133;A\a"+`%}`+prompt+`%{`+"\x1b]133;B\a"+`%}`, "POSTEDIT=\x1b]133;C\a")
+	default:
+		s.SetEnv("PS1=\x1b]133;D;$?\a\x1b]133;A\a" + prompt + "\x1b]133;B\a")
+	}
+}
+
+// writeStatus draws the exit status of a command that just ended next to
+// its prompt, if the host shows the live screen. The caller holds hostMu.
+func (s *Session) writeStatus() {
+	prompt, ok := s.screen.TakeEnded()
+	if !ok || s.host == nil || s.screen.ViewOffset() > 0 || s.confirming {
+		return
+	}
+	if seq := s.screen.StatusSequence(prompt); seq != "" {
+		io.WriteString(s.host, "\x1b7"+seq+"\x1b8")
+	}
+}
+
+// jumpPrompt scrolls the view to the prompt above the top of the view, or
+// with next to the one below it, back to the live screen after the last.
+func (s *Session) jumpPrompt(next bool) {
+	top := s.screen.TopRow()
+	commands := s.screen.Commands()
+	if next {
+		s.screen.ScrollViewToBottom()
+		for _, c := range commands {
+			if c.Prompt.Row > top {
+				s.screen.ScrollViewToRow(c.Prompt.Row)
+				break
+			}
+		}
+		s.repaint()
+		return
+	}
+	for i := len(commands) - 1; i >= 0; i-- {
+		if commands[i].Prompt.Row < top {
+			s.screen.ScrollViewToRow(commands[i].Prompt.Row)
+			s.repaint()
+			return
+		}
+	}
+	s.notify("no earlier prompt")
+}
+
+// copyCommandOutput puts the output of the last command that ended on the
+// host clipboard, or of the command at the top of the view when scrolled
+// back.
+func (s *Session) copyCommandOutput() {
+	var command vt.Command
+	found := false
+	scrolled := s.screen.ViewOffset() > 0
+	top := s.screen.TopRow()
+	for _, c := range s.screen.Commands() {
+		if scrolled && c.Prompt.Row > top {
+			break
+		}
+		if c.Finished() {
+			command, found = c, true
+		}
+	}
+	if !found {
+		s.notify("no command output")
+		return
+	}
+	text := s.screen.Output(command)
+	if text == "" {
+		s.notify("no command output")
+		return
+	}
+	s.copyToHost(text)
+	s.notify("copied the output of " + s.screen.CommandLine(command))
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"encoding/base64"
+	"io"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+)
+
+const (
+	// Host terminal modes: report every mouse event, SGR encoded.
+	hostMouseOn  = "\x1b[?1003h\x1b[?1006h"
+	hostMouseOff = "\x1b[?1006l\x1b[?1003l"
+
+	wheelLines = 3
+)
+
+// mouseState tracks a local selection in progress.
+type mouseState struct {
+	selecting bool // Left button held outside of program mouse mode
+	dragged   bool
+}
+
+// setHostMouse turns mouse reporting of the host terminal on or off.
+func (s *Session) setHostMouse(on bool) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	if s.host == nil || s.hostMouse == on {
+		return
+	}
+	s.hostMouse = on
+	if on {
+		io.WriteString(s.host, hostMouseOn)
+	} else {
+		io.WriteString(s.host, hostMouseOff)
+	}
+}
+
+func (s *Session) mouseEnabled() bool {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	return s.hostMouse
+}
+
+// parseMouse is vt.ParseSGRMouse, except that an escape sequence shorter
+// than the report prefix is passed on, so a lone Esc key is not delayed.
+func parseMouse(data []byte) (vt.MouseEvent, int) {
+	ev, n := vt.ParseSGRMouse(data)
+	if n < 0 && len(data) < len("\x1b[<") {
+		return ev, 0
+	}
+	return ev, n
+}
+
+// handleMouse forwards a host mouse event to the program when it asked for
+// mouse reports. Otherwise the wheel scrolls the scrollback, the left
+// button selects text, which is copied to the host clipboard, and Ctrl+click
+// copies the target of a link.
+func (s *Session) handleMouse(ev vt.MouseEvent) {
+	if report, ok := s.screen.EncodeMouse(ev); ok {
+		s.sendInput(report)
+		return
+	}
+
+	switch {
+	case ev.Button == vt.WheelUp && ev.Action == vt.MousePress:
+		s.screen.ScrollViewUp(wheelLines)
+		s.repaint()
+
+	case ev.Button == vt.WheelDown && ev.Action == vt.MousePress:
+		s.screen.ScrollViewDown(wheelLines)
+		s.repaint()
+
+	case ev.Button == vt.ButtonLeft && ev.Action == vt.MousePress && ev.Ctrl:
+		if link, ok := s.screen.LinkAt(ev.X, ev.Y); ok {
+			s.copyToHost(s.LinkTarget(link))
+		}
+
+	case ev.Button == vt.ButtonLeft && ev.Action == vt.MousePress:
+		_, _, hadSelection := s.screen.Selection()
+		s.screen.StartSelection(ev.X, ev.Y)
+		s.mouse = mouseState{selecting: true}
+		if hadSelection {
+			s.repaint()
+		}
+
+	case ev.Button == vt.ButtonLeft && ev.Action == vt.MouseMotion && s.mouse.selecting:
+		s.screen.ExtendSelection(ev.X, ev.Y)
+		s.mouse.dragged = true
+		s.repaint()
+
+	case ev.Action == vt.MouseRelease && s.mouse.selecting:
+		dragged := s.mouse.dragged
+		s.mouse = mouseState{}
+		if !dragged {
+			s.screen.ClearSelection()
+			return
+		}
+		s.screen.ExtendSelection(ev.X, ev.Y)
+		s.copyToHost(s.screen.SelectedText())
+		s.repaint()
+	}
+}
+
+// copyToHost puts text on the host clipboard with OSC 52.
+func (s *Session) copyToHost(text string) {
+	if text == "" {
+		return
+	}
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	if s.host != nil {
+		io.WriteString(s.host, "\x1b]52;c;"+base64.StdEncoding.EncodeToString([]byte(text))+"\x07")
+	}
+}
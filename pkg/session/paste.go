@@ -0,0 +1,196 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/FelipePn10/kariuki/pkg/paste"
+)
+
+const (
+	// Host terminal mode: wrap pastes in paste.Start and paste.End.
+	hostPasteOn  = "\x1b[?2004h"
+	hostPasteOff = "\x1b[?2004l"
+)
+
+// pasteState is a paste read from the host. It is only used by copyInput.
+type pasteState struct {
+	active  bool // Between the host's paste delimiters
+	buf     []byte
+	pending *string // Paste waiting for the user's answer
+}
+
+// readPaste collects the text of a paste and returns how many bytes of data
+// it used. A partial end delimiter at the end of data is left for the next
+// read.
+func (s *Session) readPaste(data []byte) int {
+	if end := bytes.Index(data, []byte(paste.End)); end >= 0 {
+		s.paste.buf = append(s.paste.buf, data[:end]...)
+		text := string(s.paste.buf)
+		s.paste = pasteState{}
+		s.endPaste(text)
+		return end + len(paste.End)
+	}
+	n := len(data) - partialSuffix(data, paste.End)
+	s.paste.buf = append(s.paste.buf, data[:n]...)
+	return n
+}
+
+// endPaste sends a paste to the program. At the shell prompt, pastes that
+// would run commands right away or contain blocked commands are shown
+// for confirmation first, and pastes the policy refuses are dropped. With
+// no host to show the confirmation on, such a paste is dropped too: the
+// next key typed must not be taken as the answer.
+func (s *Session) endPaste(text string) {
+	report, err := s.CheckPaste(text)
+	if err != nil {
+		return
+	}
+	if report.NeedsConfirmation() {
+		if s.showPasteConfirmation(report) {
+			s.paste.pending = &text
+		}
+		return
+	}
+	s.sendPaste(text)
+}
+
+// CheckPaste checks a paste for a host that reads pastes itself, as the mux
+// does: it returns why the policy refuses it, or what must be confirmed
+// before Paste sends it. Only pastes at the shell prompt need confirming.
+func (s *Session) CheckPaste(text string) (paste.Report, error) {
+	if err := s.checkPasteLines(text); err != nil {
+		return paste.Report{}, err
+	}
+	if !s.atPrompt() {
+		return paste.Report{}, nil
+	}
+	s.mu.Lock()
+	blocked := s.config.BlockedCommands
+	s.mu.Unlock()
+	return paste.Check(text, blocked), nil
+}
+
+// Paste sends text to the program as pasted, once CheckPaste let it.
+func (s *Session) Paste(text string) {
+	s.sendPaste(text)
+}
+
+// answerPaste handles the key pressed at the confirmation: 'y' sends the
+// paste, anything else drops it.
+func (s *Session) answerPaste(key byte) {
+	text := *s.paste.pending
+	s.paste.pending = nil
+
+	s.hostMu.Lock()
+	s.confirming = false
+	if s.host != nil {
+		io.WriteString(s.host, "\x1b[?1049l")
+	}
+	s.hostMu.Unlock()
+	// Program output was held back while the confirmation was shown.
+	s.repaint()
+
+	if key == 'y' || key == 'Y' {
+		s.sendPaste(text)
+	}
+}
+
+// sendPaste writes a paste to the program, bracketed if it enabled mode 2004.
+func (s *Session) sendPaste(text string) {
+	if s.screen.PrivateMode(2004) {
+		s.sendInput([]byte(paste.Bracket(text)))
+	} else {
+		s.sendInput([]byte(paste.Sanitize(text)))
+	}
+}
+
+// atPrompt reports whether the shell itself is reading input, rather than
+// a program started from it.
+func (s *Session) atPrompt() bool {
+	pgrp := s.foregroundGroup()
+	return (pgrp == 0 || pgrp == s.Pid()) && !s.screen.AltScreen()
+}
+
+// showPasteConfirmation draws the paste and its warnings on the host's
+// alternate screen, so the shell's screen comes back untouched. It reports
+// whether there was a host to draw it on.
+func (s *Session) showPasteConfirmation(report paste.Report) bool {
+	rows, cols := s.screen.Size()
+	flagged := map[int]bool{}
+	for _, w := range report.Warnings {
+		flagged[w.Line] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[?1049h\x1b[H\x1b[2J\x1b[0m")
+	fmt.Fprintf(&b, "\x1b[7m kariuki: confirm paste of %d line(s) \x1b[0m\r\n\r\n", len(report.Lines))
+
+	shown := max(rows-len(report.Warnings)-6, 1)
+	for i, line := range report.Lines {
+		if i == shown {
+			fmt.Fprintf(&b, "  ... %d more line(s)\r\n", len(report.Lines)-shown)
+			break
+		}
+		marker := "  "
+		if flagged[i] {
+			marker = "\x1b[1;31m!\x1b[0m "
+		}
+		b.WriteString(marker + truncate(printable(line), cols-2) + "\r\n")
+	}
+	if len(report.Warnings) > 0 {
+		b.WriteString("\r\n")
+	}
+	for _, w := range report.Warnings {
+		fmt.Fprintf(&b, "\x1b[1;31m!\x1b[0m line %d: %s\r\n", w.Line+1, w.Reason)
+	}
+	b.WriteString("\r\nPaste? [y/N] ")
+
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	if s.host == nil {
+		return false
+	}
+	s.confirming = true
+	io.WriteString(s.host, b.String())
+	return true
+}
+
+// printable shows control characters in caret notation (^[ for ESC).
+func printable(line string) string {
+	var b strings.Builder
+	for _, r := range line {
+		switch {
+		case r < 0x20:
+			b.WriteString("^" + string(rune(r+'@')))
+		case r == 0x7f:
+			b.WriteString("^?")
+		case r >= 0x80 && r < 0xa0:
+			b.WriteString("?")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width < 1 || len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// partialSuffix returns the length of the longest end of data that is the
+// start of seq.
+func partialSuffix(data []byte, seq string) int {
+	for n := min(len(seq)-1, len(data)); n > 0; n-- {
+		if bytes.HasSuffix(data, []byte(seq[:n])) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,155 @@
+package session
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/FelipePn10/kariuki/pkg/policy"
+)
+
+const (
+	// settleTime is how long the program must have been quiet for the
+	// screen to show the command line, and settleTimeout the longest wait.
+	settleTime    = 20 * time.Millisecond
+	settleTimeout = 500 * time.Millisecond
+)
+
+// SetPolicy checks command lines against p before the shell runs them:
+// when Enter is pressed at the prompt, and for pastes. A refused line is
+// abandoned with Ctrl-C and denied is told why. Lines are read from the
+// screen, after the prompt, so the shell's prompt must start with the
+// prompt setting, as UsePrompt arranges; other lines are checked whole
+// and refused.
+func (s *Session) SetPolicy(p policy.Policy, denied func(line string, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy, s.denied = p, denied
+}
+
+// commandPolicy returns the policy of SetPolicy.
+func (s *Session) commandPolicy() policy.Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policy
+}
+
+// isAcceptKey reports whether key makes shells run the command line:
+// Enter, Ctrl-J and Ctrl-O (operate-and-get-next of bash).
+func isAcceptKey(key byte) bool {
+	return key == '\r' || key == '\n' || key == 0x0f
+}
+
+// Type sends input as if typed, for input that does not come from a host
+// terminal: under the policy of SetPolicy, a key that would run a refused
+// command line abandons it instead. There are no key bindings, pastes or
+// mouse reports in such input.
+func (s *Session) Type(p []byte) error {
+	if !s.commandPolicy().Restricted() {
+		_, err := s.sendInput(p)
+		return err
+	}
+	for len(p) > 0 {
+		i := slices.IndexFunc(p, isAcceptKey)
+		if i < 0 {
+			_, err := s.sendInput(p)
+			return err
+		}
+		if i > 0 {
+			if _, err := s.sendInput(p[:i]); err != nil {
+				return err
+			}
+		}
+		s.acceptLine(p[i])
+		p = p[i+1:]
+	}
+	return nil
+}
+
+// acceptLine sends a key that runs the command line, if the policy allows
+// the line.
+func (s *Session) acceptLine(key byte) {
+	if s.atPrompt() {
+		s.settle()
+		if s.checkLine(s.commandLine()) != nil {
+			s.sendInput([]byte{0x03})
+			return
+		}
+	}
+	s.sendInput([]byte{key})
+}
+
+// checkLine checks line against the policy, telling the user and denied
+// when it is refused.
+func (s *Session) checkLine(line string) error {
+	s.mu.Lock()
+	p, denied := s.policy, s.denied
+	s.mu.Unlock()
+	err := p.Check(line)
+	if err == nil {
+		return nil
+	}
+	s.notify(err.Error())
+	if denied != nil {
+		denied(line, err)
+	}
+	return err
+}
+
+// checkPasteLines checks the lines a paste at the prompt would add to the
+// command line.
+func (s *Session) checkPasteLines(text string) error {
+	if !s.commandPolicy().Restricted() || !s.atPrompt() {
+		return nil
+	}
+	lines := strings.FieldsFunc(s.commandLine()+text, func(r rune) bool { return r == '\r' || r == '\n' })
+	for _, line := range lines {
+		if err := s.checkLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settle waits until the program has echoed the keys sent so far, so the
+// screen shows the command line as the shell has it.
+func (s *Session) settle() {
+	deadline := time.Now().Add(settleTimeout)
+	for time.Now().Before(deadline) {
+		output := s.lastOutput.Load()
+		if output >= s.lastInput.Load() && time.Since(time.Unix(0, output)) >= settleTime {
+			return
+		}
+		time.Sleep(settleTime / 4)
+	}
+}
+
+// commandLine returns the line at the cursor, joined with the rows it
+// wraps onto, without the prompt.
+func (s *Session) commandLine() string {
+	lines := s.screen.Lines()
+	y := s.screen.Cursor().Y
+	if y < 0 || y >= len(lines) {
+		return ""
+	}
+	first, last := y, y
+	for first > 0 && lines[first-1].Wrapped {
+		first--
+	}
+	for last < len(lines)-1 && lines[last].Wrapped {
+		last++
+	}
+	var b strings.Builder
+	for _, l := range lines[first : last+1] {
+		b.WriteString(l.Text())
+	}
+
+	s.mu.Lock()
+	prompt := strings.TrimRight(s.config.Prompt, " ")
+	s.mu.Unlock()
+	line := b.String()
+	if prompt != "" && strings.HasPrefix(line, prompt) {
+		line = line[len(prompt):]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,341 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/asciicast"
+	"github.com/FelipePn10/kariuki/pkg/bell"
+	"github.com/FelipePn10/kariuki/pkg/charset"
+	"github.com/FelipePn10/kariuki/pkg/clipboard"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/policy"
+	"github.com/FelipePn10/kariuki/pkg/terminfo"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/creack/pty"
+	"golang.org/x/text/encoding"
+)
+
+// MaxSize is the most rows or columns a session has. Resize makes larger
+// sizes fit, as they would take a lot of memory; CheckSize refuses them.
+const MaxSize = 1000
+
+// CheckSize returns why a client may not ask for a session of rows and
+// cols, or nil.
+func CheckSize(rows, cols int) error {
+	if rows < 1 || cols < 1 || rows > MaxSize || cols > MaxSize {
+		return fmt.Errorf("invalid size %dx%d", cols, rows)
+	}
+	return nil
+}
+
+// Session is a program running inside a pseudo-terminal.
+type Session struct {
+	config *terminal.TerminalConfig
+	argv   []string
+	cmd    *exec.Cmd
+	ptmx   *os.File // Master side of the PTY
+	screen *vt.Screen
+
+	// Conversion between the program's encoding and UTF-8.
+	encoding encoding.Encoding
+	output   io.Reader // Program output, UTF-8
+	inputMu  sync.Mutex
+	input    io.Writer // Program input, converted from UTF-8
+
+	profile color.Profile // Colors the host terminal can show
+	bell    *bell.Bell
+
+	mu         sync.Mutex
+	closed     bool
+	started    time.Time
+	limitTimer *time.Timer // Next checkLimits
+
+	lastActivity atomic.Int64 // Unix nanoseconds of the last input or output
+	lastInput    atomic.Int64 // Unix nanoseconds of the last input
+	lastOutput   atomic.Int64 // Unix nanoseconds of the last output
+
+	env      []string      // Added to the program's environment
+	prompted bool          // UsePrompt set the shell's prompt
+	policy   policy.Policy // Command lines the shell may run
+	denied   func(line string, err error)
+
+	// hostMu orders writes to the host terminal: program output, repaints
+	// of the screen model and mode changes.
+	hostMu     sync.Mutex
+	host       io.Writer
+	hostOutput io.Writer // host without the program's BEL characters
+	clipFilter *vt.OSCFilter
+	clipboard  clipboard.Policy // OSC 52 requests the program may make
+	hostMouse  bool
+	confirming bool // A paste confirmation is shown
+	mouse      mouseState
+	paste      pasteState
+	prefixed   bool // The prefix key was pressed; only used by copyInput
+	detachable bool // Attach is reading input; prefix and d ends it
+	detaching  bool
+	fgGroup    int         // Last seen foreground process group of the PTY
+	fgChecked  time.Time   // When checkForeground last looked
+	fgTimer    *time.Timer // Pending checkForeground
+	fgStopped  bool        // Close ended the checks
+	recorder   *asciicast.Writer
+	onOutput   func()
+	onBell     func() // Audible bell without a host
+}
+
+func NewSession(config *terminal.TerminalConfig, argv []string) *Session {
+	if len(argv) == 0 {
+		argv = []string{terminal.DefaultShell()}
+	}
+	screen := vt.NewScreen(min(config.Rows, MaxSize), min(config.Cols, MaxSize))
+	screen.SetScrollbackLimit(config.ScrollBuffer)
+	shape, _ := vt.ParseCursorShape(config.CursorStyle)
+	screen.SetDefaultCursorStyle(shape, config.CursorBlink)
+	screen.SetDefaultColors(config.Foreground(), config.Background())
+
+	// LoadConfig rejects unknown encodings; fall back to UTF-8 for others.
+	enc, _ := charset.Lookup(config.Encoding)
+
+	s := &Session{
+		config:    config,
+		argv:      argv,
+		screen:    screen,
+		encoding:  enc,
+		profile:   color.TrueColor,
+		clipboard: config.ClipboardPolicy(),
+	}
+	s.bell = bell.NewBell(config.BellSound, bell.Actions{
+		Audible: s.ring,
+		Flash:   s.flash,
+	})
+	// The host terminal never sees the queries that identify the terminal.
+	screen.SetIdentityWriter(s)
+	if config.ShellPrompt && len(argv) == 1 && argv[0] == terminal.DefaultShell() {
+		s.UsePrompt()
+	}
+	return s
+}
+
+// SetColorProfile sets what the host terminal can show. Program colors it
+// cannot show are converted to the closest ones it can.
+func (s *Session) SetColorProfile(profile color.Profile) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.profile = profile
+}
+
+// ApplyConfig updates the settings that can change while the session runs.
+func (s *Session) ApplyConfig(config *terminal.TerminalConfig) {
+	s.mu.Lock()
+	s.config = config
+	s.mu.Unlock()
+
+	s.screen.SetScrollbackLimit(config.ScrollBuffer)
+	s.setHostMouse(config.EnableMouse)
+	s.bell.SetMode(config.BellSound)
+	s.setClipboardPolicy(config.ClipboardPolicy())
+
+	s.screen.SetDefaultColors(config.Foreground(), config.Background())
+	s.writeTheme()
+
+	shape, _ := vt.ParseCursorShape(config.CursorStyle)
+	s.screen.SetDefaultCursorStyle(shape, config.CursorBlink)
+	if !s.screen.CursorStyleOverridden() {
+		s.writeCursorStyle()
+	}
+}
+
+// Start launches the program on a new PTY sized from Rows/Cols.
+func (s *Session) Start() error {
+	cmd := exec.Command(s.argv[0], s.argv[1:]...)
+	// Colors are converted for the host terminal, so programs may use any.
+	cmd.Env = append(os.Environ(), "TERM="+terminfo.Term(s.config.Term), "COLORTERM=truecolor")
+	cmd.Env = append(cmd.Env, s.env...)
+
+	rows, cols := s.screen.Size()
+	size := &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}
+	ptmx, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return fmt.Errorf("failed to start %q: %w", s.argv[0], err)
+	}
+
+	s.cmd = cmd
+	s.ptmx = ptmx
+	s.output = charset.NewDecoder(ptmx, s.encoding)
+	s.input = charset.NewEncoder(ptmx, s.encoding)
+
+	s.started = time.Now()
+	s.touch()
+	s.checkLimits()
+	return nil
+}
+
+// SetEnv adds variables, as "NAME=value", to the environment the program
+// is started with.
+func (s *Session) SetEnv(env ...string) {
+	s.env = append(s.env, env...)
+}
+
+// SetRecorder records the program output, and size changes, from now on.
+func (s *Session) SetRecorder(rec *asciicast.Writer) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.recorder = rec
+}
+
+// Screen returns the screen model kept up to date with the program output.
+func (s *Session) Screen() *vt.Screen {
+	return s.screen
+}
+
+// Run copies input to the program and its output to out until the program exits.
+// The returned error is the program's exit status as reported by exec.Cmd.Wait.
+func (s *Session) Run(in io.Reader, out io.Writer) error {
+	if s.ptmx == nil {
+		return errors.New("session not started")
+	}
+
+	s.attach(out, false)
+	defer s.detach()
+
+	go s.copyInput(in)
+	return s.wait(s.copyOutput())
+}
+
+// Serve is Run without a host terminal: the program output only updates the
+// screen model, which answers the program's queries, and input comes from
+// Write. OnOutput tells when the screen changes.
+func (s *Session) Serve() error {
+	if s.ptmx == nil {
+		return errors.New("session not started")
+	}
+	s.screen.SetReplyWriter(s)
+	return s.wait(s.copyOutput())
+}
+
+// OnOutput sets a function called after each piece of program output has
+// been applied to the screen model.
+func (s *Session) OnOutput(fn func()) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.onOutput = fn
+}
+
+// wait reaps the program once copyOutput has returned.
+func (s *Session) wait(copyErr error) error {
+	if copyErr != nil {
+		s.Close()
+		s.cmd.Wait()
+		return fmt.Errorf("failed to read program output: %w", copyErr)
+	}
+
+	err := s.cmd.Wait()
+	s.Close()
+	return err
+}
+
+// Resize sets the PTY window size (TIOCSWINSZ), reflows the screen model
+// and sends SIGWINCH to the program so it redraws. Sizes are cut to MaxSize.
+func (s *Session) Resize(rows, cols int) error {
+	if s.ptmx == nil {
+		return errors.New("session not started")
+	}
+	if rows < 1 || cols < 1 {
+		return fmt.Errorf("invalid size %dx%d", cols, rows)
+	}
+	rows, cols = min(rows, MaxSize), min(cols, MaxSize)
+
+	size := &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}
+	if err := pty.Setsize(s.ptmx, size); err != nil {
+		return fmt.Errorf("failed to resize PTY: %w", err)
+	}
+	s.screen.Resize(rows, cols)
+	if s.screen.ViewOffset() > 0 {
+		s.repaint()
+	}
+	s.hostMu.Lock()
+	if s.recorder != nil {
+		s.recorder.WriteResize(cols, rows)
+	}
+	s.hostMu.Unlock()
+
+	// The kernel signals the foreground process group of the PTY; the program
+	// itself is signalled too in case it runs in another group.
+	if s.cmd.Process != nil {
+		s.cmd.Process.Signal(syscall.SIGWINCH)
+	}
+	return nil
+}
+
+// Write sends input to the program.
+func (s *Session) Write(p []byte) (int, error) {
+	return s.sendInput(p)
+}
+
+// Pid returns the process id of the program, or 0 if it has not been started.
+func (s *Session) Pid() int {
+	if s.cmd == nil || s.cmd.Process == nil {
+		return 0
+	}
+	return s.cmd.Process.Pid
+}
+
+// Hangup sends SIGHUP to the program, as closing a terminal window does.
+// Run or Serve returns once it has exited.
+func (s *Session) Hangup() error {
+	if s.cmd == nil || s.cmd.Process == nil {
+		return errors.New("session not started")
+	}
+	return s.cmd.Process.Signal(syscall.SIGHUP)
+}
+
+// Kill ends the program with SIGKILL, for programs that ignore Hangup.
+func (s *Session) Kill() error {
+	if s.cmd == nil || s.cmd.Process == nil {
+		return errors.New("session not started")
+	}
+	return s.cmd.Process.Kill()
+}
+
+// Close releases the PTY. The program receives SIGHUP from the kernel.
+func (s *Session) Close() error {
+	s.stopForegroundChecks()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.ptmx == nil {
+		return nil
+	}
+	s.closed = true
+	if s.limitTimer != nil {
+		s.limitTimer.Stop()
+	}
+	return s.ptmx.Close()
+}
+
+// ExitCode extracts the exit status from the error returned by Run.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		// Killed by a signal: follow the shell convention of 128+signal.
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+	}
+	return 1
+}
@@ -0,0 +1,462 @@
+package session_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/asciicast"
+	"github.com/FelipePn10/kariuki/pkg/export"
+	"github.com/FelipePn10/kariuki/pkg/policy"
+	"github.com/FelipePn10/kariuki/pkg/session"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() *terminal.TerminalConfig {
+	return &terminal.TerminalConfig{Rows: 24, Cols: 80}
+}
+
+// runScript runs script with sh until it exits, with input as what the
+// host types, and returns the session and what the host got.
+func runScript(t *testing.T, config *terminal.TerminalConfig, script, input string) (*session.Session, string) {
+	t.Helper()
+	s := session.NewSession(config, []string{"sh", "-c", script})
+	require.NoError(t, s.Start())
+	var out bytes.Buffer
+	require.NoError(t, s.Run(strings.NewReader(input), &out))
+	return s, out.String()
+}
+
+// serve starts s with no host attached. The channel gets what Serve
+// returns.
+func serve(t *testing.T, s *session.Session) <-chan error {
+	t.Helper()
+	require.NoError(t, s.Start())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+	return done
+}
+
+// waitCommands waits until the screen of s has n commands, the last one
+// the prompt the shell shows next.
+func waitCommands(t *testing.T, s *session.Session, n int, msgAndArgs ...any) {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(s.Screen().Commands()) == n }, 2*time.Second, 10*time.Millisecond, msgAndArgs...)
+}
+
+func TestSession(t *testing.T) {
+	t.Run("Output and size", func(t *testing.T) {
+		s, out := runScript(t, testConfig(), "echo hello; stty size", "")
+		assert.Contains(t, out, "hello")
+		assert.Contains(t, out, "24 80")
+		assert.Equal(t, "hello", s.Screen().Line(0).Text())
+	})
+
+	t.Run("Exit code", func(t *testing.T) {
+		s := session.NewSession(testConfig(), []string{"sh", "-c", "exit 3"})
+		require.NoError(t, s.Start())
+
+		err := s.Run(strings.NewReader(""), &bytes.Buffer{})
+		assert.Equal(t, 3, session.ExitCode(err))
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+		s := session.NewSession(testConfig(), []string{"sh", "-c", "read x; stty size"})
+		require.NoError(t, s.Start())
+		require.NoError(t, s.Resize(30, 100))
+
+		rows, cols := s.Screen().Size()
+		assert.Equal(t, 30, rows)
+		assert.Equal(t, 100, cols)
+
+		// Huge sizes are cut to what the session can hold.
+		require.NoError(t, s.Resize(65535, 2000))
+		rows, cols = s.Screen().Size()
+		assert.Equal(t, session.MaxSize, rows)
+		assert.Equal(t, session.MaxSize, cols)
+		assert.Error(t, session.CheckSize(65535, 80))
+		assert.Error(t, session.CheckSize(0, 80))
+		assert.NoError(t, session.CheckSize(24, 80))
+		require.NoError(t, s.Resize(30, 100))
+
+		var out bytes.Buffer
+		require.NoError(t, s.Run(strings.NewReader("\n"), &out))
+		assert.Contains(t, out.String(), "30 100")
+	})
+
+	t.Run("Terminal identity", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.Term = "no-such-term"
+		script := `echo $TERM $COLORTERM; stty -icanon -echo min 1; printf '\033[>q'; head -c 20 | tr '\033' E; echo`
+		// The host terminal does not see XTVERSION; the session answers it.
+		_, out := runScript(t, cfg, script, "")
+		assert.Contains(t, out, "xterm-256color truecolor")
+		assert.NotContains(t, out, "\x1b[>q")
+		assert.Contains(t, out, `EP>|kariuki(`+vt.Version+`)E\`)
+	})
+
+	t.Run("Latin-1 program", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.Encoding = "ISO-8859-1"
+		// The program echoes its input and prints "café" in Latin-1.
+		_, out := runScript(t, cfg, `stty raw -echo; head -c 1 | od -An -tx1; printf 'caf\351\n'`, "é")
+		assert.Contains(t, out, "e9")
+		assert.Contains(t, out, "café")
+	})
+
+	t.Run("Paste", func(t *testing.T) {
+		_, out := runScript(t, testConfig(), `stty raw -echo; head -c 3 | od -An -tx1`, "\x1b[200~abc\x1b[201~")
+		assert.Contains(t, out, "61 62 63")
+		assert.NotContains(t, out, "Paste?")
+	})
+
+	t.Run("Paste confirmation", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.BlockedCommands = []string{"rm -rf /"}
+		tests := []struct {
+			name, input, want string
+		}{
+			{"Multi-line declined", "\x1b[200~a\nb\x1b[201~nz", "7a"},
+			{"Multi-line accepted", "\x1b[200~a\nb\x1b[201~y", "61 0a 62"},
+			{"Blocked command", "\x1b[200~rm -rf /\x1b[201~nz", "7a"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				n := strings.Count(tt.want, " ") + 1
+				_, out := runScript(t, cfg, fmt.Sprintf("stty raw -echo; head -c %d | od -An -tx1", n), tt.input)
+				assert.Contains(t, out, "Paste? [y/N]")
+				assert.Contains(t, out, tt.want)
+			})
+		}
+	})
+
+	t.Run("Clipboard", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.Clipboard = "write-only"
+		_, out := runScript(t, cfg, `printf '\033]52;c;aGk=\a\033]52;c;?\a'`, "")
+		assert.Contains(t, out, "\x1b]52;c;aGk=\a")
+		assert.NotContains(t, out, "52;c;?")
+	})
+
+	t.Run("Recording", func(t *testing.T) {
+		var cast bytes.Buffer
+		rec, err := asciicast.NewWriter(&cast, asciicast.Header{Width: 80, Height: 24})
+		require.NoError(t, err)
+
+		s := session.NewSession(testConfig(), []string{"sh", "-c", "read x; echo recorded"})
+		require.NoError(t, s.Start())
+		s.SetRecorder(rec)
+		require.NoError(t, s.Resize(30, 100))
+		require.NoError(t, s.Run(strings.NewReader("\n"), &bytes.Buffer{}))
+
+		r, err := asciicast.NewReader(&cast)
+		require.NoError(t, err)
+		var types, output string
+		for {
+			e, err := r.Next()
+			if err != nil {
+				break
+			}
+			types += e.Type
+			if e.Type == asciicast.Output {
+				output += e.Data
+			}
+		}
+		assert.Equal(t, "r", types[:1])
+		assert.Contains(t, output, "recorded")
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		s, _ := runScript(t, testConfig(), "printf 'plain \\033[31mred\\033[0m\\n'", "")
+
+		var text bytes.Buffer
+		require.NoError(t, s.Export(&text, export.Plain))
+		assert.Equal(t, "plain red\n", text.String())
+
+		var ansi bytes.Buffer
+		require.NoError(t, s.Export(&ansi, export.ANSI))
+		assert.Equal(t, "plain \x1b[0;31mred\x1b[0m\n", ansi.String())
+
+		s.Screen().StartSelection(6, 0)
+		s.Screen().ExtendSelection(8, 0)
+		text.Reset()
+		require.NoError(t, s.Export(&text, export.Plain))
+		assert.Equal(t, "red\n", text.String())
+	})
+
+	t.Run("Export file", func(t *testing.T) {
+		config := testConfig()
+		config.HistoryFile = filepath.Join(t.TempDir(), "history")
+		config.ExportFormat = "html"
+		config.BgColor = "#102030"
+		// The program changes the background; exports keep the theme's.
+		s, _ := runScript(t, config, `printf 'hi\033]11;#ffffff\a'`, "")
+
+		first, err := s.ExportFile()
+		require.NoError(t, err)
+		second, err := s.ExportFile()
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+		for _, path := range []string{first, second} {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), "#102030")
+			assert.NotContains(t, string(data), "#ffffff")
+		}
+	})
+
+	t.Run("Prefix key", func(t *testing.T) {
+		config := testConfig()
+		config.PrefixKey = "C-b"
+		// C-b x is an unbound key and is dropped; C-b C-b sends one C-b.
+		s, _ := runScript(t, config, "stty raw -echo; head -c 3 | od -An -c", "a\x02x\x02\x02b")
+		assert.Contains(t, s.Screen().String(), "a 002   b")
+	})
+
+	t.Run("Serve", func(t *testing.T) {
+		s := session.NewSession(testConfig(), []string{"sh", "-c", "stty raw -echo; printf '\\033[5n'; head -c 4 | od -An -c"})
+		require.NoError(t, s.Start())
+		var outputs atomic.Int32
+		s.OnOutput(func() { outputs.Add(1) })
+
+		// The screen model answers the status report.
+		require.NoError(t, s.Serve())
+		assert.Contains(t, s.Screen().String(), "033   [   0   n")
+		assert.Positive(t, outputs.Load())
+	})
+
+	t.Run("Visual bell without a host", func(t *testing.T) {
+		config := testConfig()
+		config.BellSound = "visual"
+		s := session.NewSession(config, []string{"sh", "-c", `printf '\a'; exec sleep 5`})
+		var outputs atomic.Int32
+		s.OnOutput(func() { outputs.Add(1) })
+		done := serve(t, s)
+
+		// The output and the flash going on and off each ask for a redraw.
+		assert.Eventually(t, func() bool { return outputs.Load() == 3 }, 2*time.Second, 10*time.Millisecond)
+		assert.False(t, s.Screen().VisualBell())
+		s.Hangup()
+		<-done
+	})
+
+	t.Run("Attach", func(t *testing.T) {
+		s := session.NewSession(testConfig(), []string{"sh", "-c", "printf '\\033[?1hbefore'; read x; echo after"})
+		require.NoError(t, s.Start())
+		done := make(chan error, 1)
+		go func() { done <- s.Serve() }()
+		require.Eventually(t, func() bool { return strings.Contains(s.Screen().String(), "before") }, 2*time.Second, 10*time.Millisecond)
+
+		var out bytes.Buffer
+		detached := s.Attach(strings.NewReader("\x1dd"), &out)
+		assert.True(t, detached)
+		assert.Contains(t, out.String(), "\x1b[?1h")
+		assert.Contains(t, out.String(), "before")
+
+		out.Reset()
+		assert.False(t, s.Attach(strings.NewReader("\n"), &out))
+		require.NoError(t, <-done)
+		assert.Contains(t, s.Screen().String(), "after")
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		for name, config := range map[string]*terminal.TerminalConfig{
+			"inactivity":   {Rows: 24, Cols: 80, InactivityClose: 100 * time.Millisecond},
+			"session time": {Rows: 24, Cols: 80, MaxSessionTime: 100 * time.Millisecond},
+		} {
+			s := session.NewSession(config, []string{"sh", "-c", "exec sleep 5"})
+			require.NoError(t, s.Start())
+			start := time.Now()
+			err := s.Serve()
+			assert.Equal(t, 128+int(syscall.SIGHUP), session.ExitCode(err), name)
+			assert.Less(t, time.Since(start), 2*time.Second, name)
+		}
+	})
+
+	t.Run("Link target", func(t *testing.T) {
+		dir := t.TempDir()
+		s := session.NewSession(testConfig(), []string{"sh", "-c", "cd " + dir + " && exec sleep 5"})
+		require.NoError(t, s.Start())
+		defer s.Close()
+
+		url := vt.Link{Kind: vt.LinkURL, URI: "https://go.dev"}
+		assert.Equal(t, "https://go.dev", s.LinkTarget(url))
+
+		file := vt.Link{Kind: vt.LinkFile, URI: "a_test.go", Line: 3}
+		assert.Eventually(t, func() bool {
+			return s.LinkTarget(file) == filepath.Join(dir, "a_test.go")
+		}, 2*time.Second, 10*time.Millisecond)
+
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		file = vt.Link{Kind: vt.LinkFile, URI: "~/notes/a.txt"}
+		assert.Equal(t, filepath.Join(home, "notes/a.txt"), s.LinkTarget(file))
+	})
+
+	t.Run("Shell integration", func(t *testing.T) {
+		for _, argv := range [][]string{{"bash", "--norc", "--noprofile"}, {"sh"}} {
+			config := testConfig()
+			config.Prompt = "> "
+			s := session.NewSession(config, argv)
+			s.UsePrompt()
+			done := serve(t, s)
+
+			// Each line is typed at a new prompt.
+			for i, line := range []string{"echo one; echo two\r", "(exit 3)\r", "echo three\r"} {
+				waitCommands(t, s, i+1, argv[0])
+				s.Write([]byte(line))
+			}
+			waitCommands(t, s, 4, argv[0])
+
+			// The prefix key and o copy the output of the last command.
+			var out bytes.Buffer
+			assert.True(t, s.Attach(strings.NewReader("\x1do\x1dd"), &out))
+			assert.Contains(t, out.String(), "\x1b]52;c;"+base64.StdEncoding.EncodeToString([]byte("three")), argv[0])
+			s.Write([]byte("exit 0\r"))
+			require.NoError(t, <-done)
+
+			screen := s.Screen()
+			commands := screen.Commands()
+			require.GreaterOrEqual(t, len(commands), 3, argv[0])
+			assert.Equal(t, "echo one; echo two", screen.CommandLine(commands[0]), argv[0])
+			assert.Equal(t, "one\ntwo", screen.Output(commands[0]), argv[0])
+			assert.Equal(t, 0, commands[0].Status, argv[0])
+			assert.Equal(t, "(exit 3)", screen.CommandLine(commands[1]), argv[0])
+			assert.Equal(t, 3, commands[1].Status, argv[0])
+			assert.Equal(t, "> (exit 3)", screen.Line(3).Text(), argv[0])
+		}
+	})
+
+	t.Run("Default shell prompt", func(t *testing.T) {
+		t.Setenv("SHELL", "/bin/sh")
+		config := testConfig()
+		config.Prompt = "$ "
+		config.ShellPrompt = true
+		// As for a local run, where the command is the default shell.
+		s := session.NewSession(config, []string{terminal.DefaultShell()})
+		done := serve(t, s)
+
+		waitCommands(t, s, 1)
+		s.Write([]byte("echo hi\r"))
+		waitCommands(t, s, 2)
+		s.Write([]byte("exit\r"))
+		require.NoError(t, <-done)
+		commands := s.Screen().Commands()
+		require.GreaterOrEqual(t, len(commands), 2)
+		assert.Equal(t, "echo hi", s.Screen().CommandLine(commands[0]))
+		assert.Equal(t, "hi", s.Screen().Output(commands[0]))
+	})
+
+	t.Run("Typing under a policy", func(t *testing.T) {
+		config := testConfig()
+		config.Prompt = "$ "
+		s := session.NewSession(config, []string{"sh"})
+		s.UsePrompt()
+		var denied atomic.Value
+		s.SetPolicy(policy.Policy{Blocked: []string{"touch"}}, func(line string, err error) { denied.Store(line) })
+		done := serve(t, s)
+
+		dir := t.TempDir()
+		waitCommands(t, s, 1)
+		require.NoError(t, s.Type([]byte("touch "+dir+"/x\r")))
+		waitCommands(t, s, 2)
+		require.NoError(t, s.Type([]byte("echo ok\r")))
+		waitCommands(t, s, 3)
+		require.NoError(t, s.Type([]byte("exit\r")))
+		require.NoError(t, <-done)
+
+		assert.Equal(t, "touch "+dir+"/x", denied.Load())
+		assert.NoFileExists(t, filepath.Join(dir, "x"))
+		assert.Contains(t, s.Screen().String(), "\nok\n")
+	})
+
+	t.Run("Mouse", func(t *testing.T) {
+		config := testConfig()
+		config.EnableMouse = true
+		config.ScrollBuffer = 100
+		script := `seq 30; printf hello; read x; stty raw -echo; printf '\033[?1000h\033[?1006h'; head -c 9 | od -An -c`
+		s := session.NewSession(config, []string{"sh", "-c", script})
+		require.NoError(t, s.Start())
+		in, keys := io.Pipe()
+		defer keys.Close()
+		var out bytes.Buffer
+		done := make(chan error, 1)
+		go func() { done <- s.Run(in, &out) }()
+		type_ := func(s string) {
+			_, err := io.WriteString(keys, s)
+			require.NoError(t, err)
+		}
+		waitFor := func(cond func() bool) {
+			t.Helper()
+			require.Eventually(t, cond, 2*time.Second, 10*time.Millisecond)
+		}
+		waitFor(func() bool { return strings.Contains(s.Screen().String(), "hello") })
+
+		// The wheel scrolls the scrollback.
+		type_("\x1b[<64;1;1M")
+		waitFor(func() bool { return s.Screen().ViewOffset() == 3 })
+		type_("\x1b[<65;1;1M")
+		waitFor(func() bool { return s.Screen().ViewOffset() == 0 })
+
+		// Dragging with the left button selects, and the selection is
+		// copied when the button is released.
+		type_("\x1b[<0;1;24M\x1b[<32;5;24M\x1b[<0;5;24m")
+		waitFor(func() bool { return s.Screen().SelectedText() == "hello" })
+		// A click without a drag clears it and copies nothing.
+		type_("\x1b[<0;3;24M\x1b[<0;3;24m")
+		waitFor(func() bool { _, _, ok := s.Screen().Selection(); return !ok })
+
+		// A program that asks for mouse reports gets them instead.
+		type_("\r")
+		waitFor(func() bool { return s.Screen().MouseEncoding() == vt.MouseEncodingSGR })
+		type_("\x1b[<0;3;2M")
+		require.NoError(t, <-done)
+		assert.Contains(t, s.Screen().String(), "033   [   <   0   ;   3   ;   2   M")
+		assert.Equal(t, 1, strings.Count(out.String(), "\x1b]52;c;"))
+		assert.Contains(t, out.String(), "\x1b]52;c;"+base64.StdEncoding.EncodeToString([]byte("hello"))+"\a")
+	})
+
+	t.Run("Cursor style after a program", func(t *testing.T) {
+		config := testConfig()
+		config.CursorStyle = "underline"
+		s := session.NewSession(config, []string{"sh", "-i"})
+		done := serve(t, s)
+		waitFor := func(cond func() bool) {
+			t.Helper()
+			require.Eventually(t, cond, 2*time.Second, 10*time.Millisecond)
+		}
+		shape := func() vt.CursorShape {
+			shape, _ := s.Screen().CursorStyle()
+			return shape
+		}
+		waitFor(func() bool { return strings.ContainsAny(s.Screen().String(), "$#") })
+		assert.Equal(t, vt.CursorUnderline, shape())
+
+		// A program in a job of its own sets a bar and exits without
+		// resetting it; the shell gets the configured cursor back.
+		s.Write([]byte("sh -c \"printf '\\033[6 q'; sleep 0.3; echo done\"\r"))
+		waitFor(func() bool { return shape() == vt.CursorBar })
+		waitFor(func() bool { return strings.Contains(s.Screen().String(), "\ndone") })
+		waitFor(func() bool { return shape() == vt.CursorUnderline })
+
+		s.Write([]byte("exit\r"))
+		require.NoError(t, <-done)
+		require.NoError(t, s.Close())
+	})
+
+	t.Run("Unknown program", func(t *testing.T) {
+		s := session.NewSession(testConfig(), []string{"/nonexistent/program"})
+		assert.Error(t, s.Start())
+	})
+}
@@ -0,0 +1,339 @@
+// Package sshserver serves kariuki sessions to SSH clients. Clients log in
+// with keys from an authorized_keys file, and every session runs under
+// the command policy, time limits and audit log of the configuration.
+package sshserver
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/session"
+	"golang.org/x/crypto/ssh"
+)
+
+// deniedStatus is the exit status of a command the policy refuses, as for
+// a command that can not be run.
+const deniedStatus = 126
+
+// Server accepts SSH connections and runs a session for each session
+// channel of a client.
+type Server struct {
+	mu         sync.Mutex
+	config     *terminal.TerminalConfig
+	command    []string // Program of shell sessions
+	authorized string   // authorized_keys file, read at every login
+	ssh        *ssh.ServerConfig
+	audit      *audit.Logger
+	sessions   atomic.Int64 // Sessions started, to name them in the audit log
+}
+
+// NewServer runs command, the user's shell when empty, for clients whose
+// key is in the authorized_keys file at authorized. Options of the keys
+// are ignored.
+func NewServer(config *terminal.TerminalConfig, command []string, hostKey ssh.Signer, authorized string) *Server {
+	srv := &Server{config: config, command: command, authorized: authorized}
+	srv.ssh = &ssh.ServerConfig{
+		PublicKeyCallback: srv.checkKey,
+		ServerVersion:     "SSH-2.0-kariuki",
+	}
+	srv.ssh.AddHostKey(hostKey)
+	return srv
+}
+
+// LoadHostKey reads the private host key at path, first creating an
+// Ed25519 key there, readable only by the user, if there is none.
+func LoadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if data, err = newHostKey(path); err != nil {
+			return nil, fmt.Errorf("failed to create the host key: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+func newHostKey(path string) ([]byte, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "kariuki host key")
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(block)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	return data, os.WriteFile(path, data, 0o600)
+}
+
+// SetAuditLog records logins, what clients type and the commands the
+// policy refuses to l.
+func (srv *Server) SetAuditLog(l *audit.Logger) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.audit = l
+}
+
+// ApplyConfig sets the configuration of sessions started from now on.
+func (srv *Server) ApplyConfig(config *terminal.TerminalConfig) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.config = config
+}
+
+// Serve handles clients from l until it is closed.
+func (srv *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go srv.handle(c)
+	}
+}
+
+// checkKey lets in clients whose key is authorized, remembering which key
+// it was for the audit log.
+func (srv *Server) checkKey(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	data, err := os.ReadFile(srv.authorized)
+	if err != nil {
+		return nil, fmt.Errorf("no authorized keys: %w", err)
+	}
+	for len(data) > 0 {
+		authorized, comment, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			break
+		}
+		if bytes.Equal(authorized.Marshal(), key.Marshal()) {
+			return &ssh.Permissions{Extensions: map[string]string{
+				"fingerprint": ssh.FingerprintSHA256(key),
+				"comment":     comment,
+			}}, nil
+		}
+		data = rest
+	}
+	return nil, fmt.Errorf("unknown key for %s", meta.User())
+}
+
+// handle serves the session channels of a client. Other channels, such as
+// port forwarding, and global requests are refused.
+func (srv *Server) handle(c net.Conn) {
+	conn, channels, requests, err := ssh.NewServerConn(c, srv.ssh)
+	if err != nil {
+		c.Close()
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(requests)
+
+	user := conn.User()
+	if comment := conn.Permissions.Extensions["comment"]; comment != "" && comment != user {
+		user += " (" + comment + ")"
+	}
+	via := fmt.Sprintf("ssh %s key %s", conn.RemoteAddr(), conn.Permissions.Extensions["fingerprint"])
+	for nc := range channels {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are allowed")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		c := &channel{srv: srv, ch: ch, user: user, via: via}
+		go c.serve(requests)
+	}
+}
+
+// channel is a session channel of a client.
+type channel struct {
+	srv       *Server
+	ch        ssh.Channel
+	user, via string
+
+	mu      sync.Mutex
+	term    string // TERM of the client, with a PTY
+	rows    int
+	cols    int
+	started bool             // A shell or exec request was answered
+	session *session.Session // The running session, if the policy allowed it
+}
+
+// serve answers the requests of the channel, starting a session for the
+// first shell or exec request, until the client closes the channel.
+func (c *channel) serve(requests <-chan *ssh.Request) {
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term             string
+				Cols, Rows, W, H uint32
+				Modes            string
+			}
+			// 0 leaves the size to the config; larger sizes than a session
+			// holds are refused.
+			ok := ssh.Unmarshal(req.Payload, &pty) == nil && pty.Rows <= session.MaxSize && pty.Cols <= session.MaxSize
+			if ok {
+				c.mu.Lock()
+				c.term, c.rows, c.cols = pty.Term, int(pty.Rows), int(pty.Cols)
+				c.mu.Unlock()
+			}
+			req.Reply(ok, nil)
+		case "window-change":
+			var size struct{ Cols, Rows, W, H uint32 }
+			if ssh.Unmarshal(req.Payload, &size) == nil && session.CheckSize(int(size.Rows), int(size.Cols)) == nil {
+				c.resize(int(size.Rows), int(size.Cols))
+			}
+		case "shell":
+			req.Reply(c.start(""), nil)
+		case "exec":
+			var exec struct{ Command string }
+			if ssh.Unmarshal(req.Payload, &exec) != nil || exec.Command == "" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(c.start(exec.Command), nil)
+		default:
+			// Environment variables, subsystems such as sftp, agent and X11
+			// forwarding are not offered.
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+
+	// The client is gone, as if its terminal was closed.
+	c.mu.Lock()
+	s := c.session
+	c.mu.Unlock()
+	if s != nil {
+		s.Hangup()
+	}
+}
+
+func (c *channel) resize(rows, cols int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rows, c.cols = rows, cols
+	if c.session != nil {
+		c.session.Resize(rows, cols)
+	}
+}
+
+// start runs the shell, or command if it is not empty, in a new session.
+func (c *channel) start(command string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return false
+	}
+	c.started = true
+
+	srv := c.srv
+	srv.mu.Lock()
+	config := *srv.config
+	log := srv.audit
+	srv.mu.Unlock()
+	name := fmt.Sprintf("ssh-%d", srv.sessions.Add(1))
+
+	argv := srv.command
+	if command != "" {
+		argv = []string{"/bin/sh", "-c", command}
+	}
+	log.Log(audit.Event{Session: name, User: c.user, Action: audit.Start, Via: c.via, Text: strings.Join(argv, " ")})
+
+	if command != "" {
+		if err := config.CommandPolicy().Check(command); err != nil {
+			log.Log(audit.Event{Session: name, User: c.user, Action: audit.Deny, Via: c.via, Text: command})
+			fmt.Fprintf(c.ch.Stderr(), "kariuki: %v\r\n", err)
+			go c.exit(name, deniedStatus)
+			return true
+		}
+	}
+
+	if c.rows > 0 && c.cols > 0 {
+		config.Rows, config.Cols = c.rows, c.cols
+	}
+	s := session.NewSession(&config, argv)
+	// The policy finds the command line after the prompt.
+	s.UsePrompt()
+	s.SetPolicy(config.CommandPolicy(), func(line string, err error) {
+		log.Log(audit.Event{Session: name, User: c.user, Action: audit.Deny, Via: c.via, Text: line})
+	})
+	term := c.term
+	s.SetColorProfile(color.DetectProfile(func(key string) string {
+		if key == "TERM" {
+			return term
+		}
+		return ""
+	}))
+	if err := s.Start(); err != nil {
+		fmt.Fprintf(c.ch.Stderr(), "kariuki: %v\r\n", err)
+		go c.exit(name, 1)
+		return true
+	}
+	c.session = s
+	go c.run(name, s, log)
+	return true
+}
+
+// run passes the client's keys to the session, logging them, until the
+// program exits.
+func (c *channel) run(name string, s *session.Session, log *audit.Logger) {
+	in, keys := io.Pipe()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := c.ch.Read(buf)
+			if n > 0 {
+				log.Input(name, c.user, 0, buf[:n])
+				if _, err := keys.Write(buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				// The client's input ended, as with ssh host cmd < file; the
+				// PTY gets an end of file.
+				keys.Write([]byte{0x04})
+				return
+			}
+		}
+	}()
+	code := session.ExitCode(s.Run(in, c.ch))
+	in.Close()
+	log.Flush(name, c.user, 0)
+	c.exit(name, code)
+}
+
+// exit reports the exit status of the session to the client and closes
+// the channel.
+func (c *channel) exit(name string, code int) {
+	c.srv.mu.Lock()
+	log := c.srv.audit
+	c.srv.mu.Unlock()
+	log.Log(audit.Event{Session: name, User: c.user, Action: audit.End, Via: c.via, Text: fmt.Sprint(code)})
+	c.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(code)}))
+	c.ch.Close()
+}
@@ -0,0 +1,178 @@
+package sshserver_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/sshserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// output collects what a session prints.
+type output struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.b.Write(p)
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.b.String()
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return signer
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	hostKey, err := sshserver.LoadHostKey(filepath.Join(dir, "keys", "host_key"))
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "keys", "host_key"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	again, err := sshserver.LoadHostKey(filepath.Join(dir, "keys", "host_key"))
+	require.NoError(t, err)
+	assert.Equal(t, hostKey.PublicKey().Marshal(), again.PublicKey().Marshal())
+
+	client := newSigner(t)
+	authorized := filepath.Join(dir, "authorized_keys")
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(client.PublicKey()))) + " ana@laptop\n"
+	require.NoError(t, os.WriteFile(authorized, []byte("# contractors\n"+line), 0o600))
+
+	cfg := &terminal.TerminalConfig{
+		Rows: 24, Cols: 80, Prompt: "$ ",
+		AllowedCommands: []string{"echo", "stty", "exit"},
+		BlockedCommands: []string{"rm -rf /"},
+	}
+	srv := sshserver.NewServer(cfg, []string{"sh"}, hostKey, authorized)
+	logPath := filepath.Join(dir, "audit.log")
+	log, err := audit.Open(logPath)
+	require.NoError(t, err)
+	defer log.Close()
+	srv.SetAuditLog(log)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go srv.Serve(l)
+
+	dial := func(signer ssh.Signer) (*ssh.Client, error) {
+		return ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+			User:            "ana",
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: ssh.FixedHostKey(hostKey.PublicKey()),
+			Timeout:         5 * time.Second,
+		})
+	}
+
+	t.Run("Unknown key", func(t *testing.T) {
+		_, err := dial(newSigner(t))
+		assert.Error(t, err)
+	})
+
+	conn, err := dial(client)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("Forwarding", func(t *testing.T) {
+		_, err := conn.Dial("tcp", l.Addr().String())
+		assert.Error(t, err)
+	})
+
+	t.Run("Exec", func(t *testing.T) {
+		s, err := conn.NewSession()
+		require.NoError(t, err)
+		defer s.Close()
+		out, err := s.Output("echo hello")
+		require.NoError(t, err)
+		assert.Contains(t, string(out), "hello")
+	})
+
+	t.Run("Refused exec", func(t *testing.T) {
+		s, err := conn.NewSession()
+		require.NoError(t, err)
+		defer s.Close()
+		var stderr output
+		s.Stderr = &stderr
+		err = s.Run("cat /etc/passwd")
+		var exit *ssh.ExitError
+		require.True(t, errors.As(err, &exit), "%v", err)
+		assert.Equal(t, 126, exit.ExitStatus())
+		assert.Contains(t, stderr.String(), `"cat" is not allowed`)
+	})
+
+	t.Run("Shell", func(t *testing.T) {
+		s, err := conn.NewSession()
+		require.NoError(t, err)
+		defer s.Close()
+		var out output
+		s.Stdout = &out
+		keys, err := s.StdinPipe()
+		require.NoError(t, err)
+		assert.Error(t, s.RequestPty("xterm-256color", 65535, 65535, ssh.TerminalModes{}), "too large")
+		require.NoError(t, s.RequestPty("xterm-256color", 30, 100, ssh.TerminalModes{}))
+		require.NoError(t, s.Shell())
+
+		type step struct{ keys, want string }
+		for _, st := range []step{
+			{"", "$ "},
+			{"stty size\r", "30 100"},
+			{"rm -rf /\r", `"rm -rf /" is blocked`},
+			{"echo `id`\r", `"` + "`" + `" is not allowed`},
+		} {
+			_, err := keys.Write([]byte(st.keys))
+			require.NoError(t, err)
+			assert.Eventually(t, func() bool { return strings.Contains(out.String(), st.want) }, 5*time.Second, 10*time.Millisecond, st.want)
+		}
+		require.NoError(t, s.WindowChange(40, 120))
+		require.NoError(t, s.WindowChange(65535, 65535), "ignored")
+		_, err = keys.Write([]byte("stty size; exit 3\r"))
+		require.NoError(t, err)
+
+		err = s.Wait()
+		var exit *ssh.ExitError
+		require.True(t, errors.As(err, &exit), "%v", err)
+		assert.Equal(t, 3, exit.ExitStatus())
+		assert.Contains(t, out.String(), "40 120")
+	})
+
+	conn.Close()
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(logPath)
+		return strings.Count(string(data), `"action":"end"`) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	log.Close()
+	text := string(data)
+	assert.Contains(t, text, `"user":"ana (ana@laptop)"`)
+	assert.Contains(t, text, `"via":"ssh 127.0.0.1:`)
+	assert.Contains(t, text, ssh.FingerprintSHA256(client.PublicKey()))
+	assert.Contains(t, text, `"action":"deny","via":`)
+	for _, want := range []string{`"text":"cat /etc/passwd"`, `"text":"rm -rf /"`, `"text":"echo `, `"text":"stty size\r"`, `"text":"3"`} {
+		assert.Contains(t, text, want)
+	}
+}
@@ -0,0 +1,105 @@
+// Package terminfo ships the terminfo entry of kariuki, which tells
+// programs what the terminal can do, and installs it for the user.
+package terminfo
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	Name     = "kariuki"        // The entry in Source
+	Fallback = "xterm-256color" // TERM when an entry is not installed
+)
+
+// Source is the entry, for tic.
+//
+//go:embed kariuki.terminfo
+var Source string
+
+// Dirs returns the directories searched for compiled entries, in the
+// order ncurses searches them.
+func Dirs() []string {
+	var dirs []string
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	system := []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo"}
+	if list := os.Getenv("TERMINFO_DIRS"); list != "" {
+		for _, dir := range strings.Split(list, ":") {
+			if dir == "" {
+				dirs = append(dirs, system...)
+			} else {
+				dirs = append(dirs, dir)
+			}
+		}
+		return dirs
+	}
+	return append(dirs, system...)
+}
+
+// Installed reports whether an entry for name is in one of Dirs, filed
+// under its first letter or, as on macOS, the letter's hex code.
+func Installed(name string) bool {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return false
+	}
+	for _, dir := range Dirs() {
+		for _, sub := range []string{name[:1], fmt.Sprintf("%02x", name[0])} {
+			if _, err := os.Stat(filepath.Join(dir, sub, name)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Term returns the TERM programs get for the term setting: name if its
+// entry is installed, otherwise Fallback.
+func Term(name string) string {
+	if !Installed(name) {
+		return Fallback
+	}
+	return name
+}
+
+// UserDir returns ~/.terminfo, where Install puts the entry by default.
+func UserDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".terminfo"), nil
+}
+
+// Install compiles Source with tic into dir.
+func Install(dir string) error {
+	tic, err := exec.LookPath("tic")
+	if err != nil {
+		return errors.New("tic is not installed")
+	}
+	f, err := os.CreateTemp("", Name+"-*.terminfo")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(Source)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command(tic, "-x", "-o", dir, f.Name()).CombinedOutput(); err != nil {
+		return fmt.Errorf("tic: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package terminfo_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/terminfo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerm(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TERMINFO", dir)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TERMINFO_DIRS", "")
+	assert.Equal(t, terminfo.Fallback, terminfo.Term("no-such-term"))
+	assert.Equal(t, terminfo.Fallback, terminfo.Term(""))
+	assert.Equal(t, terminfo.Fallback, terminfo.Term("../k"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "6b"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "6b", "kariuki"), nil, 0o644))
+	assert.True(t, terminfo.Installed("kariuki"))
+	assert.Equal(t, "kariuki", terminfo.Term("kariuki"))
+}
+
+func TestInstall(t *testing.T) {
+	if _, err := exec.LookPath("tic"); err != nil {
+		t.Skip("tic is not installed")
+	}
+	dir := t.TempDir()
+	require.NoError(t, terminfo.Install(dir))
+	t.Setenv("TERMINFO", dir)
+	assert.True(t, terminfo.Installed(terminfo.Name))
+
+	if _, err := exec.LookPath("infocmp"); err == nil {
+		out, err := exec.Command("infocmp", "-x", terminfo.Name).CombinedOutput()
+		require.NoError(t, err, string(out))
+		assert.Contains(t, string(out), "Tc")
+		assert.Contains(t, string(out), `Ss=\E[%p1%d q`)
+	}
+}
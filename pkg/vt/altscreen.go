@@ -0,0 +1,100 @@
+package vt
+
+// altScreen holds the buffer that is not shown: the primary screen while a
+// full-screen program uses the alternate one. Each buffer has its own
+// DECSC/DECRC slot.
+type altScreen struct {
+	active bool
+	lines  []Line      // The hidden buffer
+	saved  savedCursor // Its saved cursor
+}
+
+// AltScreen reports whether the alternate screen buffer is shown.
+func (s *Screen) AltScreen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.alt.active
+}
+
+// setAltScreen handles DECSET 47, 1047 and 1049.
+//
+//	47:   switch buffers
+//	1047: switch buffers, clearing the alternate one when leaving it
+//	1049: save the cursor and switch to a cleared alternate buffer;
+//	      when leaving, switch back and restore the cursor
+func (s *Screen) setAltScreen(mode int, on bool) {
+	if on == s.alt.active {
+		return
+	}
+	if on {
+		if mode == 1049 {
+			s.saveCursor()
+		}
+		s.swapBuffers()
+		if mode == 1049 {
+			s.clearLines()
+		}
+		return
+	}
+	if mode == 1047 {
+		s.clearLines()
+	}
+	s.swapBuffers()
+	if mode == 1049 {
+		s.restoreCursor()
+	}
+}
+
+// swapBuffers exchanges the shown and hidden buffers. The alternate buffer
+// starts blank the first time it is used.
+func (s *Screen) swapBuffers() {
+	hidden := s.alt.lines
+	if len(hidden) != s.rows {
+		hidden = blankLines(s.rows, s.cols)
+	}
+	s.alt.lines, s.lines = s.lines, hidden
+	s.alt.saved, s.saved = s.saved, s.alt.saved
+	s.alt.active = !s.alt.active
+	s.cursor.wrapNext = false
+	s.selection = selection{}
+	s.viewOffset = 0
+}
+
+func (s *Screen) clearLines() {
+	for i := range s.lines {
+		s.lines[i] = newLine(s.cols, s.blank())
+	}
+}
+
+// resizeAlt resizes while the alternate buffer is shown. The primary
+// buffer is reflowed around its saved cursor; the alternate one is cut or
+// padded, since the program redraws it after SIGWINCH anyway.
+func (s *Screen) resizeAlt(rows, cols int) {
+	altLines, altCursor := s.lines, s.cursor
+	s.lines, s.cursor = s.alt.lines, s.alt.saved.cursor
+	s.reflow(rows, cols)
+	s.alt.lines, s.alt.saved.cursor = s.lines, s.cursor
+
+	s.lines = cropLines(altLines, rows, cols)
+	s.cursor = altCursor
+	s.cursor.X = clamp(s.cursor.X, 0, cols-1)
+	s.cursor.Y = clamp(s.cursor.Y, 0, rows-1)
+	s.cursor.wrapNext = false
+}
+
+// cropLines fits lines to a new size, keeping the top-left corner.
+func cropLines(lines []Line, rows, cols int) []Line {
+	out := blankLines(rows, cols)
+	for y := 0; y < rows && y < len(lines); y++ {
+		copy(out[y].Cells, lines[y].Cells)
+	}
+	return out
+}
+
+func blankLines(rows, cols int) []Line {
+	lines := make([]Line, rows)
+	for i := range lines {
+		lines[i] = newLine(cols, Style{})
+	}
+	return lines
+}
@@ -0,0 +1,57 @@
+package vt_test
+
+import (
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAltScreen(t *testing.T) {
+	t.Run("1049 keeps primary screen and scrollback", func(t *testing.T) {
+		s := newScreen(2, 5, "1\r\n2\r\n3")
+		assert.Equal(t, 1, s.ScrollbackLen())
+
+		s.Write([]byte("\x1b[?1049h"))
+		assert.True(t, s.AltScreen())
+		assert.Equal(t, "\n", s.String())
+		s.Write([]byte("a\r\nb\r\nc\r\nd"))
+		assert.Equal(t, "c\nd", s.String())
+		assert.Equal(t, 1, s.ScrollbackLen(), "alternate screen has no history")
+
+		s.Write([]byte("\x1b[?1049l"))
+		assert.False(t, s.AltScreen())
+		assert.Equal(t, "2\n3", s.String())
+		assert.Equal(t, vt.Cursor{X: 1, Y: 1, Visible: true}, s.Cursor())
+		assert.Equal(t, []string{"1"}, texts(s.ScrollbackLines(0, 1)))
+	})
+
+	t.Run("47 keeps the alternate contents", func(t *testing.T) {
+		s := newScreen(2, 5, "p\x1b[?47hx\x1b[?47l")
+		assert.Equal(t, "p\n", s.String())
+		s.Write([]byte("\x1b[?47h"))
+		assert.Equal(t, " x\n", s.String())
+	})
+
+	t.Run("1047 clears when leaving", func(t *testing.T) {
+		s := newScreen(2, 5, "\x1b[?1047hx\x1b[?1047l\x1b[?1047h")
+		assert.Equal(t, "\n", s.String())
+		assert.True(t, s.PrivateMode(1047))
+	})
+
+	t.Run("Saved cursor per buffer", func(t *testing.T) {
+		s := newScreen(3, 5, "\x1b[2;3H\x1b7\x1b[?47h\x1b[3;1H\x1b7\x1b[H\x1b8")
+		assert.Equal(t, 2, s.Cursor().Y)
+		s.Write([]byte("\x1b[?47l\x1b8"))
+		assert.Equal(t, 1, s.Cursor().Y)
+		assert.Equal(t, 2, s.Cursor().X)
+	})
+
+	t.Run("Resize reflows the primary screen", func(t *testing.T) {
+		s := newScreen(2, 4, "abcdef\x1b[?1049h")
+		s.Resize(2, 8)
+		s.Write([]byte("\x1b[?1049l"))
+		assert.Equal(t, "abcdef\n", s.String())
+		assert.Equal(t, 6, s.Cursor().X)
+	})
+}
@@ -0,0 +1,101 @@
+package vt
+
+import "io"
+
+// TakeBell reports whether the program rang the bell since the last call.
+func (s *Screen) TakeBell() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rang := s.bellPending
+	s.bellPending = false
+	return rang
+}
+
+// SetVisualBell turns the visual bell flash on or off. Front-ends that draw
+// the screen model show it in inverse video while it is on.
+func (s *Screen) SetVisualBell(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.visualBell = on
+}
+
+// VisualBell reports whether the visual bell flash is on.
+func (s *Screen) VisualBell() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.visualBell
+}
+
+// BellFilter removes BEL characters from a program output stream so the
+// bell can be handled by kariuki, while keeping the BEL that terminates
+// OSC strings such as window titles.
+type BellFilter struct {
+	w     io.Writer
+	state bellFilterState
+}
+
+type bellFilterState int
+
+const (
+	bellGround bellFilterState = iota
+	bellEscape
+	bellString       // Inside OSC/DCS/SOS/PM/APC
+	bellStringEscape // ESC seen inside a string
+)
+
+func NewBellFilter(w io.Writer) *BellFilter {
+	return &BellFilter{w: w}
+}
+
+func (f *BellFilter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		if f.step(c) {
+			out = append(out, c)
+		}
+	}
+	if _, err := f.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// step advances the filter by one byte and reports whether it is passed on.
+func (f *BellFilter) step(c byte) bool {
+	switch f.state {
+	case bellGround:
+		if c == 0x07 {
+			return false
+		}
+		if c == 0x1b {
+			f.state = bellEscape
+		}
+	case bellEscape:
+		switch c {
+		case ']', 'P', 'X', '^', '_':
+			f.state = bellString
+		case 0x1b:
+		default:
+			f.state = bellGround
+			if c == 0x07 {
+				return false
+			}
+		}
+	case bellString:
+		switch c {
+		case 0x07, 0x18, 0x1a:
+			f.state = bellGround
+		case 0x1b:
+			f.state = bellStringEscape
+		}
+	case bellStringEscape:
+		if c == '\\' {
+			f.state = bellGround
+			break
+		}
+		// The ESC ended the string and starts a sequence of its own.
+		f.state = bellEscape
+		return f.step(c)
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package vt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBell(t *testing.T) {
+	t.Run("Take bell", func(t *testing.T) {
+		s := vt.NewScreen(5, 10)
+		assert.False(t, s.TakeBell())
+		s.Write([]byte("a\a\ab"))
+		assert.True(t, s.TakeBell())
+		assert.False(t, s.TakeBell())
+
+		// The BEL ending an OSC string is not a bell.
+		s.Write([]byte("\x1b]0;title\a"))
+		assert.False(t, s.TakeBell())
+		assert.Equal(t, "title", s.Title())
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewBellFilter(&out)
+		f.Write([]byte("a\ab\x1b]2;x\a\x1b\a"))
+		f.Write([]byte("c\x1b]0;y\x1b"))
+		f.Write([]byte("\\\a"))
+		assert.Equal(t, "ab\x1b]2;x\a\x1bc\x1b]0;y\x1b\\", out.String())
+
+		// An ESC inside a string starts a new sequence, whose BEL ends
+		// a string or rings as anywhere else.
+		out.Reset()
+		f.Write([]byte("\x1b]0;x\x1b]0;y\a\a\x1b]0;x\x1b\a"))
+		assert.Equal(t, "\x1b]0;x\x1b]0;y\a\x1b]0;x\x1b", out.String())
+	})
+
+	t.Run("Visual bell", func(t *testing.T) {
+		s := vt.NewScreen(1, 2)
+		s.Write([]byte("a"))
+		s.SetVisualBell(true)
+		assert.True(t, s.VisualBell())
+		var out bytes.Buffer
+		s.Render(&out)
+		assert.Contains(t, out.String(), "\x1b[0;7ma")
+	})
+}
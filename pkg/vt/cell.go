@@ -0,0 +1,117 @@
+package vt
+
+import (
+	"strings"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+)
+
+// Attr is a set of text rendition flags (SGR).
+type Attr uint16
+
+const (
+	AttrBold Attr = 1 << iota
+	AttrFaint
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrInvisible
+	AttrStrikethrough
+)
+
+// Style is the rendition of a cell: colors plus attribute flags.
+type Style struct {
+	Fg    color.Color
+	Bg    color.Color
+	Attrs Attr
+}
+
+// Has reports whether all flags in a are set.
+func (s Style) Has(a Attr) bool {
+	return s.Attrs&a == a
+}
+
+// Cell is one character position of the screen grid.
+// A zero Rune means the cell was never written or has been erased.
+type Cell struct {
+	Rune      rune
+	Combining string // Marks, joiners and variation selectors following Rune
+	Style
+	Link int // OSC 8 link, see Screen.Hyperlink; 0 for none
+
+	// A wide character takes two cells: the first is Wide and holds the
+	// character, the second is a Continuation with no character of its own.
+	Wide         bool
+	Continuation bool
+}
+
+// Char returns the character shown in the cell, a space for empty cells
+// and nothing for the second half of a wide character.
+func (c Cell) Char() string {
+	switch {
+	case c.Continuation:
+		return ""
+	case c.Rune == 0:
+		return " "
+	}
+	return string(c.Rune) + c.Combining
+}
+
+// Line is a row of cells.
+type Line struct {
+	Cells []Cell
+	// Wrapped is set when the text continues on the next line because it
+	// reached the right margin (a soft wrap, not a newline).
+	Wrapped bool
+	// Marks are the shell integration marks on the line, in the order
+	// they were received.
+	Marks []Mark
+}
+
+func newLine(cols int, style Style) Line {
+	l := Line{Cells: make([]Cell, cols)}
+	l.clear(0, cols, style)
+	return l
+}
+
+// clear blanks cells [from, to) keeping only the background color,
+// as xterm does for erase operations (background color erase).
+// A wide character cut in half by the range is blanked entirely.
+func (l *Line) clear(from, to int, style Style) {
+	blank := Cell{Style: Style{Bg: style.Bg}}
+	for i := from; i < to && i < len(l.Cells); i++ {
+		l.Cells[i] = blank
+	}
+	if from > 0 && from < len(l.Cells) && l.Cells[from-1].Wide {
+		l.Cells[from-1] = Cell{Style: Style{Bg: l.Cells[from-1].Bg}}
+	}
+	if to > 0 && to < len(l.Cells) && l.Cells[to].Continuation {
+		l.Cells[to] = Cell{Style: Style{Bg: l.Cells[to].Bg}}
+	}
+}
+
+// fixWide blanks halves of wide characters whose other half was moved
+// away, as happens when characters are inserted or deleted.
+func (l *Line) fixWide() {
+	for i, c := range l.Cells {
+		orphanLead := c.Wide && (i == len(l.Cells)-1 || !l.Cells[i+1].Continuation)
+		orphanTail := c.Continuation && (i == 0 || !l.Cells[i-1].Wide)
+		if orphanLead || orphanTail {
+			l.Cells[i] = Cell{Style: Style{Bg: c.Bg}}
+		}
+	}
+}
+
+func (l Line) clone() Line {
+	return Line{Cells: append([]Cell(nil), l.Cells...), Wrapped: l.Wrapped, Marks: append([]Mark(nil), l.Marks...)}
+}
+
+// Text returns the characters of the line without trailing blanks.
+func (l Line) Text() string {
+	var b strings.Builder
+	for _, c := range l.Cells {
+		b.WriteString(c.Char())
+	}
+	return strings.TrimRight(b.String(), " ")
+}
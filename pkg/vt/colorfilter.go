@@ -0,0 +1,159 @@
+package vt
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+)
+
+// ColorFilter rewrites the colors of SGR sequences in a byte stream to what
+// a color profile can show, e.g. truecolor output for a 256-color host.
+// Everything else is passed through unchanged.
+type ColorFilter struct {
+	w       io.Writer
+	profile color.Profile
+	scanner csiScanner
+}
+
+// NewColorFilter returns w itself when the profile shows every color.
+func NewColorFilter(w io.Writer, profile color.Profile) io.Writer {
+	if profile == color.TrueColor {
+		return w
+	}
+	return &ColorFilter{w: w, profile: profile}
+}
+
+func (f *ColorFilter) Write(p []byte) (int, error) {
+	out := f.scanner.scan(p, func(params []byte, final byte) (string, bool) {
+		if final != 'm' {
+			return "", false
+		}
+		return f.rewrite(params), true
+	})
+	if _, err := f.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// rewrite returns the SGR sequence for params with colors converted.
+func (f *ColorFilter) rewrite(params []byte) string {
+	// Private markers and intermediates are not plain SGR: leave them alone.
+	for _, c := range params {
+		if (c < '0' || c > '9') && c != ';' && c != ':' {
+			return "\x1b[" + string(params) + "m"
+		}
+	}
+
+	fields := strings.Split(string(params), ";")
+	var out []string
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		code, sub, hasSub := strings.Cut(field, ":")
+		n, _ := strconv.Atoi(code)
+
+		switch {
+		case n == 38 || n == 48 || n == 58:
+			var c *color.Color
+			var used int
+			if hasSub {
+				c = subColor(sub)
+			} else {
+				c, used = semicolonColor(fields[i+1:])
+			}
+			i += used
+			if c == nil || n == 58 && f.profile != color.TrueColor {
+				continue
+			}
+			out = append(out, f.encode(*c, n))
+		case n >= 30 && n <= 37 || n >= 90 && n <= 97 || n >= 40 && n <= 47 || n >= 100 && n <= 107:
+			if f.profile == color.NoColor {
+				continue
+			}
+			out = append(out, field)
+		default:
+			out = append(out, field)
+		}
+	}
+	if len(out) == 0 {
+		if len(fields) == 1 && fields[0] == "" {
+			return "\x1b[m"
+		}
+		return ""
+	}
+	return "\x1b[" + strings.Join(out, ";") + "m"
+}
+
+// encode returns the SGR parameters for a converted foreground (38) or
+// background (48) color.
+func (f *ColorFilter) encode(c color.Color, extended int) string {
+	c = f.profile.Convert(c)
+	base, bright := 30, 90
+	if extended == 48 {
+		base, bright = 40, 100
+	}
+	switch {
+	case c.IsDefault():
+		return strconv.Itoa(base + 9)
+	case c.IsIndexed() && c.Index() < 8:
+		return strconv.Itoa(base + int(c.Index()))
+	case c.IsIndexed() && c.Index() < 16:
+		return strconv.Itoa(bright + int(c.Index()) - 8)
+	case c.IsIndexed():
+		return strconv.Itoa(extended) + ";5;" + strconv.Itoa(int(c.Index()))
+	}
+	r, g, b := c.Components()
+	return strconv.Itoa(extended) + ";2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+}
+
+// semicolonColor decodes "5;n" or "2;r;g;b" following a 38/48 parameter.
+func semicolonColor(rest []string) (*color.Color, int) {
+	if len(rest) == 0 {
+		return nil, 0
+	}
+	num := func(i int) int {
+		v, _ := strconv.Atoi(rest[i])
+		return v
+	}
+	switch num(0) {
+	case 5:
+		if len(rest) >= 2 {
+			c := color.Indexed(byteParam(num(1)))
+			return &c, 2
+		}
+	case 2:
+		if len(rest) >= 4 {
+			c := color.RGB(byteParam(num(1)), byteParam(num(2)), byteParam(num(3)))
+			return &c, 4
+		}
+	}
+	return nil, len(rest)
+}
+
+// subColor decodes the colon form "5:n" or "2:[colorspace]:r:g:b".
+func subColor(sub string) *color.Color {
+	parts := strings.Split(sub, ":")
+	num := func(s string) int {
+		v, _ := strconv.Atoi(s)
+		return v
+	}
+	switch num(parts[0]) {
+	case 5:
+		if len(parts) >= 2 {
+			c := color.Indexed(byteParam(num(parts[1])))
+			return &c
+		}
+	case 2:
+		rgb := parts[1:]
+		if len(rgb) >= 4 {
+			rgb = rgb[1:]
+		}
+		if len(rgb) >= 3 {
+			c := color.RGB(byteParam(num(rgb[0])), byteParam(num(rgb[1])), byteParam(num(rgb[2])))
+			return &c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package vt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorFilter(t *testing.T) {
+	t.Run("Truecolor to 256", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewColorFilter(&out, color.ANSI256)
+		f.Write([]byte("a\x1b[1;38;2;255;135;0mb\x1b[48:2::0:0:0mc\x1b[0m"))
+		assert.Equal(t, "a\x1b[1;38;5;208mb\x1b[48;5;16mc\x1b[0m", out.String())
+	})
+
+	t.Run("256 to 16", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewColorFilter(&out, color.ANSI16)
+		f.Write([]byte("\x1b[38;5;196;44mx"))
+		assert.Equal(t, "\x1b[91;44mx", out.String())
+	})
+
+	t.Run("Sequence split across writes", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewColorFilter(&out, color.ANSI256)
+		f.Write([]byte("x\x1b[38;2;2"))
+		f.Write([]byte("55;0;0my"))
+		assert.Equal(t, "x\x1b[38;5;196my", out.String())
+	})
+
+	t.Run("No color", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewColorFilter(&out, color.NoColor)
+		f.Write([]byte("\x1b[31mx\x1b[1;32my\x1b[m\x1b[?25l"))
+		assert.Equal(t, "x\x1b[1my\x1b[m\x1b[?25l", out.String())
+	})
+
+	t.Run("Truecolor host is not filtered", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Equal(t, &out, vt.NewColorFilter(&out, color.TrueColor))
+	})
+}
+
+func TestDynamicColors(t *testing.T) {
+	var reply bytes.Buffer
+	s := vt.NewScreen(2, 10)
+	s.SetDefaultColors(color.MustParse("white"), color.MustParse("navy"))
+	s.SetReplyWriter(&reply)
+
+	s.Write([]byte("\x1b]11;?\x07"))
+	assert.Equal(t, "\x1b]11;rgb:0000/0000/8080\x1b\\", reply.String())
+
+	s.Write([]byte("\x1b]11;#102030\x07"))
+	_, bg := s.DefaultColors()
+	assert.Equal(t, color.RGB(0x10, 0x20, 0x30), bg)
+
+	s.Write([]byte("\x1b]111\x07"))
+	_, bg = s.DefaultColors()
+	assert.Equal(t, color.RGB(0, 0, 0x80), bg)
+}
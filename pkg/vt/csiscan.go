@@ -0,0 +1,62 @@
+package vt
+
+import "bytes"
+
+// maxPendingCSI bounds how much of an unfinished sequence is held back.
+const maxPendingCSI = 256
+
+// csiScanner finds the CSI sequences of a byte stream that arrives in
+// pieces, for filters that rewrite some of them on the way to a terminal.
+type csiScanner struct {
+	pending []byte // Incomplete escape sequence from the previous write
+}
+
+// scan returns p, after what was held back from the last call, with each
+// complete CSI sequence for which replace returns true replaced by what it
+// returns. replace gets the parameter and intermediate bytes and the final
+// byte. A sequence cut at the end of p is held back for the next call.
+func (sc *csiScanner) scan(p []byte, replace func(params []byte, final byte) (string, bool)) []byte {
+	data := p
+	if len(sc.pending) > 0 {
+		data = append(sc.pending, p...)
+		sc.pending = nil
+	}
+
+	var out bytes.Buffer
+	start := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x1b {
+			continue
+		}
+		if i+1 >= len(data) {
+			out.Write(data[start:i])
+			sc.pending = append([]byte(nil), data[i:]...)
+			start = len(data)
+			break
+		}
+		if data[i+1] != '[' {
+			continue
+		}
+		end := i + 2
+		for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+			end++
+		}
+		if end == len(data) && end-i < maxPendingCSI {
+			// Incomplete CSI: keep it for the next write.
+			out.Write(data[start:i])
+			sc.pending = append([]byte(nil), data[i:]...)
+			start = len(data)
+			break
+		}
+		if end < len(data) {
+			if seq, ok := replace(data[i+2:end], data[end]); ok {
+				out.Write(data[start:i])
+				out.WriteString(seq)
+				start = end + 1
+			}
+		}
+		i = end
+	}
+	out.Write(data[start:])
+	return out.Bytes()
+}
@@ -0,0 +1,103 @@
+package vt
+
+import "fmt"
+
+// CursorShape is how the cursor is drawn.
+type CursorShape int
+
+const (
+	CursorBlock CursorShape = iota
+	CursorUnderline
+	CursorBar
+)
+
+var cursorShapeNames = map[string]CursorShape{
+	"block":     CursorBlock,
+	"underline": CursorUnderline,
+	"bar":       CursorBar,
+}
+
+// ParseCursorShape converts a cursor_style setting ("block", "underline", "bar").
+func ParseCursorShape(name string) (CursorShape, bool) {
+	shape, ok := cursorShapeNames[name]
+	return shape, ok
+}
+
+func (c CursorShape) String() string {
+	switch c {
+	case CursorUnderline:
+		return "underline"
+	case CursorBar:
+		return "bar"
+	default:
+		return "block"
+	}
+}
+
+// CursorStyleSequence returns the DECSCUSR sequence that selects a style.
+func CursorStyleSequence(shape CursorShape, blink bool) string {
+	return fmt.Sprintf("\x1b[%d q", decscusrParam(shape, blink))
+}
+
+// decscusrParam returns Ps of DECSCUSR: odd values blink, even ones are steady.
+func decscusrParam(shape CursorShape, blink bool) int {
+	ps := 2*int(shape) + 2
+	if blink {
+		ps--
+	}
+	return ps
+}
+
+// SetDefaultCursorStyle sets the configured cursor style, used until a
+// program selects another one and restored by DECSCUSR 0, RIS and
+// ResetCursorStyle.
+func (s *Screen) SetDefaultCursorStyle(shape CursorShape, blink bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultShape, s.defaultBlink = shape, blink
+	if !s.cursorOverride {
+		s.resetCursorStyle()
+	}
+}
+
+// CursorStyle returns the current cursor shape and whether it blinks.
+func (s *Screen) CursorStyle() (CursorShape, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursorShape, s.modes.CursorBlink
+}
+
+// CursorStyleOverridden reports whether a program changed the cursor style.
+func (s *Screen) CursorStyleOverridden() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursorOverride
+}
+
+// ResetCursorStyle goes back to the configured cursor style, for example
+// when the program that changed it has exited.
+func (s *Screen) ResetCursorStyle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resetCursorStyle()
+}
+
+func (s *Screen) resetCursorStyle() {
+	s.cursorShape = s.defaultShape
+	s.modes.CursorBlink = s.defaultBlink
+	s.cursorOverride = false
+}
+
+// setCursorStyle implements DECSCUSR (CSI Ps SP q).
+func (s *Screen) setCursorStyle(ps int) {
+	if ps == 0 {
+		s.resetCursorStyle()
+		return
+	}
+	if ps > 6 {
+		return
+	}
+	s.cursorShape = CursorShape((ps - 1) / 2)
+	s.modes.CursorBlink = ps%2 == 1
+	s.cursorOverride = true
+}
@@ -0,0 +1,51 @@
+package vt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorStyle(t *testing.T) {
+	s := vt.NewScreen(2, 10)
+	s.SetDefaultCursorStyle(vt.CursorUnderline, true)
+
+	shape, blink := s.CursorStyle()
+	assert.Equal(t, vt.CursorUnderline, shape)
+	assert.True(t, blink)
+
+	// vi switching to a steady bar in insert mode.
+	s.Write([]byte("\x1b[6 q"))
+	shape, blink = s.CursorStyle()
+	assert.Equal(t, vt.CursorBar, shape)
+	assert.False(t, blink)
+	assert.True(t, s.CursorStyleOverridden())
+
+	var reply bytes.Buffer
+	s.SetReplyWriter(&reply)
+	s.Write([]byte("\x1bP$q q\x1b\\"))
+	assert.Equal(t, "\x1bP1$r6 q\x1b\\", reply.String())
+
+	// A config reload does not undo the program's choice...
+	s.SetDefaultCursorStyle(vt.CursorBlock, false)
+	shape, _ = s.CursorStyle()
+	assert.Equal(t, vt.CursorBar, shape)
+
+	// ...but DECSCUSR 0 restores the configured style.
+	s.Write([]byte("\x1b[0 q"))
+	shape, blink = s.CursorStyle()
+	assert.Equal(t, vt.CursorBlock, shape)
+	assert.False(t, blink)
+	assert.False(t, s.CursorStyleOverridden())
+}
+
+func TestCursorStyleSequence(t *testing.T) {
+	assert.Equal(t, "\x1b[1 q", vt.CursorStyleSequence(vt.CursorBlock, true))
+	assert.Equal(t, "\x1b[4 q", vt.CursorStyleSequence(vt.CursorUnderline, false))
+
+	shape, ok := vt.ParseCursorShape("bar")
+	assert.True(t, ok)
+	assert.Equal(t, vt.CursorBar, shape)
+}
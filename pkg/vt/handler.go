@@ -0,0 +1,379 @@
+package vt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+)
+
+// handler applies parser actions to a Screen. The screen lock is held by
+// Screen.Write for the whole duration of the parse.
+type handler struct {
+	s *Screen
+}
+
+func (h *handler) Print(r rune) {
+	h.s.print(r)
+}
+
+func (h *handler) Execute(b byte) {
+	s := h.s
+	switch b {
+	case 0x07: // BEL
+		s.bellPending = true
+	case 0x08: // BS
+		if s.cursor.X > 0 {
+			s.cursor.X--
+		}
+		s.cursor.wrapNext = false
+	case 0x09: // HT
+		s.tab(1)
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		s.index()
+		if s.modes.NewLine {
+			s.cursor.X = 0
+		}
+	case 0x0d: // CR
+		s.cursor.X = 0
+		s.cursor.wrapNext = false
+	case 0x0e: // SO
+		s.gl = 1
+	case 0x0f: // SI
+		s.gl = 0
+	}
+}
+
+func (h *handler) EscDispatch(intermediates []byte, final byte) {
+	s := h.s
+	if len(intermediates) > 0 {
+		switch intermediates[0] {
+		case '(', ')': // Designate G0 / G1
+			set := charsetASCII
+			if final == '0' {
+				set = charsetDECSpecial
+			}
+			s.charsets[intermediates[0]-'('] = set
+		case '#':
+			if final == '8' {
+				s.alignmentTest()
+			}
+		}
+		return
+	}
+
+	switch final {
+	case '7': // DECSC
+		s.saveCursor()
+	case '8': // DECRC
+		s.restoreCursor()
+	case 'D': // IND
+		s.index()
+	case 'E': // NEL
+		s.index()
+		s.cursor.X = 0
+	case 'H': // HTS
+		s.tabs[s.cursor.X] = true
+	case 'M': // RI
+		s.reverseIndex()
+	case 'c': // RIS
+		s.reset()
+	case '=': // DECKPAM
+		s.modes.AppKeypad = true
+	case '>': // DECKPNM
+		s.modes.AppKeypad = false
+	}
+}
+
+func (h *handler) CsiDispatch(p *Params, intermediates []byte, final byte) {
+	s := h.s
+
+	if len(intermediates) > 0 {
+		h.csiIntermediate(p, intermediates[0], final)
+		return
+	}
+
+	switch p.Private {
+	case 0:
+	case '?':
+		switch final {
+		case 'h':
+			h.setPrivateModes(p, true)
+		case 'l':
+			h.setPrivateModes(p, false)
+		case 'n':
+			if p.Get(0, 0) == 6 { // DECXCPR
+				y, x := h.reportedPosition()
+				s.respond(fmt.Sprintf("\x1b[?%d;%dR", y, x))
+			}
+		}
+		return
+	case '>', '=':
+		// DA2, DA3, XTVERSION
+		if reply, ok := identityQuery(p.Private, p.Raw(0, 0), final); ok {
+			s.respondIdentity(reply)
+		}
+		return
+	default:
+		return
+	}
+
+	switch final {
+	case '@': // ICH
+		s.insertChars(p.Get(0, 1))
+	case 'A': // CUU
+		s.moveRel(0, -p.Get(0, 1))
+	case 'B', 'e': // CUD, VPR
+		s.moveRel(0, p.Get(0, 1))
+	case 'C', 'a': // CUF, HPR
+		s.moveRel(p.Get(0, 1), 0)
+	case 'D': // CUB
+		s.moveRel(-p.Get(0, 1), 0)
+	case 'E': // CNL
+		s.moveRel(0, p.Get(0, 1))
+		s.cursor.X = 0
+	case 'F': // CPL
+		s.moveRel(0, -p.Get(0, 1))
+		s.cursor.X = 0
+	case 'G', '`': // CHA, HPA
+		s.cursor.X = clamp(p.Get(0, 1)-1, 0, s.cols-1)
+		s.cursor.wrapNext = false
+	case 'H', 'f': // CUP, HVP
+		s.moveTo(p.Get(1, 1)-1, p.Get(0, 1)-1)
+	case 'I': // CHT
+		s.tab(p.Get(0, 1))
+	case 'J': // ED
+		s.eraseDisplay(p.Raw(0, 0))
+	case 'K': // EL
+		s.eraseLine(p.Raw(0, 0))
+	case 'L': // IL
+		s.insertLines(p.Get(0, 1))
+	case 'M': // DL
+		s.deleteLines(p.Get(0, 1))
+	case 'P': // DCH
+		s.deleteChars(p.Get(0, 1))
+	case 'S': // SU
+		s.scrollUp(p.Get(0, 1))
+	case 'T': // SD
+		s.scrollDown(p.Get(0, 1))
+	case 'X': // ECH
+		s.eraseChars(p.Get(0, 1))
+	case 'Z': // CBT
+		s.backTab(p.Get(0, 1))
+	case 'b': // REP, at most a screenful
+		if s.lastRune != 0 {
+			for i := min(p.Get(0, 1), s.rows*s.cols); i > 0; i-- {
+				s.print(s.lastRune)
+			}
+		}
+	case 'c': // DA1
+		if p.Raw(0, 0) == 0 {
+			s.respond(replyDA1)
+		}
+	case 'd': // VPA
+		x := s.cursor.X
+		s.moveTo(x, p.Get(0, 1)-1)
+	case 'g': // TBC
+		switch p.Raw(0, 0) {
+		case 0:
+			s.tabs[s.cursor.X] = false
+		case 3:
+			s.tabs = make([]bool, s.cols)
+		}
+	case 'h':
+		h.setModes(p, true)
+	case 'l':
+		h.setModes(p, false)
+	case 'm': // SGR
+		applySGR(p, &s.cursor.Style)
+	case 'n': // DSR
+		switch p.Raw(0, 0) {
+		case 5:
+			s.respond("\x1b[0n")
+		case 6:
+			y, x := h.reportedPosition()
+			s.respond(fmt.Sprintf("\x1b[%d;%dR", y, x))
+		}
+	case 'r': // DECSTBM
+		s.setScrollRegion(p.Get(0, 1), p.Get(1, s.rows))
+	case 's': // SCOSC
+		s.saveCursor()
+	case 'u': // SCORC
+		s.restoreCursor()
+	case 't': // Window manipulation: only the size report
+		if p.Raw(0, 0) == 18 {
+			s.respond(fmt.Sprintf("\x1b[8;%d;%dt", s.rows, s.cols))
+		}
+	}
+}
+
+// csiIntermediate handles CSI sequences with an intermediate byte.
+func (h *handler) csiIntermediate(p *Params, intermediate, final byte) {
+	s := h.s
+	switch {
+	case intermediate == ' ' && final == 'q': // DECSCUSR
+		s.setCursorStyle(p.Raw(0, 0))
+	case intermediate == '!' && final == 'p': // DECSTR
+		s.softReset()
+	case intermediate == '$' && final == 'p': // DECRQM
+		mode := p.Raw(0, 0)
+		var set bool
+		if p.Private == '?' {
+			set = s.privateMode(mode)
+		} else {
+			set = h.ansiMode(mode)
+		}
+		status := 2 // Reset
+		if set {
+			status = 1
+		}
+		prefix := ""
+		if p.Private == '?' {
+			prefix = "?"
+		}
+		s.respond(fmt.Sprintf("\x1b[%s%d;%d$y", prefix, mode, status))
+	}
+}
+
+func (h *handler) ansiMode(mode int) bool {
+	switch mode {
+	case 4:
+		return h.s.modes.Insert
+	case 20:
+		return h.s.modes.NewLine
+	}
+	return false
+}
+
+func (h *handler) setModes(p *Params, on bool) {
+	for i := 0; i < p.Len(); i++ {
+		switch p.Raw(i, 0) {
+		case 4:
+			h.s.modes.Insert = on
+		case 20:
+			h.s.modes.NewLine = on
+		}
+	}
+}
+
+// storedModes are the DECSET modes without a field of their own that the
+// screen keeps for DECRQM and front-ends. Other modes are ignored, so
+// programs cannot make the screen keep any number they send.
+var storedModes = map[int]bool{
+	5:                    true, // DECSCNM, reverse video
+	2004:                 true, // Bracketed paste
+	ModeGraphemeClusters: true,
+}
+
+func (h *handler) setPrivateModes(p *Params, on bool) {
+	s := h.s
+	for i := 0; i < p.Len(); i++ {
+		mode := p.Raw(i, 0)
+		switch mode {
+		case 1:
+			s.modes.AppCursorKeys = on
+		case 3: // DECCOLM: we do not resize, but xterm clears the screen
+			s.eraseDisplay(2)
+			s.top, s.bottom = 0, s.rows-1
+			s.moveTo(0, 0)
+		case 6:
+			s.modes.Origin = on
+			s.moveTo(0, 0)
+		case 7:
+			s.modes.AutoWrap = on
+			if !on {
+				s.cursor.wrapNext = false
+			}
+		case 12:
+			s.modes.CursorBlink = on
+		case 25:
+			s.cursor.Visible = on
+		case 47, 1047, 1049:
+			s.setAltScreen(mode, on)
+		case 1048:
+			if on {
+				s.saveCursor()
+			} else {
+				s.restoreCursor()
+			}
+		default:
+			if s.setMouseMode(mode, on) {
+				continue
+			}
+			if storedModes[mode] {
+				s.private[mode] = on
+			}
+		}
+	}
+}
+
+// reportedPosition returns the 1-based cursor position for CPR,
+// relative to the scroll region in origin mode.
+func (h *handler) reportedPosition() (int, int) {
+	s := h.s
+	y := s.cursor.Y + 1
+	if s.modes.Origin {
+		y -= s.top
+	}
+	return y, s.cursor.X + 1
+}
+
+func (h *handler) OscDispatch(data []byte) {
+	s := h.s
+	cmd, arg, _ := bytes.Cut(data, []byte(";"))
+	n, err := strconv.Atoi(string(cmd))
+	if err != nil {
+		return
+	}
+	switch n {
+	case 0, 2:
+		s.title = string(arg)
+	case 8:
+		s.setHyperlink(string(arg))
+	case 10, 11:
+		h.dynamicColor(n, string(arg))
+	case 110:
+		s.fg = s.themeFg
+	case 111:
+		s.bg = s.themeBg
+	case 133:
+		s.oscMark(string(arg))
+	}
+}
+
+// dynamicColor handles OSC 10 (foreground) and OSC 11 (background):
+// "?" queries the color, anything else sets it.
+func (h *handler) dynamicColor(n int, arg string) {
+	s := h.s
+	target := &s.fg
+	fallback := color.White
+	if n == 11 {
+		target = &s.bg
+		fallback = color.Black
+	}
+	if arg == "?" {
+		s.respond(fmt.Sprintf("\x1b]%d;%s\x1b\\", n, target.X11(fallback)))
+		return
+	}
+	if c, err := color.Parse(arg); err == nil {
+		*target = c
+	}
+}
+
+func (h *handler) DcsDispatch(p *Params, intermediates []byte, final byte, data []byte) {
+	s := h.s
+	// DECRQSS: DCS $ q <setting> ST
+	if len(intermediates) == 1 && intermediates[0] == '$' && final == 'q' {
+		switch string(data) {
+		case "m":
+			s.respond("\x1bP1$r" + s.cursor.Style.SGR() + "m\x1b\\")
+		case "r":
+			s.respond(fmt.Sprintf("\x1bP1$r%d;%dr\x1b\\", s.top+1, s.bottom+1))
+		case " q":
+			s.respond(fmt.Sprintf("\x1bP1$r%d q\x1b\\", decscusrParam(s.cursorShape, s.modes.CursorBlink)))
+		default:
+			s.respond("\x1bP0$r\x1b\\")
+		}
+	}
+}
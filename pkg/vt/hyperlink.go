@@ -0,0 +1,80 @@
+package vt
+
+import "strings"
+
+// maxHyperlinks bounds the number of distinct OSC 8 links remembered per
+// screen; links beyond it are shown as plain text.
+const maxHyperlinks = 1 << 16
+
+// Hyperlink is a link set by the program with OSC 8.
+type Hyperlink struct {
+	URI string
+	ID  string // The id parameter, which joins cells of one link
+}
+
+// hyperlinks interns OSC 8 links so cells only store a small number.
+type hyperlinks struct {
+	list  []Hyperlink
+	index map[Hyperlink]int
+}
+
+// intern returns the number of h, starting at 1, or 0 when the table is full.
+func (t *hyperlinks) intern(h Hyperlink) int {
+	if n, ok := t.index[h]; ok {
+		return n
+	}
+	if len(t.list) >= maxHyperlinks {
+		return 0
+	}
+	if t.index == nil {
+		t.index = map[Hyperlink]int{}
+	}
+	t.list = append(t.list, h)
+	t.index[h] = len(t.list)
+	return len(t.list)
+}
+
+func (t *hyperlinks) get(n int) (Hyperlink, bool) {
+	if n < 1 || n > len(t.list) {
+		return Hyperlink{}, false
+	}
+	return t.list[n-1], true
+}
+
+// Hyperlink returns the OSC 8 link of a cell's Link number.
+func (s *Screen) Hyperlink(n int) (Hyperlink, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hyperlinks.get(n)
+}
+
+// setHyperlink handles OSC 8: "params;URI" starts a link for the text that
+// follows, an empty URI ends it. params are key=value pairs split by ':'.
+func (s *Screen) setHyperlink(arg string) {
+	params, uri, _ := strings.Cut(arg, ";")
+	if uri == "" {
+		s.cursor.link = 0
+		return
+	}
+	h := Hyperlink{URI: uri}
+	for _, param := range strings.Split(params, ":") {
+		if id, ok := strings.CutPrefix(param, "id="); ok {
+			h.ID = id
+		}
+	}
+	s.cursor.link = s.hyperlinks.intern(h)
+}
+
+// hyperlinkSequence returns the OSC 8 sequence that starts link n, or ends
+// the current one for 0.
+func (s *Screen) hyperlinkSequence(n int) string {
+	h, ok := s.hyperlinks.get(n)
+	if !ok {
+		return "\x1b]8;;\x1b\\"
+	}
+	params := ""
+	if h.ID != "" {
+		params = "id=" + h.ID
+	}
+	return "\x1b]8;" + params + ";" + h.URI + "\x1b\\"
+}
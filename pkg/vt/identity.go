@@ -0,0 +1,76 @@
+package vt
+
+import (
+	"io"
+)
+
+// Name and Version identify the terminal in XTVERSION replies.
+const (
+	Name    = "kariuki"
+	Version = "0.1.0"
+)
+
+// Replies to the identification queries.
+const (
+	replyDA1       = "\x1b[?62;22c"          // VT220 with ANSI color
+	replyDA2       = "\x1b[>1;10;0c"         // VT220, firmware 10
+	replyDA3       = "\x1bP!|4B524B49\x1b\\" // Unit ID "KRKI" in hex
+	replyXTVersion = "\x1bP>|" + Name + "(" + Version + ")\x1b\\"
+)
+
+// identityQuery returns the reply to a CSI sequence that asks who the
+// terminal is, for the private marker, parameter and final byte: DA2,
+// DA3 or XTVERSION. DA1 is left out, see QueryFilter.
+func identityQuery(private byte, param int, final byte) (string, bool) {
+	if param != 0 {
+		return "", false
+	}
+	switch {
+	case private == '>' && final == 'c':
+		return replyDA2, true
+	case private == '=' && final == 'c':
+		return replyDA3, true
+	case private == '>' && final == 'q':
+		return replyXTVersion, true
+	}
+	return "", false
+}
+
+// QueryFilter removes DA2, DA3 and XTVERSION queries from a program output
+// stream on its way to another terminal, so that terminal does not answer
+// them for kariuki; the screen answers them instead (see
+// SetIdentityWriter). DA1 still reaches the other terminal: programs send
+// it after their other queries and take its answer as the last one, which
+// only holds if the terminal answering them all sends it.
+type QueryFilter struct {
+	w       io.Writer
+	scanner csiScanner
+}
+
+func NewQueryFilter(w io.Writer) *QueryFilter {
+	return &QueryFilter{w: w}
+}
+
+func (f *QueryFilter) Write(p []byte) (int, error) {
+	out := f.scanner.scan(p, func(params []byte, final byte) (string, bool) {
+		return "", isIdentityQuery(params, final)
+	})
+	if _, err := f.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// isIdentityQuery reports whether the parameters and final byte of a CSI
+// sequence make one of the queries QueryFilter removes.
+func isIdentityQuery(params []byte, final byte) bool {
+	if len(params) == 0 || (params[0] != '>' && params[0] != '=') {
+		return false
+	}
+	param := string(params[1:])
+	if param != "" && param != "0" {
+		return false
+	}
+	_, ok := identityQuery(params[0], 0, final)
+	return ok
+}
@@ -0,0 +1,45 @@
+package vt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentityReplies(t *testing.T) {
+	var reply, identity bytes.Buffer
+	s := vt.NewScreen(5, 10)
+	s.SetReplyWriter(&reply)
+
+	s.Write([]byte("\x1b[c\x1b[>c\x1b[=0c\x1b[>q"))
+	assert.Equal(t, "\x1b[?62;22c\x1b[>1;10;0c\x1bP!|4B524B49\x1b\\\x1bP>|kariuki("+vt.Version+")\x1b\\", reply.String())
+
+	// Without a reply writer another terminal answers DA1 and the status
+	// queries; the identity writer gets the rest.
+	reply.Reset()
+	s.SetReplyWriter(nil)
+	s.SetIdentityWriter(&identity)
+	s.Write([]byte("\x1b[c\x1b[6n\x1b[>0q\x1b[>1q"))
+	assert.Empty(t, reply.String())
+	assert.Equal(t, "\x1bP>|kariuki("+vt.Version+")\x1b\\", identity.String())
+}
+
+func TestQueryFilter(t *testing.T) {
+	t.Run("Queries", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewQueryFilter(&out)
+		f.Write([]byte("a\x1b[>cb\x1b[=0c\x1b[>q\x1b[c\x1b[>4;1m\x1b[2 q\x1b[?1hc"))
+		assert.Equal(t, "ab\x1b[c\x1b[>4;1m\x1b[2 q\x1b[?1hc", out.String())
+	})
+
+	t.Run("Sequence split across writes", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewQueryFilter(&out)
+		f.Write([]byte("x\x1b"))
+		f.Write([]byte("[>"))
+		f.Write([]byte("0qy\x1b[1m"))
+		assert.Equal(t, "xy\x1b[1m", out.String())
+	})
+}
@@ -0,0 +1,206 @@
+package vt
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LinkKind tells where a link comes from.
+type LinkKind int
+
+const (
+	LinkExplicit LinkKind = iota // Set by the program with OSC 8
+	LinkURL                      // A URL found in the text
+	LinkFile                     // A path with a line number, as in compiler errors
+)
+
+// Link is a link on the screen or in the scrollback.
+type Link struct {
+	Kind LinkKind
+	Text string // As shown on the screen
+	URI  string // The OSC 8 target, the URL, or the path of a file link
+	ID   string // OSC 8 id parameter
+
+	// File links: the line and column after the path, 0 when absent.
+	Line, Col int
+
+	// First and last cell of the link; it may span soft-wrapped rows.
+	Start, End Point
+}
+
+var (
+	urlPattern = regexp.MustCompile(`\b(?:(?:https?|ftp|file)://|mailto:)[^\s<>"'` + "`" + `]+`)
+	// A path with an extension followed by :line or :line:col, e.g.
+	// "foo_test.go:12" or "/usr/lib/go/src/fmt/print.go:270:5".
+	filePattern = regexp.MustCompile(`(?:~|\.{1,2})?/?(?:[\w.+@-]+/)*[\w.+@-]*\.[A-Za-z0-9]+:(\d+)(?::(\d+))?`)
+)
+
+// Links returns the links shown in the current view.
+func (s *Screen) Links() []Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	first := s.scrollback.Len() - s.viewOffset
+	return s.linksBetween(first, first+s.rows-1)
+}
+
+// LinkAt returns the link under a cell of the current view, for example the
+// one the user clicked.
+func (s *Screen) LinkAt(x, y int) (Link, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.viewPoint(x, y)
+	for _, l := range s.linksBetween(p.Row, p.Row) {
+		if !p.before(l.Start) && !l.End.before(p) {
+			return l, true
+		}
+	}
+	return Link{}, false
+}
+
+// linksBetween returns the links on history rows first to last, including
+// those of lines that are soft-wrapped into the range.
+func (s *Screen) linksBetween(first, last int) []Link {
+	total := s.scrollback.Len() + s.rows
+	for first > 0 && s.historyLine(first-1).Wrapped {
+		first--
+	}
+	for last < total-1 && s.historyLine(last).Wrapped {
+		last++
+	}
+
+	var links []Link
+	for row := first; row <= last && row < total; {
+		end := row
+		for end < total-1 && s.historyLine(end).Wrapped {
+			end++
+		}
+		links = append(links, s.lineLinks(row, end)...)
+		row = end + 1
+	}
+	return links
+}
+
+// lineLinks finds the links of one logical line made of history rows
+// first to last.
+func (s *Screen) lineLinks(first, last int) []Link {
+	var (
+		text   strings.Builder
+		cells  []Cell
+		points []Point
+		starts []int // Byte offset of each cell in text
+	)
+	for row := first; row <= last; row++ {
+		for col, c := range s.historyLine(row).Cells {
+			starts = append(starts, text.Len())
+			cells = append(cells, c)
+			points = append(points, Point{Row: row, Col: col})
+			text.WriteString(c.Char())
+		}
+	}
+	str := text.String()
+	cellAt := func(offset int) int {
+		return sort.SearchInts(starts, offset+1) - 1
+	}
+
+	var links []Link
+	taken := make([]bool, len(cells))
+
+	// Runs of cells with the same OSC 8 link.
+	for i := 0; i < len(cells); {
+		n := cells[i].Link
+		j := i
+		for j < len(cells) && cells[j].Link == n {
+			j++
+		}
+		if h, ok := s.hyperlinks.get(n); ok {
+			links = append(links, Link{
+				Kind:  LinkExplicit,
+				Text:  str[starts[i]:endOffset(starts, j, len(str))],
+				URI:   h.URI,
+				ID:    h.ID,
+				Start: points[i],
+				End:   points[j-1],
+			})
+			for k := i; k < j; k++ {
+				taken[k] = true
+			}
+		}
+		i = j
+	}
+
+	add := func(kind LinkKind, from, to int, uri string, line, col int) {
+		first, last := cellAt(from), cellAt(to-1)
+		for k := first; k <= last; k++ {
+			if taken[k] {
+				return
+			}
+		}
+		for k := first; k <= last; k++ {
+			taken[k] = true
+		}
+		links = append(links, Link{
+			Kind:  kind,
+			Text:  str[from:to],
+			URI:   uri,
+			Line:  line,
+			Col:   col,
+			Start: points[first],
+			End:   points[last],
+		})
+	}
+
+	for _, m := range urlPattern.FindAllStringIndex(str, -1) {
+		url := trimURL(str[m[0]:m[1]])
+		add(LinkURL, m[0], m[0]+len(url), url, 0, 0)
+	}
+	for _, m := range filePattern.FindAllStringSubmatchIndex(str, -1) {
+		if m[0] > 0 && !isPathBoundary(str[m[0]-1]) {
+			continue
+		}
+		if m[1] < len(str) && isWordByte(str[m[1]]) {
+			continue
+		}
+		line, _ := strconv.Atoi(str[m[2]:m[3]])
+		col := 0
+		if m[4] >= 0 {
+			col, _ = strconv.Atoi(str[m[4]:m[5]])
+		}
+		path := str[m[0] : m[2]-1]
+		add(LinkFile, m[0], m[1], path, line, col)
+	}
+	return links
+}
+
+// endOffset returns where cell i ends in the text.
+func endOffset(starts []int, i, length int) int {
+	if i < len(starts) {
+		return starts[i]
+	}
+	return length
+}
+
+// trimURL drops punctuation that ends a sentence rather than the URL,
+// keeping a closing parenthesis that has an opening one in the URL.
+func trimURL(url string) string {
+	for len(url) > 0 {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"]}>", last) >= 0:
+		case last == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+		default:
+			return url
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+func isPathBoundary(c byte) bool {
+	return strings.IndexByte(" \t([{<'\"`=:,", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,85 @@
+package vt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperlinks(t *testing.T) {
+	t.Run("OSC 8", func(t *testing.T) {
+		s := newScreen(2, 20, "see \x1b]8;id=1;https://example.com/\x1b\\docs\x1b]8;;\x1b\\ now")
+		n := s.Cell(4, 0).Link
+		require.NotZero(t, n)
+		assert.Zero(t, s.Cell(8, 0).Link)
+		h, ok := s.Hyperlink(n)
+		require.True(t, ok)
+		assert.Equal(t, vt.Hyperlink{URI: "https://example.com/", ID: "1"}, h)
+
+		l, ok := s.LinkAt(5, 0)
+		require.True(t, ok)
+		assert.Equal(t, vt.Link{
+			Kind:  vt.LinkExplicit,
+			Text:  "docs",
+			URI:   "https://example.com/",
+			ID:    "1",
+			Start: vt.Point{Row: 0, Col: 4},
+			End:   vt.Point{Row: 0, Col: 7},
+		}, l)
+
+		_, ok = s.LinkAt(10, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("Render keeps links", func(t *testing.T) {
+		s := newScreen(1, 4, "\x1b]8;;file:///x\x07ab\x1b]8;;\x07c")
+		var out bytes.Buffer
+		s.Render(&out)
+		assert.Contains(t, out.String(), "\x1b]8;;file:///x\x1b\\ab\x1b]8;;\x1b\\c")
+	})
+}
+
+func TestDetectedLinks(t *testing.T) {
+	t.Run("URL", func(t *testing.T) {
+		s := newScreen(2, 40, "docs at (https://go.dev/doc/faq).")
+		links := s.Links()
+		require.Len(t, links, 1)
+		assert.Equal(t, vt.LinkURL, links[0].Kind)
+		assert.Equal(t, "https://go.dev/doc/faq", links[0].URI)
+		assert.Equal(t, vt.Point{Row: 0, Col: 9}, links[0].Start)
+	})
+
+	t.Run("Go test failure", func(t *testing.T) {
+		s := newScreen(2, 40, "    screen_test.go:42: not equal\r\n/src/fmt/print.go:270:5 x")
+		links := s.Links()
+		require.Len(t, links, 2)
+		assert.Equal(t, vt.Link{
+			Kind:  vt.LinkFile,
+			Text:  "screen_test.go:42",
+			URI:   "screen_test.go",
+			Line:  42,
+			Start: vt.Point{Row: 0, Col: 4},
+			End:   vt.Point{Row: 0, Col: 20},
+		}, links[0])
+		assert.Equal(t, "/src/fmt/print.go", links[1].URI)
+		assert.Equal(t, 270, links[1].Line)
+		assert.Equal(t, 5, links[1].Col)
+	})
+
+	t.Run("Across a soft wrap", func(t *testing.T) {
+		s := newScreen(3, 10, "x https://a.io/long/path y")
+		l, ok := s.LinkAt(2, 2)
+		require.True(t, ok)
+		assert.Equal(t, "https://a.io/long/path", l.URI)
+		assert.Equal(t, vt.Point{Row: 0, Col: 2}, l.Start)
+		assert.Equal(t, vt.Point{Row: 2, Col: 3}, l.End)
+	})
+
+	t.Run("Not links", func(t *testing.T) {
+		s := newScreen(1, 40, "at 12:30 build.v2 x.go:1a")
+		assert.Empty(t, s.Links())
+	})
+}
@@ -0,0 +1,304 @@
+package vt
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MarkKind is a shell integration mark: the OSC 133 sequences of FinalTerm
+// that shells send around their prompt, "ESC ] 133 ; A BEL" and so on.
+type MarkKind byte
+
+const (
+	MarkPrompt MarkKind = 'A' // A prompt starts
+	MarkInput  MarkKind = 'B' // The command line starts, after the prompt
+	MarkOutput MarkKind = 'C' // The command runs and its output starts
+	MarkEnd    MarkKind = 'D' // The command ended, with its exit status
+)
+
+// Mark is a shell integration mark at a column of a line.
+type Mark struct {
+	Kind MarkKind
+	Col  int
+	// Status is the exit status of the command, on its MarkEnd and, once it
+	// ended, on the MarkPrompt it started at; -1 when it is not known.
+	Status int
+}
+
+// Command is a command line and its output, found from the marks. Points
+// are rows of the history, as for the selection.
+type Command struct {
+	Prompt Point
+	Input  Point // Where the command line starts, the prompt when not marked
+	Output Point // Where the output starts
+	End    Point // Where the output ends, not included; Row -1 while running
+	Status int   // Exit status; -1 while running or when the shell did not tell
+}
+
+// Finished reports whether the command has ended.
+func (c Command) Finished() bool {
+	return c.End.Row >= 0
+}
+
+// oscMark handles OSC 133 with its argument, e.g. "D;1". Marks of the
+// alternate screen are ignored: full screen programs do not run commands.
+func (s *Screen) oscMark(arg string) {
+	if s.alt.active || arg == "" {
+		return
+	}
+	kind := MarkKind(arg[0])
+	m := Mark{Kind: kind, Col: s.cursor.X, Status: -1}
+	switch kind {
+	case MarkPrompt, MarkInput, MarkOutput:
+	case MarkEnd:
+		if _, status, ok := strings.Cut(arg, ";"); ok {
+			status, _, _ = strings.Cut(status, ";")
+			if n, err := strconv.Atoi(status); err == nil {
+				m.Status = n
+			}
+		}
+	default:
+		return
+	}
+
+	// A shell that draws its prompt again sends the marks again.
+	line := &s.lines[s.cursor.Y]
+	i := len(line.Marks)
+	for j, old := range line.Marks {
+		if old.Kind == kind {
+			i = j
+			break
+		}
+	}
+	if i == len(line.Marks) {
+		line.Marks = append(line.Marks, m)
+	} else {
+		line.Marks[i] = m
+	}
+	if kind == MarkEnd {
+		s.endCommand(s.cursor.Y, i, m.Status)
+	}
+}
+
+// endCommand records status on the prompt of the command that the end mark
+// at index i of screen row y ends, unless its command line was empty.
+func (s *Screen) endCommand(y, i, status int) {
+	row := s.scrollback.Len() + y
+	marks := s.lines[y].Marks[:i]
+	for {
+		for j := len(marks) - 1; j >= 0; j-- {
+			switch marks[j].Kind {
+			case MarkEnd:
+				return // Ended before
+			case MarkPrompt:
+				c := s.command(Point{Row: row, Col: marks[j].Col}, marks[j+1:], s.scrollback.Len()+y)
+				if status >= 0 && s.commandLine(c) != "" {
+					marks[j].Status = status
+					s.ended, s.endedPrompt = true, Point{Row: row, Col: marks[j].Col}
+				}
+				return
+			}
+		}
+		if row--; row < 0 {
+			return
+		}
+		marks = s.historyLine(row).Marks
+	}
+}
+
+// command returns the command of the prompt at p, looking for its input
+// mark in rest, the marks after the prompt on its row, and on later rows
+// up to last.
+func (s *Screen) command(p Point, rest []Mark, last int) Command {
+	c := Command{Prompt: p, Input: p, End: Point{Row: -1}, Status: -1}
+	for row := p.Row; row <= last; row++ {
+		marks := rest
+		if row > p.Row {
+			marks = s.historyLine(row).Marks
+		}
+		for _, m := range marks {
+			if m.Kind == MarkInput {
+				c.Input = Point{Row: row, Col: m.Col}
+				return c
+			}
+			if m.Kind == MarkPrompt {
+				return c
+			}
+		}
+	}
+	return c
+}
+
+// Commands returns the commands of the history, oldest first. A command
+// whose output was not marked is taken to print from the row after its
+// command line; one followed by another prompt before it ended is taken
+// to end there.
+func (s *Screen) Commands() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands()
+}
+
+func (s *Screen) commands() []Command {
+	var cmds []Command
+	var output []bool // Whether the output of each command was marked
+	rows := s.scrollback.Len() + s.rows
+	for row := 0; row < rows; row++ {
+		for _, m := range s.mainLine(row).Marks {
+			p := Point{Row: row, Col: m.Col}
+			if m.Kind == MarkPrompt {
+				if n := len(cmds); n > 0 && !cmds[n-1].Finished() {
+					cmds[n-1].End = p
+				}
+				cmds = append(cmds, Command{Prompt: p, Input: p, Output: Point{Row: -1}, End: Point{Row: -1}, Status: -1})
+				output = append(output, false)
+				continue
+			}
+			n := len(cmds)
+			if n == 0 || cmds[n-1].Finished() {
+				continue
+			}
+			c := &cmds[n-1]
+			switch m.Kind {
+			case MarkInput:
+				c.Input = p
+			case MarkOutput:
+				if !output[n-1] {
+					c.Output, output[n-1] = p, true
+				}
+			case MarkEnd:
+				c.End, c.Status = p, m.Status
+			}
+		}
+	}
+	for i := range cmds {
+		if !output[i] {
+			// The output starts below the command line.
+			row := cmds[i].Input.Row
+			for row < rows-1 && s.mainLine(row).Wrapped {
+				row++
+			}
+			cmds[i].Output = Point{Row: row + 1}
+		}
+		if cmds[i].Finished() && cmds[i].End.before(cmds[i].Output) {
+			cmds[i].Output = cmds[i].End
+		}
+	}
+	return cmds
+}
+
+// mainLine returns a row of the history of the main screen: while the
+// alternate screen is shown, its rows are not the history.
+func (s *Screen) mainLine(row int) Line {
+	if s.alt.active && row >= s.scrollback.Len() {
+		row -= s.scrollback.Len()
+		if row < 0 || row >= len(s.alt.lines) {
+			return Line{}
+		}
+		return s.alt.lines[row]
+	}
+	return s.historyLine(row)
+}
+
+// CommandLine returns the command line of c, joined if it wraps.
+func (s *Screen) CommandLine(c Command) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commandLine(c)
+}
+
+func (s *Screen) commandLine(c Command) string {
+	end := c.Input.Row
+	for s.historyLine(end).Wrapped {
+		end++
+	}
+	return strings.TrimSpace(s.textBetween(c.Input, Point{Row: end, Col: s.cols - 1}))
+}
+
+// Output returns the text c printed, without the blank rows at its end.
+func (s *Screen) Output(c Command) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := c.End
+	if !c.Finished() {
+		end = Point{Row: s.scrollback.Len() + s.cursor.Y, Col: s.cursor.X}
+	}
+	// The end is not part of the output.
+	if end.Col > 0 {
+		end.Col--
+	} else {
+		end = Point{Row: end.Row - 1, Col: s.cols - 1}
+	}
+	if end.before(c.Output) {
+		return ""
+	}
+	return strings.TrimRight(s.textBetween(c.Output, end), "\n ")
+}
+
+// ScrollViewToRow scrolls the view so row of the history is at its top,
+// as far as the history goes.
+func (s *Screen) ScrollViewToRow(row int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.viewOffset = clamp(s.scrollback.Len()-row, 0, s.scrollback.Len())
+}
+
+// TopRow returns the row of the history at the top of the view.
+func (s *Screen) TopRow() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scrollback.Len() - s.viewOffset
+}
+
+// TakeEnded returns the prompt of the last command that ended since the
+// last call, if it was given an exit status.
+func (s *Screen) TakeEnded() (Point, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ended := s.ended
+	s.ended = false
+	return s.endedPrompt, ended
+}
+
+// StatusSequence returns what draws the exit status of the command whose
+// prompt is at p at the end of its row, on a host terminal showing the
+// live screen, or "" when the row is not on the screen.
+func (s *Screen) StatusSequence(p Point) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	y := p.Row - s.scrollback.Len()
+	if y < 0 || y >= s.rows || s.alt.active {
+		return ""
+	}
+	return s.statusBadge(s.lines[y], y, 0, 0)
+}
+
+// statusBadge returns what draws the exit status on the prompt of line,
+// at screen row y, if the end of the row is blank: a check mark for
+// success, a cross and the status otherwise.
+func (s *Screen) statusBadge(line Line, y, top, left int) string {
+	status := -1
+	for _, m := range line.Marks {
+		if m.Kind == MarkPrompt {
+			status = m.Status
+		}
+	}
+	if status < 0 {
+		return ""
+	}
+	badge, sgr := " ✔ ", "32"
+	if status != 0 {
+		badge, sgr = " ✘ "+strconv.Itoa(status)+" ", "31"
+	}
+	x := s.cols - utf8.RuneCountInString(badge)
+	if x < 1 || len(line.Cells) < s.cols {
+		return ""
+	}
+	for _, c := range line.Cells[x-1:] {
+		if c.Rune != 0 && c.Rune != ' ' || c.Continuation {
+			return ""
+		}
+	}
+	return "\x1b[" + strconv.Itoa(top+y+1) + ";" + strconv.Itoa(left+x+1) + "H\x1b[0;" + sgr + "m" + badge + "\x1b[0m"
+}
@@ -0,0 +1,130 @@
+package vt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shell writes what a shell with OSC 133 marks prints for a prompt and the
+// commands, each with its output and exit status.
+func shell(commands ...string) string {
+	var b bytes.Buffer
+	status := "0"
+	for i := 0; i+2 < len(commands); i += 3 {
+		b.WriteString("\x1b]133;D;" + status + "\a\x1b]133;A\a$ \x1b]133;B\a" + commands[i] + "\r\n\x1b]133;C\a" + commands[i+1])
+		status = commands[i+2]
+	}
+	b.WriteString("\x1b]133;D;" + status + "\a\x1b]133;A\a$ \x1b]133;B\a")
+	return b.String()
+}
+
+func TestMarks(t *testing.T) {
+	t.Run("Commands", func(t *testing.T) {
+		s := newScreen(10, 20, shell("ls", "a\r\nb\r\n", "0", "false", "", "1"))
+		commands := s.Commands()
+		require.Len(t, commands, 3)
+
+		ls := commands[0]
+		assert.Equal(t, vt.Point{Row: 0, Col: 0}, ls.Prompt)
+		assert.Equal(t, vt.Point{Row: 0, Col: 2}, ls.Input)
+		assert.Equal(t, vt.Point{Row: 1, Col: 0}, ls.Output)
+		assert.Equal(t, vt.Point{Row: 3, Col: 0}, ls.End)
+		assert.Equal(t, 0, ls.Status)
+		assert.Equal(t, "ls", s.CommandLine(ls))
+		assert.Equal(t, "a\nb", s.Output(ls))
+
+		assert.Equal(t, "false", s.CommandLine(commands[1]))
+		assert.Equal(t, 1, commands[1].Status)
+		assert.Equal(t, "", s.Output(commands[1]))
+
+		// The last prompt waits for a command.
+		assert.False(t, commands[2].Finished())
+		assert.Equal(t, -1, commands[2].Status)
+	})
+
+	t.Run("Status on the prompt", func(t *testing.T) {
+		s := newScreen(10, 20, shell("false", "", "2", "", "", "2"))
+		marks := s.Line(0).Marks
+		require.Len(t, marks, 3)
+		assert.Equal(t, vt.Mark{Kind: vt.MarkPrompt, Col: 0, Status: 2}, marks[1])
+		assert.Equal(t, vt.Mark{Kind: vt.MarkInput, Col: 2, Status: -1}, marks[2])
+
+		// An empty command line keeps the status of the one before; it is
+		// not shown for it.
+		assert.Equal(t, vt.Mark{Kind: vt.MarkPrompt, Col: 0, Status: -1}, s.Line(1).Marks[2])
+	})
+
+	t.Run("Unmarked output", func(t *testing.T) {
+		s := newScreen(10, 20, "\x1b]133;A\a$ \x1b]133;B\aecho hi\r\nhi\r\n\x1b]133;A\a$ ")
+		commands := s.Commands()
+		require.Len(t, commands, 2)
+		assert.Equal(t, vt.Point{Row: 1, Col: 0}, commands[0].Output)
+		assert.Equal(t, vt.Point{Row: 2, Col: 0}, commands[0].End)
+		assert.Equal(t, -1, commands[0].Status)
+		assert.Equal(t, "hi", s.Output(commands[0]))
+	})
+
+	t.Run("Running command", func(t *testing.T) {
+		s := newScreen(10, 20, shell()+"sleep 9\r\n\x1b]133;C\aworking")
+		commands := s.Commands()
+		require.Len(t, commands, 1)
+		assert.False(t, commands[0].Finished())
+		assert.Equal(t, "working", s.Output(commands[0]))
+	})
+
+	t.Run("Alternate screen", func(t *testing.T) {
+		s := newScreen(10, 20, shell()+"vi\r\n\x1b]133;C\a\x1b[?1049h\x1b]133;A\a")
+		assert.Len(t, s.Commands(), 1)
+	})
+
+	t.Run("Scrollback and reflow", func(t *testing.T) {
+		s := newScreen(3, 10, shell("seq", "1\r\n2\r\n3\r\n4\r\n", "0", "echo abcdefghijkl", "abcdefghijkl\r\n", "0"))
+		commands := s.Commands()
+		require.Len(t, commands, 3)
+		assert.Equal(t, "1\n2\n3\n4", s.Output(commands[0]))
+		assert.Equal(t, "echo abcdefghijkl", s.CommandLine(commands[1]))
+
+		s.Resize(3, 30)
+		commands = s.Commands()
+		require.Len(t, commands, 3)
+		assert.Equal(t, "1\n2\n3\n4", s.Output(commands[0]))
+		assert.Equal(t, "echo abcdefghijkl", s.CommandLine(commands[1]))
+		assert.Equal(t, "abcdefghijkl", s.Output(commands[1]))
+		assert.Equal(t, vt.Point{Row: 5, Col: 2}, commands[1].Input)
+	})
+
+	t.Run("Jump to a prompt", func(t *testing.T) {
+		s := newScreen(3, 10, shell("seq", "1\r\n2\r\n3\r\n4\r\n", "0"))
+		assert.Equal(t, 3, s.TopRow())
+		s.ScrollViewToRow(0)
+		assert.Equal(t, 3, s.ViewOffset())
+		assert.Equal(t, "$ seq", s.View()[0].Text())
+		s.ScrollViewToRow(9)
+		assert.Zero(t, s.ViewOffset())
+	})
+
+	t.Run("Status badge", func(t *testing.T) {
+		s := newScreen(3, 20, shell("true", "", "0", "false", "", "1"))
+		var out bytes.Buffer
+		require.NoError(t, s.Render(&out))
+		assert.Contains(t, out.String(), "\x1b[1;18H\x1b[0;32m ✔ \x1b[0m")
+		assert.Contains(t, out.String(), "\x1b[2;16H\x1b[0;31m ✘ 1 \x1b[0m")
+
+		p, ok := s.TakeEnded()
+		require.True(t, ok)
+		assert.Equal(t, vt.Point{Row: 1, Col: 0}, p)
+		assert.Equal(t, "\x1b[2;16H\x1b[0;31m ✘ 1 \x1b[0m", s.StatusSequence(p))
+		_, ok = s.TakeEnded()
+		assert.False(t, ok)
+
+		// A long command line has no room for it.
+		s = newScreen(3, 20, shell("echo abcdefghijklmn", "", "0"))
+		out.Reset()
+		require.NoError(t, s.Render(&out))
+		assert.NotContains(t, out.String(), "✔")
+	})
+}
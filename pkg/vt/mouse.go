@@ -0,0 +1,273 @@
+package vt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// MouseTracking is the mouse reporting mode requested by the program.
+type MouseTracking int
+
+const (
+	MouseOff         MouseTracking = iota
+	MouseX10                       // ?9: button presses only
+	MouseNormal                    // ?1000: presses and releases
+	MouseButtonEvent               // ?1002: plus motion while a button is held
+	MouseAnyEvent                  // ?1003: plus all motion
+)
+
+// MouseEncoding is the format of mouse reports sent to the program.
+type MouseEncoding int
+
+const (
+	MouseEncodingDefault MouseEncoding = iota // ESC [ M Cb Cx Cy
+	MouseEncodingUTF8                         // ?1005
+	MouseEncodingSGR                          // ?1006: ESC [ < b ; x ; y M/m
+	MouseEncodingURXVT                        // ?1015: ESC [ b ; x ; y M
+)
+
+var trackingModes = map[int]MouseTracking{
+	9:    MouseX10,
+	1000: MouseNormal,
+	1002: MouseButtonEvent,
+	1003: MouseAnyEvent,
+}
+
+var encodingModes = map[int]MouseEncoding{
+	1005: MouseEncodingUTF8,
+	1006: MouseEncodingSGR,
+	1015: MouseEncodingURXVT,
+}
+
+// MouseButton identifies the button of a mouse event.
+type MouseButton int
+
+const (
+	ButtonLeft MouseButton = iota
+	ButtonMiddle
+	ButtonRight
+	ButtonNone // Motion without a button
+	WheelUp
+	WheelDown
+)
+
+// MouseAction is what happened to the button.
+type MouseAction int
+
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseMotion
+)
+
+// MouseEvent is a mouse event at a cell, zero based.
+type MouseEvent struct {
+	X, Y   int
+	Button MouseButton
+	Action MouseAction
+	Shift  bool
+	Alt    bool
+	Ctrl   bool
+}
+
+// MouseTracking returns the reporting mode requested by the program.
+func (s *Screen) MouseTracking() MouseTracking {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mouseTracking
+}
+
+// MouseEncoding returns the report format requested by the program.
+func (s *Screen) MouseEncoding() MouseEncoding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mouseEncoding
+}
+
+// setMouseMode handles the DECSET modes of mouse reporting. The tracking
+// modes replace each other, as do the encodings, like in xterm.
+func (s *Screen) setMouseMode(mode int, on bool) bool {
+	if tracking, ok := trackingModes[mode]; ok {
+		if on {
+			s.mouseTracking = tracking
+		} else if s.mouseTracking == tracking {
+			s.mouseTracking = MouseOff
+		}
+		return true
+	}
+	if encoding, ok := encodingModes[mode]; ok {
+		if on {
+			s.mouseEncoding = encoding
+		} else if s.mouseEncoding == encoding {
+			s.mouseEncoding = MouseEncodingDefault
+		}
+		return true
+	}
+	return false
+}
+
+func (s *Screen) mouseModeSet(mode int) (set, ok bool) {
+	if tracking, ok := trackingModes[mode]; ok {
+		return s.mouseTracking == tracking, true
+	}
+	if encoding, ok := encodingModes[mode]; ok {
+		return s.mouseEncoding == encoding, true
+	}
+	return false, false
+}
+
+// EncodeMouse returns the report for ev in the format the program asked for.
+// It returns false when the program does not want this kind of event.
+func (s *Screen) EncodeMouse(ev MouseEvent) ([]byte, bool) {
+	s.mu.Lock()
+	tracking, encoding := s.mouseTracking, s.mouseEncoding
+	s.mu.Unlock()
+
+	switch tracking {
+	case MouseOff:
+		return nil, false
+	case MouseX10:
+		if ev.Action != MousePress {
+			return nil, false
+		}
+		ev.Shift, ev.Alt, ev.Ctrl = false, false, false
+	case MouseNormal:
+		if ev.Action == MouseMotion {
+			return nil, false
+		}
+	case MouseButtonEvent:
+		if ev.Action == MouseMotion && ev.Button == ButtonNone {
+			return nil, false
+		}
+	}
+	// Wheel "buttons" have no release.
+	if ev.Action == MouseRelease && (ev.Button == WheelUp || ev.Button == WheelDown) {
+		return nil, false
+	}
+
+	code := buttonCode(ev)
+	x, y := ev.X+1, ev.Y+1
+
+	switch encoding {
+	case MouseEncodingSGR:
+		final := 'M'
+		if ev.Action == MouseRelease {
+			final = 'm'
+		}
+		return fmt.Appendf(nil, "\x1b[<%d;%d;%d%c", code, x, y, final), true
+	case MouseEncodingURXVT:
+		if ev.Action == MouseRelease {
+			code = code&^3 | 3
+		}
+		return fmt.Appendf(nil, "\x1b[%d;%d;%dM", code+32, x, y), true
+	}
+
+	// Legacy encodings cannot tell which button was released.
+	if ev.Action == MouseRelease {
+		code = code&^3 | 3
+	}
+	buf := []byte("\x1b[M")
+	buf = append(buf, byte(code+32))
+	if encoding == MouseEncodingUTF8 {
+		if x > 2015 || y > 2015 {
+			return nil, false
+		}
+		buf = append(buf, string(rune(x+32))...)
+		buf = append(buf, string(rune(y+32))...)
+		return buf, true
+	}
+	if x > 223 || y > 223 {
+		return nil, false
+	}
+	return append(buf, byte(x+32), byte(y+32)), true
+}
+
+func buttonCode(ev MouseEvent) int {
+	var code int
+	switch ev.Button {
+	case ButtonLeft, ButtonMiddle, ButtonRight, ButtonNone:
+		code = int(ev.Button)
+	case WheelUp:
+		code = 64
+	case WheelDown:
+		code = 65
+	}
+	if ev.Action == MouseMotion {
+		code += 32
+	}
+	if ev.Shift {
+		code += 4
+	}
+	if ev.Alt {
+		code += 8
+	}
+	if ev.Ctrl {
+		code += 16
+	}
+	return code
+}
+
+// ParseSGRMouse decodes an SGR mouse report ("ESC [ < b ; x ; y M" or "m")
+// at the start of data, as sent by a host terminal in mode 1006.
+// It returns the event and the number of bytes used; n is 0 when data does
+// not start with a report and -1 when the report is incomplete.
+func ParseSGRMouse(data []byte) (ev MouseEvent, n int) {
+	const prefix = "\x1b[<"
+	if !bytes.HasPrefix(data, []byte(prefix)) {
+		if bytes.HasPrefix([]byte(prefix), data) && len(data) > 0 {
+			return ev, -1
+		}
+		return ev, 0
+	}
+
+	end := bytes.IndexAny(data[len(prefix):], "Mm")
+	if end < 0 {
+		for _, c := range data[len(prefix):] {
+			if (c < '0' || c > '9') && c != ';' {
+				return ev, 0
+			}
+		}
+		return ev, -1
+	}
+	end += len(prefix)
+
+	fields := bytes.Split(data[len(prefix):end], []byte(";"))
+	if len(fields) != 3 {
+		return ev, 0
+	}
+	var v [3]int
+	for i, f := range fields {
+		n, err := strconv.Atoi(string(f))
+		if err != nil {
+			return ev, 0
+		}
+		v[i] = n
+	}
+
+	code := v[0]
+	ev.X, ev.Y = v[1]-1, v[2]-1
+	ev.Shift = code&4 != 0
+	ev.Alt = code&8 != 0
+	ev.Ctrl = code&16 != 0
+
+	switch {
+	case code&64 != 0:
+		ev.Button = WheelUp
+		if code&1 != 0 {
+			ev.Button = WheelDown
+		}
+	default:
+		ev.Button = MouseButton(code & 3)
+	}
+
+	switch {
+	case data[end] == 'm':
+		ev.Action = MouseRelease
+	case code&32 != 0:
+		ev.Action = MouseMotion
+	default:
+		ev.Action = MousePress
+	}
+	return ev, end + 1
+}
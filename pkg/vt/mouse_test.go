@@ -0,0 +1,99 @@
+package vt_test
+
+import (
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeMouse(t *testing.T) {
+	press := vt.MouseEvent{X: 4, Y: 2, Button: vt.ButtonLeft, Action: vt.MousePress}
+	release := vt.MouseEvent{X: 4, Y: 2, Button: vt.ButtonLeft, Action: vt.MouseRelease}
+	drag := vt.MouseEvent{X: 5, Y: 2, Button: vt.ButtonLeft, Action: vt.MouseMotion}
+	move := vt.MouseEvent{X: 5, Y: 2, Button: vt.ButtonNone, Action: vt.MouseMotion}
+
+	t.Run("Off", func(t *testing.T) {
+		s := vt.NewScreen(5, 10)
+		_, ok := s.EncodeMouse(press)
+		assert.False(t, ok)
+	})
+
+	t.Run("X10", func(t *testing.T) {
+		s := newScreen(5, 10, "\x1b[?9h")
+		out, ok := s.EncodeMouse(press)
+		assert.True(t, ok)
+		assert.Equal(t, "\x1b[M\x20\x25\x23", string(out))
+		_, ok = s.EncodeMouse(release)
+		assert.False(t, ok)
+	})
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newScreen(5, 10, "\x1b[?1000h")
+		out, _ := s.EncodeMouse(release)
+		assert.Equal(t, "\x1b[M\x23\x25\x23", string(out))
+		_, ok := s.EncodeMouse(drag)
+		assert.False(t, ok)
+	})
+
+	t.Run("Button event SGR", func(t *testing.T) {
+		s := newScreen(5, 10, "\x1b[?1002h\x1b[?1006h")
+		out, ok := s.EncodeMouse(drag)
+		assert.True(t, ok)
+		assert.Equal(t, "\x1b[<32;6;3M", string(out))
+		out, _ = s.EncodeMouse(release)
+		assert.Equal(t, "\x1b[<0;5;3m", string(out))
+		_, ok = s.EncodeMouse(move)
+		assert.False(t, ok)
+	})
+
+	t.Run("Any event and wheel", func(t *testing.T) {
+		s := newScreen(5, 10, "\x1b[?1003h\x1b[?1006h")
+		out, _ := s.EncodeMouse(move)
+		assert.Equal(t, "\x1b[<35;6;3M", string(out))
+		out, _ = s.EncodeMouse(vt.MouseEvent{Button: vt.WheelDown, Action: vt.MousePress, Ctrl: true})
+		assert.Equal(t, "\x1b[<81;1;1M", string(out))
+	})
+
+	t.Run("Modes replace each other", func(t *testing.T) {
+		s := newScreen(5, 10, "\x1b[?1000h\x1b[?1003h")
+		assert.Equal(t, vt.MouseAnyEvent, s.MouseTracking())
+		assert.False(t, s.PrivateMode(1000))
+		s.Write([]byte("\x1b[?1003l"))
+		assert.Equal(t, vt.MouseOff, s.MouseTracking())
+	})
+}
+
+func TestParseSGRMouse(t *testing.T) {
+	ev, n := vt.ParseSGRMouse([]byte("\x1b[<0;10;5Mrest"))
+	assert.Equal(t, 10, n)
+	assert.Equal(t, vt.MouseEvent{X: 9, Y: 4, Button: vt.ButtonLeft, Action: vt.MousePress}, ev)
+
+	ev, _ = vt.ParseSGRMouse([]byte("\x1b[<65;1;1M"))
+	assert.Equal(t, vt.WheelDown, ev.Button)
+
+	ev, _ = vt.ParseSGRMouse([]byte("\x1b[<36;2;2M"))
+	assert.Equal(t, vt.MouseMotion, ev.Action)
+	assert.True(t, ev.Shift)
+
+	_, n = vt.ParseSGRMouse([]byte("\x1b[<0;10"))
+	assert.Equal(t, -1, n)
+
+	_, n = vt.ParseSGRMouse([]byte("\x1b[A"))
+	assert.Equal(t, 0, n)
+}
+
+func TestSelection(t *testing.T) {
+	s := newScreen(3, 6, "abcdefgh\r\nline 2")
+	s.StartSelection(2, 0)
+	s.ExtendSelection(3, 2)
+	assert.Equal(t, "cdefgh\nline", s.SelectedText())
+
+	// Selecting backwards gives the same text.
+	s.StartSelection(3, 2)
+	s.ExtendSelection(2, 0)
+	assert.Equal(t, "cdefgh\nline", s.SelectedText())
+
+	s.ClearSelection()
+	assert.Equal(t, "", s.SelectedText())
+}
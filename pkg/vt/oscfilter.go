@@ -0,0 +1,176 @@
+package vt
+
+import (
+	"io"
+	"strconv"
+)
+
+// OSCFilter copies a program output stream, holding back the OSC sequences
+// with one number until they are complete and dropping those that allow
+// rejects. Other output passes through unchanged.
+type OSCFilter struct {
+	w      io.Writer
+	number string
+	allow  func(payload string) bool
+	limit  int // Longest payload held back; longer ones are dropped. 0: no limit
+	state  oscFilterState
+	buf    []byte
+}
+
+type oscFilterState int
+
+const (
+	oscGround oscFilterState = iota
+	oscEscape
+	oscNumber     // Reading the number after ESC ]
+	oscHold       // Inside a filtered sequence
+	oscHoldEscape // ESC seen inside a filtered sequence
+	oscPass       // Inside another string sequence
+	oscPassEscape
+	oscDrop // Inside a filtered sequence that is too long
+	oscDropEscape
+)
+
+// NewOSCFilter returns a filter for OSC sequences number; allow gets the
+// payload after "number;".
+func NewOSCFilter(w io.Writer, number int, allow func(payload string) bool, limit int) *OSCFilter {
+	return &OSCFilter{w: w, number: strconv.Itoa(number), allow: allow, limit: limit}
+}
+
+// SetLimit changes the longest payload held back.
+func (f *OSCFilter) SetLimit(limit int) {
+	f.limit = limit
+}
+
+func (f *OSCFilter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		out = f.step(c, out)
+	}
+	if _, err := f.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (f *OSCFilter) step(c byte, out []byte) []byte {
+	switch f.state {
+	case oscGround:
+		if c == 0x1b {
+			f.state = oscEscape
+			return out
+		}
+		return append(out, c)
+
+	case oscEscape:
+		if c == ']' {
+			f.buf = append(f.buf[:0], "\x1b]"...)
+			f.state = oscNumber
+			return out
+		}
+		out = append(out, 0x1b)
+		if c == 0x1b {
+			return out
+		}
+		f.state = oscGround
+		return append(out, c)
+
+	case oscNumber:
+		switch {
+		case c >= '0' && c <= '9':
+			f.buf = append(f.buf, c)
+			return out
+		case c == ';' && string(f.buf[2:]) == f.number:
+			f.buf = append(f.buf, c)
+			f.state = oscHold
+			return out
+		}
+		out = append(out, f.buf...)
+		f.state = oscPass
+		return f.step(c, out)
+
+	case oscHold:
+		switch c {
+		case 0x07:
+			f.state = oscGround
+			return f.finish(out, "\a")
+		case 0x1b:
+			f.state = oscHoldEscape
+		case 0x18, 0x1a: // CAN, SUB: the sequence is cancelled
+			f.state = oscGround
+		default:
+			f.buf = append(f.buf, c)
+			if f.limit > 0 && len(f.buf)-len(f.number)-3 > f.limit {
+				f.state = oscDrop
+			}
+		}
+		return out
+
+	case oscHoldEscape:
+		// Any escape ends the string; only ESC \ is a proper terminator.
+		out = f.finish(out, "\x1b\\")
+		f.state = oscGround
+		if c == '\\' {
+			return out
+		}
+		return f.afterString(c, out)
+
+	case oscPass:
+		switch c {
+		case 0x07, 0x18, 0x1a:
+			f.state = oscGround
+		case 0x1b:
+			f.state = oscPassEscape
+			return out
+		}
+		return append(out, c)
+
+	case oscPassEscape:
+		// Any escape ends the string, as for a filtered sequence; the host
+		// gets ST and the escape is read again, as it may start the next one.
+		out = append(out, "\x1b\\"...)
+		f.state = oscGround
+		if c == '\\' {
+			return out
+		}
+		return f.afterString(c, out)
+
+	case oscDrop:
+		switch c {
+		case 0x07, 0x18, 0x1a:
+			f.state = oscGround
+		case 0x1b:
+			f.state = oscDropEscape
+		}
+		return out
+
+	case oscDropEscape:
+		f.state = oscGround
+		if c == '\\' {
+			return out
+		}
+		return f.afterString(c, out)
+	}
+	return out
+}
+
+// afterString reads c, the byte after an escape that ended a string
+// sequence, as following an escape: it may start the next sequence.
+func (f *OSCFilter) afterString(c byte, out []byte) []byte {
+	f.state = oscEscape
+	if c == 0x1b {
+		return out // The escape that ended the string is not passed on twice
+	}
+	return f.step(c, out)
+}
+
+// finish writes a complete filtered sequence if it is allowed.
+func (f *OSCFilter) finish(out []byte, terminator string) []byte {
+	payload := string(f.buf[len(f.number)+3:])
+	if f.allow(payload) {
+		out = append(out, f.buf...)
+		out = append(out, terminator...)
+	}
+	f.buf = f.buf[:0]
+	return out
+}
@@ -0,0 +1,63 @@
+package vt_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOSCFilter(t *testing.T) {
+	allowWrites := func(payload string) bool { return !strings.HasSuffix(payload, "?") }
+
+	t.Run("Allowed and rejected", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewOSCFilter(&out, 52, allowWrites, 0)
+		f.Write([]byte("a\x1b]52;c;aGk=\ab\x1b]52;c;?\x1b\\c"))
+		assert.Equal(t, "a\x1b]52;c;aGk=\abc", out.String())
+	})
+
+	t.Run("Other sequences pass", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewOSCFilter(&out, 52, func(string) bool { return false }, 0)
+		in := "\x1b]0;title\a\x1b]5;x\x1b\\\x1b[1m\x1b\x1b]520;y\a"
+		f.Write([]byte(in))
+		assert.Equal(t, in, out.String())
+	})
+
+	t.Run("Sequence started inside another", func(t *testing.T) {
+		// An escape ends the title; the clipboard read after it is dropped.
+		for in, want := range map[string]string{
+			"\x1b]0;x\x1b]52;c;?\ay":     "\x1b]0;x\x1b\\y",
+			"\x1b]0;x\x1b\x1b]52;c;?\ay": "\x1b]0;x\x1b\\y",
+			"\x1b]0;x\x1b[1my":           "\x1b]0;x\x1b\\\x1b[1my",
+		} {
+			var out bytes.Buffer
+			f := vt.NewOSCFilter(&out, 52, allowWrites, 0)
+			f.Write([]byte(in))
+			assert.Equal(t, want, out.String(), "%q", in)
+		}
+	})
+
+	t.Run("Split across writes", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewOSCFilter(&out, 52, allowWrites, 0)
+		f.Write([]byte("x\x1b"))
+		f.Write([]byte("]5"))
+		f.Write([]byte("2;c;aG"))
+		assert.Equal(t, "x", out.String())
+		f.Write([]byte("k=\x1b"))
+		f.Write([]byte("\\y"))
+		assert.Equal(t, "x\x1b]52;c;aGk=\x1b\\y", out.String())
+	})
+
+	t.Run("Too long", func(t *testing.T) {
+		var out bytes.Buffer
+		f := vt.NewOSCFilter(&out, 52, allowWrites, 8)
+		f.Write([]byte("\x1b]52;c;" + strings.Repeat("A", 20) + "\aok"))
+		f.Write([]byte("\x1b]52;c;AAAA\a"))
+		assert.Equal(t, "ok\x1b]52;c;AAAA\a", out.String())
+	})
+}
@@ -0,0 +1,369 @@
+package vt
+
+import "unicode/utf8"
+
+// Handler receives the actions decoded by a Parser.
+type Handler interface {
+	Print(r rune)
+	Execute(b byte)                                          // C0/C1 control
+	EscDispatch(intermediates []byte, final byte)            // ESC sequence
+	CsiDispatch(p *Params, intermediates []byte, final byte) // CSI sequence
+	OscDispatch(data []byte)                                 // OSC string without terminator
+	DcsDispatch(p *Params, intermediates []byte, final byte, data []byte)
+}
+
+// Params holds the numeric parameters of a CSI or DCS sequence.
+type Params struct {
+	Private byte  // Leading '?', '>', '<' or '=' (0 if none)
+	Values  []int // -1 marks an omitted parameter
+	// Sub holds colon-separated sub-parameters (e.g. "38:2::255:0:0"),
+	// keyed by the index of the parameter they belong to.
+	Sub map[int][]int
+}
+
+// Get returns parameter i, or def when it is missing or zero.
+func (p *Params) Get(i, def int) int {
+	if i >= len(p.Values) || p.Values[i] <= 0 {
+		return def
+	}
+	return p.Values[i]
+}
+
+// Raw returns parameter i, or def when it is missing. Zero is kept.
+func (p *Params) Raw(i, def int) int {
+	if i >= len(p.Values) || p.Values[i] < 0 {
+		return def
+	}
+	return p.Values[i]
+}
+
+// Len returns the number of parameters.
+func (p *Params) Len() int {
+	return len(p.Values)
+}
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCsiEntry
+	stateCsiParam
+	stateCsiIntermediate
+	stateCsiIgnore
+	stateOscString
+	stateDcsEntry
+	stateDcsParam
+	stateDcsIntermediate
+	stateDcsPassthrough
+	stateDcsIgnore
+	stateSosPmApcString
+)
+
+const (
+	maxParams        = 32
+	maxIntermediates = 2
+	maxStringLength  = 1 << 20 // Upper bound for OSC/DCS payloads
+)
+
+// Parser is a DEC VT500-style escape sequence state machine
+// (see https://vt100.net/emu/dec_ansi_parser) that decodes UTF-8 text.
+type Parser struct {
+	handler Handler
+	state   parserState
+
+	params        Params
+	current       int  // Parameter being accumulated (-1 = omitted)
+	inSub         bool // Accumulating a colon sub-parameter
+	intermediates []byte
+	final         byte
+	data          []byte // OSC/DCS payload
+	escInString   bool   // ESC seen inside a string, waiting for '\'
+
+	utf8Buf []byte
+}
+
+func NewParser(h Handler) *Parser {
+	return &Parser{handler: h}
+}
+
+// Write feeds program output into the parser. It never fails.
+func (p *Parser) Write(b []byte) (int, error) {
+	for i := 0; i < len(b); i++ {
+		p.advance(b[i])
+	}
+	return len(b), nil
+}
+
+func (p *Parser) clear() {
+	p.params = Params{}
+	p.current = -1
+	p.inSub = false
+	p.intermediates = p.intermediates[:0]
+	p.final = 0
+}
+
+func (p *Parser) advance(c byte) {
+	// Bytes of a multi-byte UTF-8 sequence only matter in the ground state.
+	if p.state == stateGround && (len(p.utf8Buf) > 0 || c >= 0x80) {
+		p.advanceUTF8(c)
+		return
+	}
+
+	// String states consume almost everything until ST, BEL or CAN/SUB.
+	switch p.state {
+	case stateOscString, stateDcsPassthrough, stateDcsIgnore, stateSosPmApcString:
+		p.advanceString(c)
+		return
+	}
+
+	// "Anywhere" transitions.
+	switch c {
+	case 0x18, 0x1a: // CAN, SUB
+		p.handler.Execute(c)
+		p.state = stateGround
+		return
+	case 0x1b:
+		p.clear()
+		p.state = stateEscape
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		if c < 0x20 || c == 0x7f {
+			if c != 0x7f {
+				p.handler.Execute(c)
+			}
+			return
+		}
+		p.handler.Print(rune(c))
+
+	case stateEscape:
+		switch {
+		case c < 0x20:
+			p.handler.Execute(c)
+		case c >= 0x20 && c <= 0x2f:
+			p.collect(c)
+			p.state = stateEscapeIntermediate
+		case c == '[':
+			p.clear()
+			p.state = stateCsiEntry
+		case c == ']':
+			p.data = p.data[:0]
+			p.state = stateOscString
+		case c == 'P':
+			p.clear()
+			p.data = p.data[:0]
+			p.state = stateDcsEntry
+		case c == 'X' || c == '^' || c == '_':
+			p.state = stateSosPmApcString
+		case c == 0x7f:
+		default:
+			p.handler.EscDispatch(p.intermediates, c)
+			p.state = stateGround
+		}
+
+	case stateEscapeIntermediate:
+		switch {
+		case c < 0x20:
+			p.handler.Execute(c)
+		case c <= 0x2f:
+			p.collect(c)
+		case c == 0x7f:
+		default:
+			p.handler.EscDispatch(p.intermediates, c)
+			p.state = stateGround
+		}
+
+	case stateCsiEntry, stateCsiParam, stateCsiIntermediate, stateCsiIgnore:
+		p.advanceCsi(c)
+
+	case stateDcsEntry, stateDcsParam, stateDcsIntermediate:
+		p.advanceDcsHeader(c)
+	}
+}
+
+func (p *Parser) advanceCsi(c byte) {
+	switch {
+	case c < 0x20:
+		p.handler.Execute(c)
+		return
+	case c == 0x7f:
+		return
+	}
+
+	if p.state == stateCsiIgnore {
+		if c >= 0x40 && c <= 0x7e {
+			p.state = stateGround
+		}
+		return
+	}
+
+	switch {
+	case c >= '0' && c <= '9' || c == ';' || c == ':':
+		if p.state == stateCsiIntermediate {
+			p.state = stateCsiIgnore
+			return
+		}
+		p.param(c)
+		p.state = stateCsiParam
+	case c >= '<' && c <= '?':
+		if p.state != stateCsiEntry {
+			p.state = stateCsiIgnore
+			return
+		}
+		p.params.Private = c
+		p.state = stateCsiParam
+	case c >= 0x20 && c <= 0x2f:
+		p.collect(c)
+		p.state = stateCsiIntermediate
+	case c >= 0x40 && c <= 0x7e:
+		p.finishParams()
+		p.handler.CsiDispatch(&p.params, p.intermediates, c)
+		p.state = stateGround
+	}
+}
+
+func (p *Parser) advanceDcsHeader(c byte) {
+	switch {
+	case c < 0x20 || c == 0x7f:
+		return
+	case c >= '0' && c <= '9' || c == ';' || c == ':':
+		if p.state == stateDcsIntermediate {
+			p.state = stateDcsIgnore
+			return
+		}
+		p.param(c)
+		p.state = stateDcsParam
+	case c >= '<' && c <= '?':
+		if p.state != stateDcsEntry {
+			p.state = stateDcsIgnore
+			return
+		}
+		p.params.Private = c
+		p.state = stateDcsParam
+	case c >= 0x20 && c <= 0x2f:
+		p.collect(c)
+		p.state = stateDcsIntermediate
+	case c >= 0x40 && c <= 0x7e:
+		p.finishParams()
+		p.final = c
+		p.state = stateDcsPassthrough
+	}
+}
+
+func (p *Parser) advanceString(c byte) {
+	if p.escInString {
+		p.escInString = false
+		if c == '\\' {
+			p.finishString()
+			return
+		}
+		// Any other ESC aborts the string and starts a new sequence.
+		p.finishString()
+		p.clear()
+		p.state = stateEscape
+		p.advance(c)
+		return
+	}
+
+	switch c {
+	case 0x1b:
+		p.escInString = true
+		return
+	case 0x07: // xterm accepts BEL as OSC terminator
+		if p.state == stateOscString {
+			p.finishString()
+			return
+		}
+	case 0x18, 0x1a:
+		p.state = stateGround
+		return
+	}
+
+	if p.state == stateOscString || p.state == stateDcsPassthrough {
+		if c < 0x20 && p.state == stateOscString {
+			return
+		}
+		if len(p.data) < maxStringLength {
+			p.data = append(p.data, c)
+		}
+	}
+}
+
+func (p *Parser) finishString() {
+	switch p.state {
+	case stateOscString:
+		p.handler.OscDispatch(p.data)
+	case stateDcsPassthrough:
+		p.handler.DcsDispatch(&p.params, p.intermediates, p.final, p.data)
+	}
+	p.state = stateGround
+}
+
+func (p *Parser) advanceUTF8(c byte) {
+	p.utf8Buf = append(p.utf8Buf, c)
+	if !utf8.FullRune(p.utf8Buf) {
+		return
+	}
+	r, size := utf8.DecodeRune(p.utf8Buf)
+	rest := append([]byte(nil), p.utf8Buf[size:]...)
+	p.utf8Buf = p.utf8Buf[:0]
+
+	if r == utf8.RuneError && size <= 1 {
+		p.handler.Print(utf8.RuneError)
+	} else {
+		p.handler.Print(r)
+	}
+	// Bytes that did not belong to the sequence are parsed again.
+	for _, b := range rest {
+		p.advance(b)
+	}
+}
+
+func (p *Parser) collect(c byte) {
+	if len(p.intermediates) < maxIntermediates {
+		p.intermediates = append(p.intermediates, c)
+	}
+}
+
+func (p *Parser) param(c byte) {
+	switch c {
+	case ';':
+		p.pushParam()
+		p.inSub = false
+	case ':':
+		p.pushParam()
+		p.inSub = true
+	default:
+		if p.current < 0 {
+			p.current = 0
+		}
+		if p.current < 1<<16 {
+			p.current = p.current*10 + int(c-'0')
+		}
+	}
+}
+
+func (p *Parser) pushParam() {
+	if p.inSub {
+		idx := len(p.params.Values) - 1
+		if idx >= 0 {
+			if p.params.Sub == nil {
+				p.params.Sub = map[int][]int{}
+			}
+			p.params.Sub[idx] = append(p.params.Sub[idx], p.current)
+		}
+	} else if len(p.params.Values) < maxParams {
+		p.params.Values = append(p.params.Values, p.current)
+	}
+	p.current = -1
+}
+
+func (p *Parser) finishParams() {
+	if p.current >= 0 || p.inSub || len(p.params.Values) > 0 {
+		p.pushParam()
+	}
+}
@@ -0,0 +1,98 @@
+package vt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Render repaints a host terminal with the current view: the visible rows
+// (including scrollback when scrolled back), the selection shown in reverse
+// video, the exit status of commands next to their prompt, and the cursor
+// when the live screen is shown.
+func (s *Screen) Render(w io.Writer) error {
+	return s.RenderAt(w, 0, 0, true)
+}
+
+// RenderAt draws the view with its top left corner at row top and column
+// left of the host (counted from 0), for screens shown in part of the host.
+// Without cursor the host cursor is left hidden, in the default rendition.
+func (s *Screen) RenderAt(w io.Writer, top, left int, cursor bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[?25l\x1b[0m")
+
+	first := s.scrollback.Len() - s.viewOffset
+	for y := 0; y < s.rows; y++ {
+		fmt.Fprintf(&buf, "\x1b[%d;%dH", top+y+1, left+1)
+		line := s.historyLine(first + y)
+		current, link := Style{}, 0
+		for x := 0; x < s.cols; x++ {
+			var cell Cell
+			if x < len(line.Cells) {
+				cell = line.Cells[x]
+			}
+			style := cell.Style
+			if s.selection.contains(Point{Row: first + y, Col: x}) != s.visualBell {
+				style.Attrs ^= AttrReverse
+			}
+			if style != current {
+				buf.WriteString("\x1b[" + style.SGR() + "m")
+				current = style
+			}
+			if cell.Link != link {
+				buf.WriteString(s.hyperlinkSequence(cell.Link))
+				link = cell.Link
+			}
+			buf.WriteString(cell.Char())
+		}
+		if link != 0 {
+			buf.WriteString(s.hyperlinkSequence(0))
+		}
+		buf.WriteString(s.statusBadge(line, y, top, left))
+	}
+
+	if !cursor {
+		buf.WriteString("\x1b[0m")
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	// Leave the host with the program's own rendition and cursor.
+	buf.WriteString("\x1b[" + s.cursor.Style.SGR() + "m")
+	if s.cursor.link != 0 {
+		buf.WriteString(s.hyperlinkSequence(s.cursor.link))
+	}
+	fmt.Fprintf(&buf, "\x1b[%d;%dH", top+s.cursor.Y+1, left+s.cursor.X+1)
+	buf.WriteString(CursorStyleSequence(s.cursorShape, s.modes.CursorBlink))
+	if s.cursor.Visible && s.viewOffset == 0 {
+		buf.WriteString("\x1b[?25h")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ModeSequence returns what a host terminal that did not see the program
+// output needs before Render to show the program as it expects: the
+// alternate screen, the cursor key and keypad modes and the title.
+func (s *Screen) ModeSequence() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seq string
+	if s.alt.active {
+		seq += "\x1b[?1049h"
+	}
+	if s.modes.AppCursorKeys {
+		seq += "\x1b[?1h"
+	}
+	if s.modes.AppKeypad {
+		seq += "\x1b="
+	}
+	if s.title != "" {
+		seq += "\x1b]2;" + s.title + "\x1b\\"
+	}
+	return seq
+}
@@ -0,0 +1,177 @@
+package vt
+
+// Resize changes the screen size. Soft-wrapped lines of the screen and the
+// scrollback are joined and wrapped again at the new width, and the cursor
+// stays on the character it was on.
+func (s *Screen) Resize(rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rows < 1 {
+		rows = 1
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	if rows == s.rows && cols == s.cols {
+		return
+	}
+
+	if s.alt.active {
+		s.resizeAlt(rows, cols)
+	} else {
+		s.reflow(rows, cols)
+		s.alt.lines = nil
+	}
+	s.selection = selection{}
+	s.ended = false
+
+	s.rows, s.cols = rows, cols
+	s.top, s.bottom = 0, rows-1
+	s.resizeTabs(cols)
+	s.saved.cursor.X = clamp(s.saved.cursor.X, 0, cols-1)
+	s.saved.cursor.Y = clamp(s.saved.cursor.Y, 0, rows-1)
+	s.alt.saved.cursor.X = clamp(s.alt.saved.cursor.X, 0, cols-1)
+	s.alt.saved.cursor.Y = clamp(s.alt.saved.cursor.Y, 0, rows-1)
+	s.viewOffset = min(s.viewOffset, s.scrollback.Len())
+}
+
+// reflow rewraps the scrollback and screen for a new size.
+func (s *Screen) reflow(rows, cols int) {
+	// Rows below both the cursor and the last written line are not content.
+	used := s.cursor.Y + 1
+	for y := s.rows - 1; y >= used; y-- {
+		if !isBlankLine(s.lines[y]) {
+			used = y + 1
+			break
+		}
+	}
+
+	physical := make([]Line, 0, s.scrollback.Len()+used)
+	physical = append(physical, s.scrollback.Lines(0, s.scrollback.Len())...)
+	cursorRow := len(physical) + s.cursor.Y
+	physical = append(physical, s.lines[:used]...)
+
+	// Join soft-wrapped rows into the lines the program wrote,
+	// remembering where the cursor falls.
+	// Marks move with the text, their columns becoming offsets in the line.
+	var logical [][]Cell
+	var logicalMarks [][]Mark
+	cursorLine, cursorOffset := 0, 0
+	var cur []Cell
+	var curMarks []Mark
+	for i, l := range physical {
+		if i == cursorRow {
+			cursorLine, cursorOffset = len(logical), len(cur)+s.cursor.X
+			if s.cursor.wrapNext {
+				cursorOffset++
+			}
+		}
+		for _, m := range l.Marks {
+			m.Col += len(cur)
+			curMarks = append(curMarks, m)
+		}
+		cur = append(cur, l.Cells...)
+		if !l.Wrapped || i == len(physical)-1 {
+			logical = append(logical, cur)
+			logicalMarks = append(logicalMarks, curMarks)
+			cur, curMarks = nil, nil
+		}
+	}
+
+	// Wrap again at the new width.
+	var wrapped []Line
+	newCursorX, newCursorY, wrapNext := 0, 0, false
+	for i, line := range logical {
+		cells := trimBlankCells(line)
+		if i == cursorLine && len(cells) < cursorOffset {
+			// Keep blank cells up to the cursor so it does not move left.
+			cells = line[:min(cursorOffset, len(line))]
+		}
+		// A wide character that does not fit at the end of a row moves to
+		// the next one.
+		consumed := 0
+		for len(cells) > cols {
+			n := cols
+			if n > 1 && cells[n].Continuation {
+				n--
+			}
+			if i == cursorLine && cursorOffset >= consumed && cursorOffset < consumed+n {
+				newCursorY, newCursorX = len(wrapped), cursorOffset-consumed
+			}
+			row := make([]Cell, cols)
+			copy(row, cells[:n])
+			wrapped = append(wrapped, Line{Cells: row, Wrapped: true, Marks: marksBetween(logicalMarks[i], consumed, consumed+n, cols)})
+			cells = cells[n:]
+			consumed += n
+		}
+		last := Line{Cells: make([]Cell, cols), Marks: marksBetween(logicalMarks[i], consumed, -1, cols)}
+		copy(last.Cells, cells)
+		wrapped = append(wrapped, last)
+
+		if i == cursorLine && cursorOffset >= consumed {
+			newCursorY, newCursorX = len(wrapped)-1, cursorOffset-consumed
+			if newCursorX >= cols {
+				// The cursor sits just past a full row: keep the pending wrap.
+				newCursorX = cols - 1
+				wrapNext = true
+			}
+		}
+	}
+
+	// The screen shows the last rows, but never starts below the cursor.
+	start := clamp(len(wrapped)-rows, 0, newCursorY)
+	s.scrollback.Clear()
+	for _, l := range wrapped[:start] {
+		s.scrollback.Push(l)
+	}
+	end := min(start+rows, len(wrapped))
+	s.lines = append([]Line(nil), wrapped[start:end]...)
+	for len(s.lines) < rows {
+		s.lines = append(s.lines, newLine(cols, Style{}))
+	}
+
+	s.cursor.X = clamp(newCursorX, 0, cols-1)
+	s.cursor.Y = clamp(newCursorY-start, 0, rows-1)
+	s.cursor.wrapNext = wrapNext
+}
+
+func (s *Screen) resizeTabs(cols int) {
+	tabs := make([]bool, cols)
+	copy(tabs, s.tabs)
+	for i := len(s.tabs); i < cols; i++ {
+		tabs[i] = i%8 == 0 && i > 0
+	}
+	s.tabs = tabs
+}
+
+func isBlankLine(l Line) bool {
+	for _, c := range l.Cells {
+		if c.Rune != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// marksBetween returns the marks at offsets [from, to) of a logical line,
+// to being -1 for the rest of it, placed on a row of width cols.
+func marksBetween(marks []Mark, from, to, cols int) []Mark {
+	var row []Mark
+	for _, m := range marks {
+		if m.Col >= from && (to < 0 || m.Col < to) {
+			m.Col = min(m.Col-from, cols-1)
+			row = append(row, m)
+		}
+	}
+	return row
+}
+
+// trimBlankCells drops erased cells from the end of a line.
+func trimBlankCells(cells []Cell) []Cell {
+	end := len(cells)
+	for end > 0 && cells[end-1].Rune == 0 && !cells[end-1].Continuation {
+		end--
+	}
+	return cells[:end]
+}
@@ -0,0 +1,54 @@
+package vt_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResize(t *testing.T) {
+	t.Run("Narrower rewraps", func(t *testing.T) {
+		s := newScreen(3, 10, "abcdefgh\r\nxy")
+		s.Resize(3, 4)
+
+		assert.Equal(t, "abcd\nefgh\nxy", s.String())
+		assert.True(t, s.Line(0).Wrapped)
+		assert.Equal(t, 2, s.Cursor().X)
+		assert.Equal(t, 2, s.Cursor().Y)
+	})
+
+	t.Run("Wider joins wrapped lines", func(t *testing.T) {
+		s := newScreen(3, 4, "abcdefgh")
+		assert.Equal(t, "abcd\nefgh\n", s.String())
+
+		s.Resize(3, 10)
+		assert.Equal(t, "abcdefgh\n\n", s.String())
+		assert.Equal(t, 8, s.Cursor().X)
+		assert.Equal(t, 0, s.Cursor().Y)
+	})
+
+	t.Run("Overflow goes to scrollback", func(t *testing.T) {
+		s := newScreen(2, 6, "123456\r\nab")
+		s.Resize(2, 3)
+
+		assert.Equal(t, []string{"123"}, texts(s.ScrollbackLines(0, s.ScrollbackLen())))
+		assert.Equal(t, "456\nab", s.String())
+	})
+
+	t.Run("Taller pulls lines back", func(t *testing.T) {
+		s := newScreen(2, 5, "1\r\n2\r\n3")
+		assert.Equal(t, 1, s.ScrollbackLen())
+
+		s.Resize(3, 5)
+		assert.Equal(t, 0, s.ScrollbackLen())
+		assert.Equal(t, "1\n2\n3", s.String())
+		assert.Equal(t, 2, s.Cursor().Y)
+	})
+
+	t.Run("Shorter keeps cursor row", func(t *testing.T) {
+		s := newScreen(4, 5, "a\r\nb\x1b[1;1H")
+		s.Resize(2, 5)
+		assert.Equal(t, 0, s.Cursor().Y)
+		assert.Equal(t, "a\nb", s.String())
+	})
+}
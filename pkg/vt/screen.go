@@ -0,0 +1,668 @@
+package vt
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/rivo/uniseg"
+)
+
+// Cursor is the current write position and the rendition used for new text.
+type Cursor struct {
+	X, Y    int
+	Style   Style
+	Visible bool
+
+	// wrapNext is the "pending wrap" flag: the last column was written and
+	// the next printable character goes to the following line.
+	wrapNext bool
+
+	link int // OSC 8 link of the text written next, 0 for none
+}
+
+// savedCursor is the state stored by DECSC and restored by DECRC.
+type savedCursor struct {
+	cursor   Cursor
+	origin   bool
+	charsets [2]charset
+	gl       int
+}
+
+// Modes are the terminal modes set by SM/RM and DECSET/DECRST.
+type Modes struct {
+	AppCursorKeys bool // DECCKM (?1)
+	Origin        bool // DECOM (?6)
+	AutoWrap      bool // DECAWM (?7)
+	CursorBlink   bool // ?12 and DECSCUSR
+	Insert        bool // IRM (4)
+	NewLine       bool // LNM (20)
+	AppKeypad     bool // DECKPAM / DECKPNM
+}
+
+type charset int
+
+// maxCombining is the most bytes of marks a cell keeps; a family emoji
+// sequence fits, and more marks on one character are dropped.
+const maxCombining = 32
+
+const (
+	charsetASCII charset = iota
+	charsetDECSpecial
+)
+
+// Screen is the cell grid of a terminal, updated by writing program output to it.
+// All methods are safe for concurrent use.
+type Screen struct {
+	mu     sync.Mutex
+	parser *Parser
+
+	rows, cols int
+	lines      []Line
+	alt        altScreen
+	scrollback *Scrollback
+	viewOffset int // Lines of scrollback shown above the screen
+	cursor     Cursor
+	saved      savedCursor
+	top        int // Scroll region, inclusive
+	bottom     int
+	tabs       []bool
+	modes      Modes
+	private    map[int]bool // Other DECSET modes, kept for DECRQM and front-ends
+
+	cursorShape    CursorShape
+	cursorOverride bool // A program chose the cursor style with DECSCUSR
+	defaultShape   CursorShape
+	defaultBlink   bool
+
+	mouseTracking MouseTracking
+	mouseEncoding MouseEncoding
+	selection     selection
+
+	charsets [2]charset // G0 and G1
+	gl       int        // Charset invoked with SI/SO
+	lastRune rune       // For REP
+
+	hyperlinks hyperlinks
+
+	title       string
+	bellPending bool // BEL received, see TakeBell
+	ended       bool // A command ended with a status, see TakeEnded
+	endedPrompt Point
+	visualBell  bool
+	reply       io.Writer // Answers to status queries; nil discards them
+	identity    io.Writer // Answers to DA2, DA3 and XTVERSION without reply
+
+	// Default colors: the configured theme and the current values,
+	// which programs may change with OSC 10/11.
+	themeFg, themeBg color.Color
+	fg, bg           color.Color
+}
+
+func NewScreen(rows, cols int) *Screen {
+	if rows < 1 {
+		rows = 1
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	s := &Screen{rows: rows, cols: cols, scrollback: NewScrollback(DefaultScrollback)}
+	s.parser = NewParser(&handler{s})
+	s.reset()
+	return s
+}
+
+// Write feeds program output into the screen.
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parser.Write(p)
+}
+
+// SetReplyWriter sets where answers to queries such as DSR and DA are sent,
+// normally the PTY master. With no writer the queries are ignored, which is
+// what we want when another terminal is already answering them.
+func (s *Screen) SetReplyWriter(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = w
+}
+
+// SetIdentityWriter sets where answers to DA2, DA3 and XTVERSION go while
+// there is no reply writer: the terminal answering the other queries does
+// not see them when the output passes through a QueryFilter.
+func (s *Screen) SetIdentityWriter(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identity = w
+}
+
+// SetDefaultColors sets the configured foreground and background, used to
+// draw cells with the default color and to answer OSC 10/11 queries.
+func (s *Screen) SetDefaultColors(fg, bg color.Color) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.themeFg, s.themeBg = fg, bg
+	s.fg, s.bg = fg, bg
+}
+
+// DefaultColors returns the current default foreground and background.
+func (s *Screen) DefaultColors() (fg, bg color.Color) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fg, s.bg
+}
+
+// Size returns the number of rows and columns.
+func (s *Screen) Size() (rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rows, s.cols
+}
+
+// Cursor returns the cursor state.
+func (s *Screen) Cursor() Cursor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursor
+}
+
+// Cell returns the cell at column x, row y (zero based).
+func (s *Screen) Cell(x, y int) Cell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if y < 0 || y >= s.rows || x < 0 || x >= s.cols {
+		return Cell{}
+	}
+	return s.lines[y].Cells[x]
+}
+
+// Line returns a copy of row y.
+func (s *Screen) Line(y int) Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if y < 0 || y >= s.rows {
+		return Line{}
+	}
+	return s.lines[y].clone()
+}
+
+// Lines returns a copy of all rows.
+func (s *Screen) Lines() []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := make([]Line, len(s.lines))
+	for i, l := range s.lines {
+		lines[i] = l.clone()
+	}
+	return lines
+}
+
+// Modes returns the current terminal modes.
+func (s *Screen) Modes() Modes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modes
+}
+
+// PrivateMode reports whether DECSET mode n is enabled.
+func (s *Screen) PrivateMode(n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.privateMode(n)
+}
+
+// Title returns the window title set through OSC 0/2.
+func (s *Screen) Title() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.title
+}
+
+// String returns the text of the screen, one line per row, without trailing blanks.
+func (s *Screen) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := make([]string, len(s.lines))
+	for i, l := range s.lines {
+		rows[i] = l.Text()
+	}
+	return strings.Join(rows, "\n")
+}
+
+//----------------------------------------------------------------------------//
+// Internal operations. The caller holds s.mu.
+//----------------------------------------------------------------------------//
+
+// reset brings the screen to its power-on state (RIS).
+func (s *Screen) reset() {
+	s.lines = blankLines(s.rows, s.cols)
+	s.alt = altScreen{}
+	s.cursor = Cursor{Visible: true}
+	s.top, s.bottom = 0, s.rows-1
+	s.modes = Modes{AutoWrap: true}
+	s.resetCursorStyle()
+	s.private = map[int]bool{}
+	s.mouseTracking = MouseOff
+	s.mouseEncoding = MouseEncodingDefault
+	s.selection = selection{}
+	s.ended = false
+	s.charsets = [2]charset{}
+	s.gl = 0
+	s.title = ""
+	s.resetTabs()
+	s.saved = savedCursor{cursor: s.cursor}
+}
+
+// softReset implements DECSTR.
+func (s *Screen) softReset() {
+	s.cursor.Visible = true
+	s.cursor.Style = Style{}
+	s.cursor.wrapNext = false
+	s.top, s.bottom = 0, s.rows-1
+	s.modes.Origin = false
+	s.modes.AutoWrap = true
+	s.modes.Insert = false
+	s.modes.AppCursorKeys = false
+	s.modes.AppKeypad = false
+	s.charsets = [2]charset{}
+	s.gl = 0
+	s.saved = savedCursor{cursor: Cursor{Visible: true}}
+}
+
+func (s *Screen) resetTabs() {
+	s.tabs = make([]bool, s.cols)
+	for i := 8; i < s.cols; i += 8 {
+		s.tabs[i] = true
+	}
+}
+
+func (s *Screen) privateMode(n int) bool {
+	switch n {
+	case 1:
+		return s.modes.AppCursorKeys
+	case 6:
+		return s.modes.Origin
+	case 7:
+		return s.modes.AutoWrap
+	case 12:
+		return s.modes.CursorBlink
+	case 25:
+		return s.cursor.Visible
+	case 47, 1047, 1049:
+		return s.alt.active
+	}
+	if set, ok := s.mouseModeSet(n); ok {
+		return set
+	}
+	return s.private[n]
+}
+
+func (s *Screen) blank() Style {
+	return Style{Bg: s.cursor.Style.Bg}
+}
+
+func (s *Screen) print(r rune) {
+	if s.charsets[s.gl] == charsetDECSpecial {
+		r = decSpecial(r)
+	}
+
+	width := RuneWidth(r)
+	graphemes := s.private[ModeGraphemeClusters]
+	if x, ok := s.previousCell(); ok {
+		prev := &s.lines[s.cursor.Y].Cells[x]
+		if graphemes && joinsCluster(prev.Char(), r) || !graphemes && width == 0 {
+			s.combine(x, r)
+			return
+		}
+	}
+	if width == 0 {
+		return // Nothing to combine with
+	}
+	s.lastRune = r
+	width = min(width, s.cols)
+
+	if s.cursor.wrapNext && s.modes.AutoWrap || width == 2 && s.cursor.X == s.cols-1 && s.modes.AutoWrap {
+		s.lines[s.cursor.Y].Wrapped = true
+		s.cursor.X = 0
+		s.index()
+	}
+	s.cursor.wrapNext = false
+	x := min(s.cursor.X, s.cols-width)
+
+	line := &s.lines[s.cursor.Y]
+	if s.modes.Insert {
+		copy(line.Cells[x+width:], line.Cells[x:])
+	}
+	line.clear(x, x+width, Style{})
+	line.Cells[x] = Cell{Rune: r, Style: s.cursor.Style, Link: s.cursor.link, Wide: width == 2}
+	if width == 2 {
+		line.Cells[x+1] = Cell{Style: s.cursor.Style, Link: s.cursor.link, Continuation: true}
+	}
+	if s.modes.Insert {
+		line.fixWide()
+	}
+
+	if x+width >= s.cols {
+		s.cursor.X = s.cols - 1
+		s.cursor.wrapNext = true
+	} else {
+		s.cursor.X = x + width
+	}
+}
+
+// previousCell returns the column of the character just written on the
+// cursor row, which combining characters attach to.
+func (s *Screen) previousCell() (int, bool) {
+	x := s.cursor.X - 1
+	if s.cursor.wrapNext {
+		x = s.cursor.X
+	}
+	if x < 0 {
+		return 0, false
+	}
+	cells := s.lines[s.cursor.Y].Cells
+	if cells[x].Continuation && x > 0 {
+		x--
+	}
+	return x, cells[x].Rune != 0
+}
+
+// combine adds r to the character at column x. In grapheme cluster mode a
+// cluster that becomes wide, such as a symbol followed by VS16, takes a
+// second cell when the cursor is right after it.
+func (s *Screen) combine(x int, r rune) {
+	line := &s.lines[s.cursor.Y]
+	c := &line.Cells[x]
+	if len(c.Combining)+utf8.RuneLen(r) > maxCombining {
+		return
+	}
+	c.Combining += string(r)
+	if !s.private[ModeGraphemeClusters] || c.Wide || uniseg.StringWidth(c.Char()) < 2 {
+		return
+	}
+	if x+1 >= s.cols || s.cursor.wrapNext || s.cursor.X != x+1 {
+		return
+	}
+	line.clear(x+1, x+2, Style{})
+	c.Wide = true
+	line.Cells[x+1] = Cell{Style: c.Style, Link: c.Link, Continuation: true}
+	if x+2 >= s.cols {
+		s.cursor.X = s.cols - 1
+		s.cursor.wrapNext = true
+	} else {
+		s.cursor.X = x + 2
+	}
+}
+
+// index moves the cursor down, scrolling at the bottom margin (IND / LF).
+func (s *Screen) index() {
+	s.cursor.wrapNext = false
+	if s.cursor.Y == s.bottom {
+		s.scrollUp(1)
+	} else if s.cursor.Y < s.rows-1 {
+		s.cursor.Y++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling at the top margin (RI).
+func (s *Screen) reverseIndex() {
+	s.cursor.wrapNext = false
+	if s.cursor.Y == s.top {
+		s.scrollDown(1)
+	} else if s.cursor.Y > 0 {
+		s.cursor.Y--
+	}
+}
+
+// scrollUp moves the lines of the scroll region up by n.
+func (s *Screen) scrollUp(n int) {
+	region := s.bottom - s.top + 1
+	if n > region {
+		n = region
+	}
+	// Only lines leaving the top of the whole primary screen go to the history.
+	if s.top == 0 && !s.alt.active {
+		for i := 0; i < n; i++ {
+			s.pushScrollback(s.lines[i])
+		}
+	}
+	s.shiftUp(s.top, n)
+}
+
+// shiftUp drops the n lines at top, moving the lines below them up to the
+// bottom of the scroll region, which gets blank lines. n is at most the
+// number of lines from top to the bottom.
+func (s *Screen) shiftUp(top, n int) {
+	copy(s.lines[top:], s.lines[top+n:s.bottom+1])
+	for i := s.bottom - n + 1; i <= s.bottom; i++ {
+		s.lines[i] = newLine(s.cols, s.blank())
+	}
+}
+
+// scrollDown moves the lines of the scroll region down by n.
+func (s *Screen) scrollDown(n int) {
+	region := s.bottom - s.top + 1
+	if n > region {
+		n = region
+	}
+	copy(s.lines[s.top+n:s.bottom+1], s.lines[s.top:s.bottom+1-n])
+	for i := s.top; i < s.top+n; i++ {
+		s.lines[i] = newLine(s.cols, s.blank())
+	}
+}
+
+// moveTo places the cursor, honoring origin mode for the row.
+func (s *Screen) moveTo(x, y int) {
+	minY, maxY := 0, s.rows-1
+	if s.modes.Origin {
+		y += s.top
+		minY, maxY = s.top, s.bottom
+	}
+	s.cursor.X = clamp(x, 0, s.cols-1)
+	s.cursor.Y = clamp(y, minY, maxY)
+	s.cursor.wrapNext = false
+}
+
+// moveRel moves the cursor vertically without leaving the scroll region
+// when it starts inside it (CUU/CUD semantics).
+func (s *Screen) moveRel(dx, dy int) {
+	minY, maxY := 0, s.rows-1
+	if s.cursor.Y >= s.top && s.cursor.Y <= s.bottom {
+		minY, maxY = s.top, s.bottom
+	}
+	s.cursor.X = clamp(s.cursor.X+dx, 0, s.cols-1)
+	s.cursor.Y = clamp(s.cursor.Y+dy, minY, maxY)
+	s.cursor.wrapNext = false
+}
+
+func (s *Screen) tab(n int) {
+	for ; n > 0 && s.cursor.X < s.cols-1; n-- {
+		s.cursor.X++
+		for s.cursor.X < s.cols-1 && !s.tabs[s.cursor.X] {
+			s.cursor.X++
+		}
+	}
+}
+
+func (s *Screen) backTab(n int) {
+	for ; n > 0 && s.cursor.X > 0; n-- {
+		s.cursor.X--
+		for s.cursor.X > 0 && !s.tabs[s.cursor.X] {
+			s.cursor.X--
+		}
+	}
+}
+
+// eraseDisplay implements ED.
+func (s *Screen) eraseDisplay(mode int) {
+	y := s.cursor.Y
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for i := y + 1; i < s.rows; i++ {
+			s.lines[i] = newLine(s.cols, s.blank())
+		}
+	case 1:
+		s.eraseLine(1)
+		for i := 0; i < y; i++ {
+			s.lines[i] = newLine(s.cols, s.blank())
+		}
+	case 2:
+		for i := range s.lines {
+			s.lines[i] = newLine(s.cols, s.blank())
+		}
+	case 3:
+		s.clearScrollback()
+	}
+}
+
+// eraseLine implements EL.
+func (s *Screen) eraseLine(mode int) {
+	line := &s.lines[s.cursor.Y]
+	switch mode {
+	case 0:
+		line.clear(s.cursor.X, s.cols, s.blank())
+		line.Wrapped = false
+	case 1:
+		line.clear(0, s.cursor.X+1, s.blank())
+	case 2:
+		line.clear(0, s.cols, s.blank())
+		line.Wrapped = false
+	}
+	s.cursor.wrapNext = false
+}
+
+// insertLines implements IL: blank lines at the cursor, pushing the rest of
+// the scroll region down.
+func (s *Screen) insertLines(n int) {
+	if s.cursor.Y < s.top || s.cursor.Y > s.bottom {
+		return
+	}
+	top := s.top
+	s.top = s.cursor.Y
+	s.scrollDown(n)
+	s.top = top
+	s.cursor.X = 0
+	s.cursor.wrapNext = false
+}
+
+// deleteLines implements DL.
+func (s *Screen) deleteLines(n int) {
+	if s.cursor.Y < s.top || s.cursor.Y > s.bottom {
+		return
+	}
+	// Deleted lines do not go to the history, even at the top of the screen.
+	s.shiftUp(s.cursor.Y, min(n, s.bottom-s.cursor.Y+1))
+	s.cursor.X = 0
+	s.cursor.wrapNext = false
+}
+
+// insertChars implements ICH.
+func (s *Screen) insertChars(n int) {
+	cells := s.lines[s.cursor.Y].Cells
+	x := s.cursor.X
+	n = min(n, s.cols-x)
+	copy(cells[x+n:], cells[x:])
+	s.lines[s.cursor.Y].clear(x, x+n, s.blank())
+	s.lines[s.cursor.Y].fixWide()
+	s.cursor.wrapNext = false
+}
+
+// deleteChars implements DCH.
+func (s *Screen) deleteChars(n int) {
+	cells := s.lines[s.cursor.Y].Cells
+	x := s.cursor.X
+	n = min(n, s.cols-x)
+	copy(cells[x:], cells[x+n:])
+	s.lines[s.cursor.Y].clear(s.cols-n, s.cols, s.blank())
+	s.lines[s.cursor.Y].fixWide()
+	s.cursor.wrapNext = false
+}
+
+// eraseChars implements ECH.
+func (s *Screen) eraseChars(n int) {
+	s.lines[s.cursor.Y].clear(s.cursor.X, s.cursor.X+n, s.blank())
+	s.cursor.wrapNext = false
+}
+
+func (s *Screen) setScrollRegion(top, bottom int) {
+	if bottom <= 0 || bottom > s.rows {
+		bottom = s.rows
+	}
+	if top < 1 {
+		top = 1
+	}
+	if top >= bottom {
+		return
+	}
+	s.top, s.bottom = top-1, bottom-1
+	s.moveTo(0, 0)
+}
+
+func (s *Screen) saveCursor() {
+	s.saved = savedCursor{
+		cursor:   s.cursor,
+		origin:   s.modes.Origin,
+		charsets: s.charsets,
+		gl:       s.gl,
+	}
+}
+
+func (s *Screen) restoreCursor() {
+	visible := s.cursor.Visible
+	s.cursor = s.saved.cursor
+	s.cursor.Visible = visible
+	s.cursor.X = clamp(s.cursor.X, 0, s.cols-1)
+	s.cursor.Y = clamp(s.cursor.Y, 0, s.rows-1)
+	s.modes.Origin = s.saved.origin
+	s.charsets = s.saved.charsets
+	s.gl = s.saved.gl
+}
+
+// alignmentTest fills the screen with 'E' (DECALN).
+func (s *Screen) alignmentTest() {
+	for i := range s.lines {
+		for x := range s.lines[i].Cells {
+			s.lines[i].Cells[x] = Cell{Rune: 'E'}
+		}
+		s.lines[i].Wrapped = false
+	}
+	s.top, s.bottom = 0, s.rows-1
+	s.moveTo(0, 0)
+}
+
+func (s *Screen) respond(msg string) {
+	if s.reply != nil {
+		io.WriteString(s.reply, msg)
+	}
+}
+
+// respondIdentity answers a query QueryFilter removes.
+func (s *Screen) respondIdentity(msg string) {
+	if s.reply != nil {
+		io.WriteString(s.reply, msg)
+	} else if s.identity != nil {
+		io.WriteString(s.identity, msg)
+	}
+}
+
+// decSpecial maps ASCII to the DEC Special Graphics (line drawing) set.
+func decSpecial(r rune) rune {
+	if r < 0x5f || r > 0x7e {
+		return r
+	}
+	return []rune(" ◆▒␉␌␍␊°±␤␋┘┐┌└┼⎺⎻─⎼⎽├┤┴┬│≤≥π≠£·")[r-0x5f]
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
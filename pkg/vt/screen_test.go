@@ -0,0 +1,171 @@
+package vt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func newScreen(rows, cols int, input string) *vt.Screen {
+	s := vt.NewScreen(rows, cols)
+	s.Write([]byte(input))
+	return s
+}
+
+func TestScreenText(t *testing.T) {
+	t.Run("Print and newline", func(t *testing.T) {
+		s := newScreen(3, 10, "hello\r\nworld")
+		assert.Equal(t, "hello\nworld\n", s.String())
+		assert.Equal(t, 5, s.Cursor().X)
+		assert.Equal(t, 1, s.Cursor().Y)
+	})
+
+	t.Run("Autowrap", func(t *testing.T) {
+		s := newScreen(3, 5, "abcdefg")
+		assert.Equal(t, "abcde\nfg\n", s.String())
+		assert.True(t, s.Line(0).Wrapped)
+	})
+
+	t.Run("Scroll", func(t *testing.T) {
+		s := newScreen(2, 5, "1\r\n2\r\n3")
+		assert.Equal(t, "2\n3", s.String())
+	})
+
+	t.Run("UTF-8 split across writes", func(t *testing.T) {
+		s := vt.NewScreen(1, 5)
+		b := []byte("é")
+		s.Write(b[:1])
+		s.Write(b[1:])
+		assert.Equal(t, 'é', s.Cell(0, 0).Rune)
+	})
+
+	t.Run("DEC special graphics", func(t *testing.T) {
+		s := newScreen(1, 5, "\x1b(0qx\x1b(Bq")
+		assert.Equal(t, "─│q", s.String())
+	})
+}
+
+func TestScreenCSI(t *testing.T) {
+	t.Run("Cursor position", func(t *testing.T) {
+		s := newScreen(5, 10, "\x1b[3;4HX")
+		assert.Equal(t, 'X', s.Cell(3, 2).Rune)
+	})
+
+	t.Run("Erase in line", func(t *testing.T) {
+		s := newScreen(1, 10, "abcdef\x1b[3G\x1b[K")
+		assert.Equal(t, "ab", s.String())
+	})
+
+	t.Run("Erase display", func(t *testing.T) {
+		s := newScreen(2, 5, "ab\r\ncd\x1b[2J")
+		assert.Equal(t, "\n", s.String())
+	})
+
+	t.Run("Insert and delete chars", func(t *testing.T) {
+		s := newScreen(1, 10, "abcd\x1b[2G\x1b[2@")
+		assert.Equal(t, "a  bcd", s.String())
+		s.Write([]byte("\x1b[3P"))
+		assert.Equal(t, "acd", s.String())
+	})
+
+	t.Run("Scroll region", func(t *testing.T) {
+		s := newScreen(4, 5, "a\r\nb\r\nc\r\nd\x1b[2;3r\x1b[3;1H\n")
+		assert.Equal(t, "a\nc\n\nd", s.String())
+	})
+
+	t.Run("Insert lines", func(t *testing.T) {
+		s := newScreen(3, 5, "a\r\nb\r\nc\x1b[2;1H\x1b[L")
+		assert.Equal(t, "a\n\nb", s.String())
+	})
+
+	t.Run("Delete lines", func(t *testing.T) {
+		s := newScreen(3, 5, "a\r\nb\r\nc\x1b[H\x1b[M")
+		assert.Equal(t, "b\nc\n", s.String())
+		// Deleted lines are not history.
+		assert.Zero(t, s.ScrollbackLen())
+
+		s = newScreen(3, 5, "a\r\nb\r\nc\x1b[2;1H\x1b[5M")
+		assert.Equal(t, "a\n\n", s.String())
+	})
+
+	t.Run("Tab stops", func(t *testing.T) {
+		s := newScreen(1, 20, "\tX\x1b[3g\x1b[1G\x1b[5G\x1bH\x1b[1G\tY")
+		assert.Equal(t, 'X', s.Cell(8, 0).Rune)
+		assert.Equal(t, 'Y', s.Cell(4, 0).Rune)
+	})
+
+	t.Run("Repeat", func(t *testing.T) {
+		s := newScreen(1, 10, "-\x1b[4b")
+		assert.Equal(t, "-----", s.String())
+
+		// A huge count fills the screen once.
+		s = newScreen(2, 3, "-\x1b[65535b")
+		assert.Equal(t, "---\n-", s.String())
+		assert.Equal(t, 1, s.ScrollbackLen())
+	})
+}
+
+func TestScreenSGR(t *testing.T) {
+	s := newScreen(1, 10, "\x1b[1;4;31;44ma\x1b[38;5;200;48;2;1;2;3mb\x1b[38:2::10:20:30mc\x1b[0md")
+
+	a := s.Cell(0, 0)
+	assert.True(t, a.Has(vt.AttrBold|vt.AttrUnderline))
+	assert.Equal(t, color.Red, a.Fg)
+	assert.Equal(t, color.Blue, a.Bg)
+
+	b := s.Cell(1, 0)
+	assert.Equal(t, color.Indexed(200), b.Fg)
+	assert.Equal(t, color.RGB(1, 2, 3), b.Bg)
+
+	assert.Equal(t, color.RGB(10, 20, 30), s.Cell(2, 0).Fg)
+	assert.Equal(t, vt.Style{}, s.Cell(3, 0).Style)
+}
+
+func TestScreenReplies(t *testing.T) {
+	var reply bytes.Buffer
+	s := vt.NewScreen(5, 10)
+	s.SetReplyWriter(&reply)
+
+	s.Write([]byte("\x1b[2;3H\x1b[6n"))
+	assert.Equal(t, "\x1b[2;3R", reply.String())
+
+	reply.Reset()
+	s.Write([]byte("\x1b[?25$p"))
+	assert.Equal(t, "\x1b[?25;1$y", reply.String())
+
+	reply.Reset()
+	s.Write([]byte("\x1b[1m\x1bP$qm\x1b\\"))
+	assert.Equal(t, "\x1bP1$r0;1m\x1b\\", reply.String())
+}
+
+func TestScreenRenderAt(t *testing.T) {
+	s := vt.NewScreen(2, 3)
+	s.Write([]byte("ab\r\nc"))
+
+	var out bytes.Buffer
+	s.RenderAt(&out, 4, 10, false)
+	assert.Equal(t, "\x1b[?25l\x1b[0m\x1b[5;11Hab \x1b[6;11Hc  \x1b[0m", out.String())
+
+	out.Reset()
+	s.RenderAt(&out, 4, 10, true)
+	assert.Contains(t, out.String(), "\x1b[6;12H")
+	assert.Regexp(t, `\x1b\[\?25h$`, out.String())
+}
+
+func TestScreenModesAndOSC(t *testing.T) {
+	s := newScreen(2, 10, "\x1b[?25l\x1b[?1h\x1b[?2004h\x1b]2;my title\x07")
+	assert.False(t, s.Cursor().Visible)
+	assert.True(t, s.Modes().AppCursorKeys)
+	assert.True(t, s.PrivateMode(2004))
+	assert.Equal(t, "my title", s.Title())
+	// Modes the screen does not know are not kept.
+	s.Write([]byte("\x1b[?12345h"))
+	assert.False(t, s.PrivateMode(12345))
+
+	s.Write([]byte("\x1b]0;other\x1b\\x"))
+	assert.Equal(t, "other", s.Title())
+	assert.Equal(t, "x", s.String()[:1])
+}
@@ -0,0 +1,241 @@
+package vt
+
+// DefaultScrollback is the number of lines kept when no limit is configured.
+const DefaultScrollback = 1000
+
+// Scrollback is a ring buffer of the lines that scrolled off the top of the
+// screen. It holds at most Limit lines; older ones are dropped.
+type Scrollback struct {
+	lines []Line
+	start int // Index of the oldest line
+	count int
+	limit int
+}
+
+func NewScrollback(limit int) *Scrollback {
+	if limit < 0 {
+		limit = 0
+	}
+	return &Scrollback{limit: limit}
+}
+
+// Push appends a line, evicting the oldest one when the buffer is full.
+func (b *Scrollback) Push(l Line) {
+	if b.limit == 0 {
+		return
+	}
+	if b.count < b.limit {
+		// Grow lazily so a large limit costs nothing until it is used.
+		switch i := b.start + b.count; {
+		case i < len(b.lines):
+			b.lines[i] = l
+		case len(b.lines) < b.limit:
+			b.lines = append(b.lines, l)
+		default:
+			b.lines[i%b.limit] = l
+		}
+		b.count++
+		return
+	}
+	b.lines[b.start] = l
+	b.start = (b.start + 1) % b.limit
+}
+
+// Pop removes and returns the newest line.
+func (b *Scrollback) Pop() (Line, bool) {
+	if b.count == 0 {
+		return Line{}, false
+	}
+	i := (b.start + b.count - 1) % len(b.lines)
+	l := b.lines[i]
+	b.lines[i] = Line{}
+	b.count--
+	return l, true
+}
+
+// Len returns the number of stored lines.
+func (b *Scrollback) Len() int {
+	return b.count
+}
+
+// Limit returns the maximum number of lines.
+func (b *Scrollback) Limit() int {
+	return b.limit
+}
+
+// Line returns line i, 0 being the oldest.
+func (b *Scrollback) Line(i int) Line {
+	if i < 0 || i >= b.count {
+		return Line{}
+	}
+	return b.lines[(b.start+i)%len(b.lines)]
+}
+
+// Lines returns copies of lines [from, to), clamped to the stored range.
+func (b *Scrollback) Lines(from, to int) []Line {
+	from = clamp(from, 0, b.count)
+	to = clamp(to, from, b.count)
+	out := make([]Line, 0, to-from)
+	for i := from; i < to; i++ {
+		out = append(out, b.Line(i).clone())
+	}
+	return out
+}
+
+// SetLimit changes the capacity, keeping the newest lines.
+func (b *Scrollback) SetLimit(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	if limit == b.limit {
+		return
+	}
+	keep := min(b.count, limit)
+	lines := make([]Line, 0, keep)
+	for i := b.count - keep; i < b.count; i++ {
+		lines = append(lines, b.Line(i))
+	}
+	b.lines = lines
+	b.start = 0
+	b.count = keep
+	b.limit = limit
+}
+
+// Clear drops all lines.
+func (b *Scrollback) Clear() {
+	b.lines = nil
+	b.start = 0
+	b.count = 0
+}
+
+// SetScrollbackLimit changes how many lines of history the screen keeps.
+func (s *Screen) SetScrollbackLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scrollback.SetLimit(limit)
+	s.viewOffset = min(s.viewOffset, s.scrollback.Len())
+}
+
+// ScrollbackLen returns the number of lines in the scrollback.
+func (s *Screen) ScrollbackLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scrollback.Len()
+}
+
+// ScrollbackLines returns copies of scrollback lines [from, to), 0 being the oldest.
+func (s *Screen) ScrollbackLines(from, to int) []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scrollback.Lines(from, to)
+}
+
+// ClearScrollback drops the history (as ED 3 does).
+func (s *Screen) ClearScrollback() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clearScrollback()
+}
+
+// ViewOffset returns how many lines the view is scrolled back (0 = live screen).
+func (s *Screen) ViewOffset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.viewOffset
+}
+
+// ScrollViewUp moves the view n lines back into the history.
+func (s *Screen) ScrollViewUp(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.viewOffset = clamp(s.viewOffset+n, 0, s.scrollback.Len())
+}
+
+// ScrollViewDown moves the view n lines towards the live screen.
+func (s *Screen) ScrollViewDown(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.viewOffset = clamp(s.viewOffset-n, 0, s.scrollback.Len())
+}
+
+// PageUp scrolls the view back by one screen.
+func (s *Screen) PageUp() {
+	s.ScrollViewUp(s.pageSize())
+}
+
+// PageDown scrolls the view forward by one screen.
+func (s *Screen) PageDown() {
+	s.ScrollViewDown(s.pageSize())
+}
+
+// ScrollViewToTop shows the oldest lines of the history.
+func (s *Screen) ScrollViewToTop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.viewOffset = s.scrollback.Len()
+}
+
+// ScrollViewToBottom returns the view to the live screen.
+func (s *Screen) ScrollViewToBottom() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.viewOffset = 0
+}
+
+// View returns the rows currently in view: the end of the scrollback
+// followed by the top of the screen when scrolled back.
+func (s *Screen) View() []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	off := s.viewOffset
+	total := s.scrollback.Len()
+	view := s.scrollback.Lines(total-off, total-off+s.rows)
+	for i := 0; len(view) < s.rows; i++ {
+		view = append(view, s.lines[i].clone())
+	}
+	return view
+}
+
+func (s *Screen) pageSize() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return max(s.rows-1, 1)
+}
+
+// pushScrollback saves a line that scrolled off the top. A view that is
+// scrolled back keeps showing the same lines.
+func (s *Screen) pushScrollback(l Line) {
+	full := s.scrollback.Len() == s.scrollback.Limit()
+	s.scrollback.Push(l)
+	if s.viewOffset > 0 {
+		s.viewOffset++
+	}
+	if full {
+		s.shiftSelection(1)
+		s.endedPrompt.Row--
+	}
+	s.viewOffset = min(s.viewOffset, s.scrollback.Len())
+}
+
+func (s *Screen) clearScrollback() {
+	s.scrollback.Clear()
+	s.viewOffset = 0
+	s.selection = selection{}
+	s.ended = false
+}
+
+// History returns copies of the scrollback lines followed by the screen
+// rows, without the blank rows at the bottom of the screen.
+func (s *Screen) History() []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used := s.rows
+	for used > 0 && isBlankLine(s.lines[used-1]) {
+		used--
+	}
+	lines := s.scrollback.Lines(0, s.scrollback.Len())
+	for _, l := range s.lines[:used] {
+		lines = append(lines, l.clone())
+	}
+	return lines
+}
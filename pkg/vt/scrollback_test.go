@@ -0,0 +1,100 @@
+package vt_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func texts(lines []vt.Line) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = l.Text()
+	}
+	return out
+}
+
+func TestScrollback(t *testing.T) {
+	t.Run("Ring buffer", func(t *testing.T) {
+		b := vt.NewScrollback(3)
+		for i := 0; i < 5; i++ {
+			b.Push(vt.Line{Cells: []vt.Cell{{Rune: rune('a' + i)}}})
+		}
+		assert.Equal(t, 3, b.Len())
+		assert.Equal(t, []string{"c", "d", "e"}, texts(b.Lines(0, 10)))
+
+		b.SetLimit(2)
+		assert.Equal(t, []string{"d", "e"}, texts(b.Lines(0, 2)))
+
+		l, ok := b.Pop()
+		assert.True(t, ok)
+		assert.Equal(t, "e", l.Text())
+		b.Push(vt.Line{Cells: []vt.Cell{{Rune: 'f'}}})
+		assert.Equal(t, []string{"d", "f"}, texts(b.Lines(0, 2)))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		b := vt.NewScrollback(0)
+		b.Push(vt.Line{})
+		assert.Equal(t, 0, b.Len())
+	})
+}
+
+func TestScreenScrollback(t *testing.T) {
+	var out strings.Builder
+	for i := 1; i <= 6; i++ {
+		fmt.Fprintf(&out, "%d\r\n", i)
+	}
+	s := vt.NewScreen(3, 10)
+	s.SetScrollbackLimit(2)
+	s.Write([]byte(out.String()))
+
+	assert.Equal(t, []string{"3", "4"}, texts(s.ScrollbackLines(0, s.ScrollbackLen())))
+	assert.Equal(t, "5\n6\n", s.String())
+	assert.Equal(t, []string{"3", "4", "5", "6"}, texts(s.History()))
+
+	s.ScrollViewUp(1)
+	assert.Equal(t, []string{"4", "5", "6"}, texts(s.View()))
+
+	s.ScrollViewToTop()
+	assert.Equal(t, []string{"3", "4", "5"}, texts(s.View()))
+
+	s.PageDown()
+	assert.Equal(t, 0, s.ViewOffset())
+
+	s.Write([]byte("\x1b[3J"))
+	assert.Equal(t, 0, s.ScrollbackLen())
+}
+
+func TestScrolledBackView(t *testing.T) {
+	s := vt.NewScreen(3, 10)
+	s.SetScrollbackLimit(4)
+	for i := 1; i <= 8; i++ {
+		fmt.Fprintf(s, "%d\r\n", i)
+	}
+	s.ScrollViewUp(2)
+	assert.Equal(t, []string{"5", "6", "7"}, texts(s.View()))
+
+	// New output fills the full scrollback; the view stays on its lines
+	// until they are dropped.
+	s.Write([]byte("9\r\n"))
+	assert.Equal(t, []string{"5", "6", "7"}, texts(s.View()))
+	s.Write([]byte("10\r\n11\r\n12\r\n"))
+	assert.Equal(t, 4, s.ViewOffset())
+	assert.Equal(t, []string{"7", "8", "9"}, texts(s.View()))
+}
+
+func TestSelectedLines(t *testing.T) {
+	s := vt.NewScreen(3, 10)
+	s.Write([]byte("one two\r\n\x1b[1mthree\x1b[0m four"))
+	assert.Nil(t, s.SelectedLines())
+
+	s.StartSelection(4, 0)
+	s.ExtendSelection(4, 1)
+	lines := s.SelectedLines()
+	assert.Equal(t, []string{"two", "three"}, texts(lines))
+	assert.True(t, lines[1].Cells[0].Style.Has(vt.AttrBold))
+}
@@ -0,0 +1,167 @@
+package vt
+
+import "strings"
+
+// Point is a cell in the history: Row 0 is the oldest scrollback line and
+// the screen rows follow the scrollback.
+type Point struct {
+	Row, Col int
+}
+
+func (p Point) before(q Point) bool {
+	return p.Row < q.Row || p.Row == q.Row && p.Col < q.Col
+}
+
+// selection is the text selected with the mouse, from anchor to head.
+type selection struct {
+	active       bool
+	anchor, head Point
+}
+
+// ordered returns the selection ends in reading order.
+func (sel selection) ordered() (Point, Point) {
+	if sel.head.before(sel.anchor) {
+		return sel.head, sel.anchor
+	}
+	return sel.anchor, sel.head
+}
+
+func (sel selection) contains(p Point) bool {
+	if !sel.active {
+		return false
+	}
+	start, end := sel.ordered()
+	return !p.before(start) && !end.before(p)
+}
+
+// StartSelection begins a selection at a cell of the current view.
+func (s *Screen) StartSelection(x, y int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.viewPoint(x, y)
+	s.selection = selection{active: true, anchor: p, head: p}
+}
+
+// ExtendSelection moves the free end of the selection to a cell of the view.
+func (s *Screen) ExtendSelection(x, y int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.selection.active {
+		s.selection.head = s.viewPoint(x, y)
+	}
+}
+
+// ClearSelection removes the selection.
+func (s *Screen) ClearSelection() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.selection = selection{}
+}
+
+// Selection returns the selected range in reading order.
+func (s *Screen) Selection() (start, end Point, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start, end = s.selection.ordered()
+	return start, end, s.selection.active
+}
+
+// SelectedText returns the selected characters. Soft-wrapped rows are
+// joined and trailing blanks are dropped at the end of each line.
+func (s *Screen) SelectedText() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.selection.active {
+		return ""
+	}
+	start, end := s.selection.ordered()
+	return s.textBetween(start, end)
+}
+
+// textBetween returns the text from start to end, both included.
+func (s *Screen) textBetween(start, end Point) string {
+	var b strings.Builder
+	for row := start.Row; row <= end.Row; row++ {
+		line := s.historyLine(row)
+		from, to := 0, len(line.Cells)-1
+		if row == start.Row {
+			from = start.Col
+		}
+		if row == end.Row {
+			to = min(end.Col, to)
+		}
+		var text strings.Builder
+		for x := from; x <= to && x < len(line.Cells); x++ {
+			text.WriteString(line.Cells[x].Char())
+		}
+		if line.Wrapped && row != end.Row {
+			b.WriteString(text.String())
+			continue
+		}
+		b.WriteString(strings.TrimRight(text.String(), " "))
+		if row != end.Row {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// viewPoint converts a cell of the view to a history position.
+func (s *Screen) viewPoint(x, y int) Point {
+	return Point{
+		Row: s.scrollback.Len() - s.viewOffset + clamp(y, 0, s.rows-1),
+		Col: clamp(x, 0, s.cols-1),
+	}
+}
+
+// historyLine returns a scrollback line or a screen row by history row.
+func (s *Screen) historyLine(row int) Line {
+	if row < s.scrollback.Len() {
+		return s.scrollback.Line(row)
+	}
+	row -= s.scrollback.Len()
+	if row < 0 || row >= len(s.lines) {
+		return Line{}
+	}
+	return s.lines[row]
+}
+
+// shiftSelection keeps the selection on the same text when the oldest
+// scrollback line is dropped.
+func (s *Screen) shiftSelection(rows int) {
+	if !s.selection.active {
+		return
+	}
+	s.selection.anchor.Row -= rows
+	s.selection.head.Row -= rows
+	if s.selection.head.Row < 0 && s.selection.anchor.Row < 0 {
+		s.selection = selection{}
+	}
+}
+
+// SelectedLines returns the selected part of each selected row, keeping
+// the cells so the text can be exported with its style.
+func (s *Screen) SelectedLines() []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.selection.active {
+		return nil
+	}
+	start, end := s.selection.ordered()
+	var lines []Line
+	for row := start.Row; row <= end.Row; row++ {
+		line := s.historyLine(row)
+		from, to := 0, len(line.Cells)
+		if row == start.Row {
+			from = min(start.Col, to)
+		}
+		if row == end.Row {
+			to = min(end.Col+1, to)
+		}
+		lines = append(lines, Line{
+			Cells:   append([]Cell(nil), line.Cells[from:to]...),
+			Wrapped: line.Wrapped && row != end.Row,
+		})
+	}
+	return lines
+}
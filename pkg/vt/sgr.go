@@ -0,0 +1,175 @@
+package vt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/FelipePn10/kariuki/pkg/color"
+)
+
+// applySGR updates style from the parameters of an SGR (CSI ... m) sequence.
+func applySGR(p *Params, style *Style) {
+	if p.Len() == 0 {
+		*style = Style{}
+		return
+	}
+
+	for i := 0; i < p.Len(); i++ {
+		n := p.Raw(i, 0)
+		switch {
+		case n == 0:
+			*style = Style{}
+		case n == 1:
+			style.Attrs |= AttrBold
+		case n == 2:
+			style.Attrs |= AttrFaint
+		case n == 3:
+			style.Attrs |= AttrItalic
+		case n == 4:
+			// 4:0 turns underline off, 4:1..4:5 select a style we draw as single.
+			if sub := p.Sub[i]; len(sub) > 0 && sub[0] == 0 {
+				style.Attrs &^= AttrUnderline
+			} else {
+				style.Attrs |= AttrUnderline
+			}
+		case n == 5 || n == 6:
+			style.Attrs |= AttrBlink
+		case n == 7:
+			style.Attrs |= AttrReverse
+		case n == 8:
+			style.Attrs |= AttrInvisible
+		case n == 9:
+			style.Attrs |= AttrStrikethrough
+		case n == 21:
+			style.Attrs |= AttrUnderline
+		case n == 22:
+			style.Attrs &^= AttrBold | AttrFaint
+		case n == 23:
+			style.Attrs &^= AttrItalic
+		case n == 24:
+			style.Attrs &^= AttrUnderline
+		case n == 25:
+			style.Attrs &^= AttrBlink
+		case n == 27:
+			style.Attrs &^= AttrReverse
+		case n == 28:
+			style.Attrs &^= AttrInvisible
+		case n == 29:
+			style.Attrs &^= AttrStrikethrough
+		case n >= 30 && n <= 37:
+			style.Fg = color.Indexed(uint8(n - 30))
+		case n == 38:
+			c, skip := extendedColor(p, i)
+			if c != nil {
+				style.Fg = *c
+			}
+			i += skip
+		case n == 39:
+			style.Fg = color.Default
+		case n >= 40 && n <= 47:
+			style.Bg = color.Indexed(uint8(n - 40))
+		case n == 48:
+			c, skip := extendedColor(p, i)
+			if c != nil {
+				style.Bg = *c
+			}
+			i += skip
+		case n == 49:
+			style.Bg = color.Default
+		case n == 58:
+			// Underline color: parsed so its arguments are skipped, not drawn.
+			_, skip := extendedColor(p, i)
+			i += skip
+		case n >= 90 && n <= 97:
+			style.Fg = color.Indexed(uint8(n - 90 + 8))
+		case n >= 100 && n <= 107:
+			style.Bg = color.Indexed(uint8(n - 100 + 8))
+		}
+	}
+}
+
+// extendedColor decodes the color selected by SGR 38/48/58 at parameter i,
+// in either the "38;5;n" / "38;2;r;g;b" form or the colon form
+// "38:5:n" / "38:2:[colorspace]:r:g:b". It returns the number of following
+// semicolon parameters that were consumed.
+func extendedColor(p *Params, i int) (*color.Color, int) {
+	if sub := p.Sub[i]; len(sub) > 0 {
+		switch sub[0] {
+		case 5:
+			if len(sub) >= 2 {
+				c := color.Indexed(uint8(clamp(sub[1], 0, 255)))
+				return &c, 0
+			}
+		case 2:
+			rgb := sub[1:]
+			if len(rgb) >= 4 { // Colorspace id present
+				rgb = rgb[1:]
+			}
+			if len(rgb) >= 3 {
+				c := color.RGB(byteParam(rgb[0]), byteParam(rgb[1]), byteParam(rgb[2]))
+				return &c, 0
+			}
+		}
+		return nil, 0
+	}
+
+	switch p.Raw(i+1, -1) {
+	case 5:
+		if i+2 < p.Len() {
+			c := color.Indexed(uint8(clamp(p.Raw(i+2, 0), 0, 255)))
+			return &c, 2
+		}
+		return nil, p.Len() - i - 1
+	case 2:
+		if i+4 < p.Len() {
+			c := color.RGB(byteParam(p.Raw(i+2, 0)), byteParam(p.Raw(i+3, 0)), byteParam(p.Raw(i+4, 0)))
+			return &c, 4
+		}
+		return nil, p.Len() - i - 1
+	}
+	return nil, 0
+}
+
+func byteParam(v int) uint8 {
+	return uint8(clamp(v, 0, 255))
+}
+
+// SGR returns the parameters of an SGR sequence that selects the style,
+// e.g. "0;1;38;2;255;0;0". The leading 0 resets previous attributes.
+func (s Style) SGR() string {
+	params := []string{"0"}
+	flags := []struct {
+		attr Attr
+		code string
+	}{
+		{AttrBold, "1"}, {AttrFaint, "2"}, {AttrItalic, "3"}, {AttrUnderline, "4"},
+		{AttrBlink, "5"}, {AttrReverse, "7"}, {AttrInvisible, "8"}, {AttrStrikethrough, "9"},
+	}
+	for _, f := range flags {
+		if s.Has(f.attr) {
+			params = append(params, f.code)
+		}
+	}
+	if c := colorSGR(s.Fg, 30, 90, 38); c != "" {
+		params = append(params, c)
+	}
+	if c := colorSGR(s.Bg, 40, 100, 48); c != "" {
+		params = append(params, c)
+	}
+	return strings.Join(params, ";")
+}
+
+func colorSGR(c color.Color, base, brightBase, extended int) string {
+	switch {
+	case c.IsIndexed() && c.Index() < 8:
+		return strconv.Itoa(base + int(c.Index()))
+	case c.IsIndexed() && c.Index() < 16:
+		return strconv.Itoa(brightBase + int(c.Index()) - 8)
+	case c.IsIndexed():
+		return strconv.Itoa(extended) + ";5;" + strconv.Itoa(int(c.Index()))
+	case c.IsRGB():
+		r, g, b := c.Components()
+		return strconv.Itoa(extended) + ";2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package vt
+
+import "github.com/rivo/uniseg"
+
+// ModeGraphemeClusters is the DECSET mode (2027) in which a whole grapheme
+// cluster, such as an emoji ZWJ sequence, takes one or two cells. Without
+// it widths are counted per code point like wcwidth(3), which is what
+// shells and most programs assume when they place the cursor.
+const ModeGraphemeClusters = 2027
+
+// RuneWidth returns the number of cells r takes: 0 for combining marks,
+// joiners and variation selectors, 2 for East Asian wide and fullwidth
+// characters and emoji, 1 otherwise. Ambiguous width characters are narrow.
+func RuneWidth(r rune) int {
+	if r < 0x20 || r == 0x7f {
+		return 0
+	}
+	if r < 0x7f {
+		return 1
+	}
+	return uniseg.StringWidth(string(r))
+}
+
+// StringWidth returns the number of cells s takes when printed, counted
+// the same way as the screen does with mode 2027 off.
+func StringWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += RuneWidth(r)
+	}
+	return n
+}
+
+// joinsCluster reports whether r continues the grapheme cluster prev.
+func joinsCluster(prev string, r rune) bool {
+	if prev == "" {
+		return false
+	}
+	s := prev + string(r)
+	cluster, _, _, _ := uniseg.FirstGraphemeClusterInString(s, -1)
+	return len(cluster) == len(s)
+}
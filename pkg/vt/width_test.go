@@ -0,0 +1,87 @@
+package vt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuneWidth(t *testing.T) {
+	assert.Equal(t, 1, vt.RuneWidth('a'))
+	assert.Equal(t, 2, vt.RuneWidth('日'))
+	assert.Equal(t, 2, vt.RuneWidth('가'))
+	assert.Equal(t, 1, vt.RuneWidth('ｱ'), "halfwidth katakana")
+	assert.Equal(t, 1, vt.RuneWidth('①'), "ambiguous width is narrow")
+	assert.Equal(t, 0, vt.RuneWidth('́'))
+	assert.Equal(t, 0, vt.RuneWidth('‍'))
+	assert.Equal(t, 0, vt.RuneWidth('️'))
+	assert.Equal(t, 6, vt.StringWidth("日本語"))
+}
+
+func TestWideCharacters(t *testing.T) {
+	t.Run("Wide characters take two cells", func(t *testing.T) {
+		s := newScreen(2, 10, "日本x")
+		assert.Equal(t, "日本x", s.Line(0).Text())
+		assert.Equal(t, 5, s.Cursor().X)
+		assert.True(t, s.Cell(0, 0).Wide)
+		assert.True(t, s.Cell(1, 0).Continuation)
+		assert.Equal(t, 'x', s.Cell(4, 0).Rune)
+	})
+
+	t.Run("Japanese prompt", func(t *testing.T) {
+		s := newScreen(1, 20, "ユーザー$ \x1b[Kls")
+		assert.Equal(t, 12, s.Cursor().X)
+		assert.Equal(t, "ユーザー$ ls", s.Line(0).Text())
+	})
+
+	t.Run("Wrap before a wide character at the margin", func(t *testing.T) {
+		s := newScreen(2, 5, "abcd日")
+		assert.Equal(t, "abcd\n日", s.String())
+		assert.True(t, s.Line(0).Wrapped)
+		assert.Equal(t, 2, s.Cursor().X)
+	})
+
+	t.Run("Overwriting half of a wide character", func(t *testing.T) {
+		s := newScreen(1, 6, "日本\x1b[2Gx")
+		assert.Equal(t, " x本", s.Line(0).Text())
+		s = newScreen(1, 6, "日本\x1b[1G\x1b[P")
+		assert.Equal(t, " 本", s.Line(0).Text())
+	})
+
+	t.Run("Combining marks", func(t *testing.T) {
+		s := newScreen(1, 10, "éa")
+		assert.Equal(t, "éa", s.Line(0).Text())
+		assert.Equal(t, 2, s.Cursor().X)
+		assert.Equal(t, "́", s.Cell(0, 0).Combining)
+
+		// Endless marks on one character are cut.
+		s = newScreen(1, 10, "e"+strings.Repeat("\u0301", 1000)+"a")
+		assert.Equal(t, strings.Repeat("\u0301", 16), s.Cell(0, 0).Combining)
+		assert.Equal(t, 'a', s.Cell(1, 0).Rune)
+	})
+
+	t.Run("Emoji ZWJ sequence per code point", func(t *testing.T) {
+		s := newScreen(1, 10, "👨‍👩x")
+		assert.Equal(t, 5, s.Cursor().X)
+		assert.Equal(t, "👨‍", s.Cell(0, 0).Char())
+	})
+
+	t.Run("Grapheme cluster mode", func(t *testing.T) {
+		s := newScreen(1, 10, "\x1b[?2027h👨‍👩‍👧x❤️y")
+		assert.Equal(t, "👨‍👩‍👧", s.Cell(0, 0).Char())
+		assert.Equal(t, 'x', s.Cell(2, 0).Rune)
+		assert.Equal(t, "❤️", s.Cell(3, 0).Char())
+		assert.True(t, s.Cell(3, 0).Wide)
+		assert.Equal(t, 'y', s.Cell(5, 0).Rune)
+		assert.True(t, s.PrivateMode(vt.ModeGraphemeClusters))
+	})
+
+	t.Run("Resize moves a wide character to the next row", func(t *testing.T) {
+		s := newScreen(3, 6, "ab日本")
+		s.Resize(3, 3)
+		assert.Equal(t, "ab\n日\n本", s.String())
+		assert.Equal(t, 2, s.Cursor().Y)
+	})
+}
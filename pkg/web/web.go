@@ -0,0 +1,222 @@
+// Package web serves sessions to browsers: an xterm.js page, and a
+// WebSocket on which each connection runs a session of its own.
+package web
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/session"
+	"github.com/FelipePn10/kariuki/pkg/vt"
+	"github.com/gorilla/websocket"
+)
+
+// Types of messages. The browser sends input, resize and ping; the server
+// sends config first, then output, pong and finally exit.
+const (
+	TypeConfig = "config"
+	TypeInput  = "input"
+	TypeOutput = "output"
+	TypeResize = "resize"
+	TypePing   = "ping"
+	TypePong   = "pong"
+	TypeExit   = "exit"
+)
+
+// readTimeout is how long a browser may stay silent; the page pings more often.
+const readTimeout = time.Minute
+
+//go:embed index.html
+var indexPage []byte
+
+// Message is a WebSocket text message, a JSON object.
+type Message struct {
+	Type  string `json:"type"`
+	Data  string `json:"data,omitempty"` // Input and output
+	Rows  int    `json:"rows,omitempty"` // Resize
+	Cols  int    `json:"cols,omitempty"`
+	Code  int    `json:"code,omitempty"`  // Exit status
+	Theme *Theme `json:"theme,omitempty"` // Config
+}
+
+// Theme is the appearance settings, named as the options of xterm.js.
+type Theme struct {
+	FontFamily  string `json:"fontFamily"`
+	Background  string `json:"background"`
+	Foreground  string `json:"foreground"`
+	CursorStyle string `json:"cursorStyle"` // block, underline or bar
+	CursorBlink bool   `json:"cursorBlink"`
+}
+
+// ThemeOf returns the appearance settings of config.
+func ThemeOf(config *terminal.TerminalConfig) *Theme {
+	// The names of the shapes are those of xterm.js.
+	shape, _ := vt.ParseCursorShape(config.CursorStyle)
+	return &Theme{
+		FontFamily:  config.Font,
+		Background:  config.Background().Hex(color.RGB(0, 0, 0)),
+		Foreground:  config.Foreground().Hex(color.RGB(0xe5, 0xe5, 0xe5)),
+		CursorStyle: shape.String(),
+		CursorBlink: config.CursorBlink,
+	}
+}
+
+// Server serves the page on / and sessions on /ws. Both need the token,
+// as a token query parameter.
+type Server struct {
+	mu       sync.Mutex
+	config   *terminal.TerminalConfig
+	command  []string
+	token    string
+	upgrader websocket.Upgrader
+	mux      *http.ServeMux
+}
+
+// NewServer runs command in the sessions of browsers that know token.
+func NewServer(config *terminal.TerminalConfig, command []string, token string) *Server {
+	srv := &Server{config: config, command: command, token: token}
+	srv.mux = http.NewServeMux()
+	srv.mux.HandleFunc("GET /{$}", srv.servePage)
+	srv.mux.HandleFunc("GET /ws", srv.serveSocket)
+	return srv
+}
+
+// ApplyConfig sets the configuration of sessions started from now on.
+func (srv *Server) ApplyConfig(config *terminal.TerminalConfig) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.config = config
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(srv.token)) != 1 {
+		http.Error(w, "a valid token is required", http.StatusUnauthorized)
+		return
+	}
+	srv.mux.ServeHTTP(w, r)
+}
+
+func (srv *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Write(indexPage)
+}
+
+// serveSocket runs a session for the browser until the program exits or
+// the browser goes away. Pages of other origins are refused by the upgrader.
+func (srv *Server) serveSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := srv.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &socket{ws: ws}
+	defer ws.Close()
+
+	srv.mu.Lock()
+	config := *srv.config
+	srv.mu.Unlock()
+	if err := c.send(Message{Type: TypeConfig, Theme: ThemeOf(&config)}); err != nil {
+		return
+	}
+
+	s := session.NewSession(&config, srv.command)
+	if err := s.Start(); err != nil {
+		c.send(Message{Type: TypeOutput, Data: "kariuki: " + err.Error() + "\r\n"})
+		c.send(Message{Type: TypeExit, Code: 1})
+		return
+	}
+	// xterm.js shows 24-bit color.
+	s.SetColorProfile(color.TrueColor)
+
+	in, keys := io.Pipe()
+	go func() {
+		keys.CloseWithError(c.read(s, keys))
+		// The browser is gone, as if its window was closed.
+		s.Hangup()
+	}()
+	code := session.ExitCode(s.Run(in, &outputWriter{c: c}))
+	in.Close()
+	c.send(Message{Type: TypeExit, Code: code})
+	ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+}
+
+// socket is a WebSocket written from several goroutines.
+type socket struct {
+	ws  *websocket.Conn
+	wmu sync.Mutex
+}
+
+func (c *socket) send(m Message) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.ws.WriteJSON(m)
+}
+
+// read passes the browser's keys to the session through keys and applies
+// its size changes, until the browser goes away.
+func (c *socket) read(s *session.Session, keys io.Writer) error {
+	for {
+		c.ws.SetReadDeadline(time.Now().Add(readTimeout))
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+		switch m.Type {
+		case TypeInput:
+			if _, err := io.WriteString(keys, m.Data); err != nil {
+				return err
+			}
+		case TypeResize:
+			// Sizes a client may not ask for are ignored.
+			if session.CheckSize(m.Rows, m.Cols) == nil {
+				s.Resize(m.Rows, m.Cols)
+			}
+		case TypePing:
+			if err := c.send(Message{Type: TypePong}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// outputWriter sends program output as output messages. JSON strings hold
+// text, so a character cut in two by a read waits for its other part.
+type outputWriter struct {
+	c       *socket
+	pending []byte
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	data := append(w.pending, p...)
+	n := len(data)
+	for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				n = i
+			}
+			break
+		}
+	}
+	w.pending = append([]byte(nil), data[n:]...)
+	if n == 0 {
+		return len(p), nil
+	}
+	if err := w.c.send(Message{Type: TypeOutput, Data: string(data[:n])}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
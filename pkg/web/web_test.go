@@ -0,0 +1,117 @@
+package web_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/web"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThemeOf(t *testing.T) {
+	cfg := &terminal.TerminalConfig{Font: "Fira Code", BgColor: "#102030", TextColor: "white", CursorStyle: "bar", CursorBlink: true}
+	assert.Equal(t, &web.Theme{
+		FontFamily:  "Fira Code",
+		Background:  "#102030",
+		Foreground:  "#e5e5e5",
+		CursorStyle: "bar",
+		CursorBlink: true,
+	}, web.ThemeOf(cfg))
+
+	cfg = &terminal.TerminalConfig{CursorStyle: "beam"}
+	assert.Equal(t, &web.Theme{Background: "#000000", Foreground: "#e5e5e5", CursorStyle: "block"}, web.ThemeOf(cfg))
+}
+
+func TestServer(t *testing.T) {
+	cfg := &terminal.TerminalConfig{Rows: 24, Cols: 80, BgColor: "black", TextColor: "white", CursorStyle: "underline"}
+	ts := httptest.NewServer(web.NewServer(cfg, []string{"sh"}, "secret"))
+	defer ts.Close()
+
+	t.Run("Token", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/?token=wrong")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		_, resp, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, err = http.Get(ts.URL + "/?token=secret")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		page, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(page), "xterm")
+	})
+
+	t.Run("Other origin", func(t *testing.T) {
+		header := http.Header{"Origin": {"https://example.com"}}
+		_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?token=secret", header)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Size too large", func(t *testing.T) {
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?token=secret", nil)
+		require.NoError(t, err)
+		defer ws.Close()
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		require.NoError(t, ws.WriteJSON(web.Message{Type: web.TypeResize, Rows: 65535, Cols: 65535}))
+		require.NoError(t, ws.WriteJSON(web.Message{Type: web.TypeInput, Data: "stty size; exit\r"}))
+		var output strings.Builder
+		for {
+			var m web.Message
+			require.NoError(t, ws.ReadJSON(&m))
+			if m.Type == web.TypeExit {
+				break
+			}
+			output.WriteString(m.Data)
+		}
+		assert.Contains(t, output.String(), "24 80")
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?token=secret", nil)
+		require.NoError(t, err)
+		defer ws.Close()
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		var m web.Message
+		require.NoError(t, ws.ReadJSON(&m))
+		assert.Equal(t, web.TypeConfig, m.Type)
+		assert.Equal(t, "underline", m.Theme.CursorStyle)
+
+		require.NoError(t, ws.WriteJSON(web.Message{Type: web.TypeResize, Rows: 30, Cols: 100}))
+		require.NoError(t, ws.WriteJSON(web.Message{Type: web.TypePing}))
+		require.NoError(t, ws.WriteJSON(web.Message{Type: web.TypeInput, Data: "stty size; exit 3\r"}))
+
+		var output strings.Builder
+		var pong bool
+		for {
+			var m web.Message
+			require.NoError(t, ws.ReadJSON(&m))
+			switch m.Type {
+			case web.TypeOutput:
+				output.WriteString(m.Data)
+			case web.TypePong:
+				pong = true
+			}
+			if m.Type == web.TypeExit {
+				assert.Equal(t, 3, m.Code)
+				break
+			}
+		}
+		assert.True(t, pong)
+		assert.Contains(t, output.String(), "30 100")
+	})
+}
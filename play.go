@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/asciicast"
+	"golang.org/x/term"
+)
+
+// startRecording records the session, or the mux, to a new asciicast file.
+// The returned function closes it.
+func startRecording(s app, cfg *terminal.TerminalConfig, command []string) (func(), error) {
+	path := cfg.RecordingPath(terminal.AppName, time.Now())
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	cols, rows := cfg.Cols, cfg.Rows
+	if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		cols, rows = w, h
+	}
+	rec, err := asciicast.NewWriter(f, asciicast.Header{
+		Width:   cols,
+		Height:  rows,
+		Command: strings.Join(command, " "),
+		Env:     map[string]string{"SHELL": terminal.DefaultShell(), "TERM": os.Getenv("TERM")},
+	})
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write recording: %w", err)
+	}
+	s.SetRecorder(rec)
+	return func() {
+		s.SetRecorder(nil)
+		f.Close()
+	}, nil
+}
+
+// play replays a recording: `kariuki play [-speed n] [-idle d] file.cast`.
+func play(args []string) int {
+	opts, err := terminal.ParsePlayArgs(args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+
+	f, err := os.Open(opts.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	r, err := asciicast.NewReader(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %s: %v\n", opts.Path, err)
+		return 1
+	}
+
+	player := asciicast.NewPlayer(opts.Speed, opts.IdleLimit)
+
+	// Space pauses and resumes, q or Ctrl-C quits.
+	stdin := int(os.Stdin.Fd())
+	if term.IsTerminal(stdin) {
+		oldState, err := term.MakeRaw(stdin)
+		if err == nil {
+			defer term.Restore(stdin, oldState)
+			go readPlayKeys(player)
+		}
+	}
+
+	err = player.Play(r, os.Stdout)
+	// Leave the host terminal in its usual state whatever the recording did.
+	fmt.Print("\x1b[0m\x1b[?25h\r\n")
+	if err != nil && err != asciicast.ErrStopped {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func readPlayKeys(player *asciicast.Player) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			return
+		}
+		switch buf[0] {
+		case ' ':
+			player.TogglePause()
+		case 'q', 0x03:
+			player.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/color"
+	"github.com/FelipePn10/kariuki/pkg/daemon"
+	"golang.org/x/term"
+)
+
+// share lets others watch a session of the daemon, or changes what they
+// may do: `kariuki share [-name session] [-stop | -grant id | -revoke id]`.
+func share(args []string) int {
+	opts, err := terminal.ParseShareArgs(args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+	cfg, err := terminal.LoadConfig(opts.ConfigPath, terminal.AppName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	path := opts.Socket
+	if path == "" {
+		path = cfg.SocketPath(terminal.AppName)
+	}
+
+	req := daemon.ShareRequest{Name: opts.Name, Action: daemon.ShareStart}
+	switch {
+	case opts.Stop:
+		req.Action = daemon.ShareStop
+	case opts.Grant != 0:
+		req.Action, req.Viewer = daemon.ShareGrant, opts.Grant
+	case opts.Revoke != 0:
+		req.Action, req.Viewer = daemon.ShareRevoke, opts.Revoke
+	}
+
+	client, err := daemon.Dial(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kariuki: no daemon is running")
+		return 1
+	}
+	defer client.Close()
+	status, err := client.Share(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	if !status.Shared {
+		fmt.Printf("%s is not shared\n", opts.Name)
+		return 0
+	}
+
+	join := fmt.Sprintf("%s join -name %s -token %s", terminal.AppName, opts.Name, status.Token)
+	if status.Address != "" {
+		join += " -address " + status.Address
+	}
+	fmt.Printf("%s is shared; viewers join with:\n  %s\n", opts.Name, join)
+	for _, v := range status.Viewers {
+		access := "read-only"
+		if v.CanType {
+			access = "can type"
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\tsince %s\n", v.ID, v.User, v.Via, access, v.Since.Format(time.DateTime))
+	}
+	return 0
+}
+
+// join watches a session another user shares: `kariuki join -token token`.
+func join(args []string) int {
+	opts, err := terminal.ParseJoinArgs(args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+	cfg, err := terminal.LoadConfig(opts.ConfigPath, terminal.AppName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+
+	var client *daemon.Client
+	switch address := opts.Address; {
+	case opts.Socket != "":
+		client, err = daemon.Dial(opts.Socket)
+	case address == "" && cfg.ShareAddress == "":
+		client, err = daemon.Dial(cfg.SocketPath(terminal.AppName))
+	case address == "":
+		client, err = daemon.DialShared(cfg.ShareAddress)
+	default:
+		client, err = daemon.DialShared(address)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	req := daemon.JoinRequest{
+		Name:    opts.Name,
+		Token:   opts.Token,
+		User:    opts.As,
+		Profile: color.DetectProfile(os.Getenv),
+	}
+	var in io.Reader = os.Stdin
+	stdin := int(os.Stdin.Fd())
+	if term.IsTerminal(stdin) {
+		oldState, err := term.MakeRaw(stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kariuki: failed to set raw mode: %v\n", err)
+			return 1
+		}
+		defer term.Restore(stdin, oldState)
+		in = &leaveReader{r: os.Stdin, prefix: cfg.Prefix()}
+		// The viewer's own screen comes back afterwards.
+		os.Stdout.WriteString("\x1b[?1049h")
+		defer os.Stdout.WriteString("\x1b[?1049l\x1b[?1l")
+	}
+
+	result, err := client.Join(req, in, os.Stdout)
+	os.Stdout.WriteString("\x1b[?25h\x1b[0m")
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "\r\nkariuki: %v\r\n", err)
+		return 1
+	case result.Detached:
+		return 0
+	}
+	return result.ExitCode
+}
+
+// leaveReader passes keys on until the prefix key followed by d, when it
+// ends. The prefix key typed twice is passed on once.
+type leaveReader struct {
+	r        io.Reader
+	prefix   byte
+	prefixed bool
+	left     bool
+}
+
+func (l *leaveReader) Read(p []byte) (int, error) {
+	if l.left {
+		return 0, io.EOF
+	}
+	for {
+		// A prefix key held back from the last read may come out with the
+		// next key, so one byte of p is kept free for it.
+		buf := make([]byte, max(len(p)-1, 1))
+		n, err := l.r.Read(buf)
+		out := p[:0]
+		for _, b := range buf[:n] {
+			switch {
+			case l.prefixed && b == 'd':
+				l.left = true
+				return len(out), nil
+			case l.prefixed:
+				l.prefixed = false
+				if b != l.prefix {
+					out = append(out, l.prefix)
+				}
+				out = append(out, b)
+			case b == l.prefix:
+				l.prefixed = true
+			default:
+				out = append(out, b)
+			}
+		}
+		if len(out) > 0 || err != nil {
+			return len(out), err
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/audit"
+	"github.com/FelipePn10/kariuki/pkg/sshserver"
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSSH runs restricted sessions for SSH clients:
+// `kariuki ssh [-address host:port] [-host-key file] [-authorized-keys file] [command...]`.
+func serveSSH(args []string) int {
+	opts, err := terminal.ParseSSHArgs(args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+	cfg, err := terminal.LoadConfig(opts.ConfigPath, terminal.AppName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	installTerminfo(cfg)
+	address := opts.Address
+	if address == "" {
+		address = cfg.SSHAddress
+	}
+	hostKeyPath := opts.HostKey
+	if hostKeyPath == "" {
+		hostKeyPath = cfg.SSHHostKeyPath(terminal.AppName)
+	}
+	authorized := opts.AuthorizedKeys
+	if authorized == "" {
+		authorized = cfg.SSHAuthorizedKeysPath(terminal.AppName)
+	}
+	if _, err := os.Stat(authorized); err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: no authorized keys: %v\n", err)
+		return 1
+	}
+	hostKey, err := sshserver.LoadHostKey(hostKeyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+
+	srv := sshserver.NewServer(cfg, opts.Command, hostKey, authorized)
+	terminal.OnReload(srv.ApplyConfig)
+	if cfg.EnableLogging {
+		log, err := audit.Open(cfg.AuditLogPath(terminal.AppName))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kariuki: failed to open the audit log: %v\n", err)
+			return 1
+		}
+		defer log.Close()
+		srv.SetAuditLog(log)
+	}
+	if len(cfg.AllowedCommands) == 0 {
+		fmt.Fprintln(os.Stderr, "kariuki: warning: allowed_commands is empty, clients may run any program")
+	}
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Listening on %s, host key %s\n", l.Addr(), ssh.FingerprintSHA256(hostKey.PublicKey()))
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-stop
+		l.Close()
+	}()
+	if err := srv.Serve(l); err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/FelipePn10/kariuki/cmd/terminal"
+	"github.com/FelipePn10/kariuki/pkg/web"
+)
+
+// serveWeb runs sessions for browsers:
+// `kariuki web [-address host:port] [-token token] [command...]`.
+func serveWeb(args []string) int {
+	opts, err := terminal.ParseWebArgs(args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+	cfg, err := terminal.LoadConfig(opts.ConfigPath, terminal.AppName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	installTerminfo(cfg)
+	address := opts.Address
+	if address == "" {
+		address = cfg.WebAddress
+	}
+	token := opts.Token
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+			return 1
+		}
+		token = hex.EncodeToString(b)
+	}
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	if addr, ok := l.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+		fmt.Fprintf(os.Stderr, "kariuki: warning: %s is reachable from other hosts, over plain HTTP\n", l.Addr())
+	}
+	srv := web.NewServer(cfg, opts.Command, token)
+	terminal.OnReload(srv.ApplyConfig)
+	fmt.Fprintf(os.Stderr, "Open http://%s/?token=%s\n", l.Addr(), url.QueryEscape(token))
+
+	server := &http.Server{Handler: srv}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-stop
+		server.Close()
+	}()
+	if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "kariuki: %v\n", err)
+		return 1
+	}
+	return 0
+}